@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/saucer-man/wxdump/pkg/wexin"
+)
+
+// runListChats 列出解密后数据库中的会话，顺序与客户端一致
+func runListChats(args []string) error {
+	fs := flag.NewFlagSet("list-chats", flag.ExitOnError)
+	dir := fs.String("dir", "", "解密后的数据库目录（包含 wxid 子目录）")
+	wxid := fs.String("wxid", "", "账号 wxid")
+	version := fs.Int("version", 4, "微信版本：3 或 4")
+	hidden := fs.Bool("hidden", false, "包括折叠、隐藏的会话")
+	fs.Parse(args)
+
+	if *dir == "" || *wxid == "" {
+		fs.Usage()
+		return fmt.Errorf("need -dir and -wxid")
+	}
+	a := &wexin.Account{Wxid: *wxid, Version: *version}
+	sessions, err := a.LoadSessions(*dir)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "时间\t未读\t标记\t会话\tusername\t最后一条消息")
+	for _, s := range sessions {
+		if s.Hidden && !*hidden {
+			continue
+		}
+		var mark string
+		if s.Sticky {
+			mark += "置顶 "
+		}
+		if s.Muted {
+			mark += "免打扰"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n", s.LastTime.Format("2006-01-02 15:04"), s.Unread, mark,
+			s.DisplayName, s.Username, truncateRunes(s.Summary, 40))
+	}
+	return w.Flush()
+}
+
+func truncateRunes(s string, n int) string {
+	r := []rune(strings.Join(strings.Fields(s), " "))
+	if len(r) <= n {
+		return string(r)
+	}
+	return string(r[:n]) + "..."
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/saucer-man/wxdump/pkg/export"
+	"github.com/saucer-man/wxdump/pkg/wexin"
+)
+
+// runExport 把解密后的数据库导出成 HTML 等格式
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "html", "导出格式：html、jsonl、csv、xlsx、markdown、sqlite")
+	dir := fs.String("dir", "", "解密后的数据库目录（包含 wxid 子目录）")
+	wxid := fs.String("wxid", "", "账号 wxid")
+	version := fs.Int("version", 4, "微信版本：3 或 4")
+	dataDir := fs.String("data-dir", "", "账号的微信数据目录，用于导出图片、视频和文件")
+	aesKey := fs.String("aes-key", "", "v4 图片 AES key")
+	xorKey := fs.String("xor-key", "", "图片异或 key，例如 0x5A，为空时自动推算")
+	chats := fs.String("chat", "", "只导出这些会话，多个 username 用逗号分隔")
+	columns := fs.String("columns", "", "csv/xlsx 导出的列，逗号分隔，可选："+strings.Join(export.CSVColumnNames(), ","))
+	tz := fs.String("tz", "", "csv/xlsx 中时间的时区，例如 Asia/Shanghai，默认本地时区")
+	out := fs.String("out", "", "输出目录")
+	fs.Parse(args)
+
+	if *dir == "" || *wxid == "" || *out == "" {
+		fs.Usage()
+		return fmt.Errorf("need -dir, -wxid and -out")
+	}
+	a := &wexin.Account{Wxid: *wxid, Version: *version, DataDir: *dataDir, ImageAesKey: *aesKey, ImageXorKey: *xorKey}
+	src, err := export.Open(a, *dir)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if *chats != "" {
+		src.Talkers = strings.Split(*chats, ",")
+	}
+
+	switch *format {
+	case "html":
+		return export.ExportHTML(src, *out)
+	case "jsonl":
+		return export.ExportJSONL(src, *out)
+	case "markdown", "md":
+		return export.ExportMarkdown(src, *out)
+	case "sqlite":
+		return export.ExportSQLite(src, *out)
+	case "csv", "xlsx":
+		opts := export.CSVOptions{XLSX: *format == "xlsx"}
+		if *columns != "" {
+			opts.Columns = strings.Split(*columns, ",")
+		}
+		if *tz != "" {
+			loc, err := time.LoadLocation(*tz)
+			if err != nil {
+				return err
+			}
+			opts.Location = loc
+		}
+		return export.ExportCSV(src, *out, opts)
+	}
+	return fmt.Errorf("unknown format: %s", *format)
+}
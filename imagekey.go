@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/saucer-man/wxdump/pkg/wexin"
+)
+
+// runImageKey 离线校验 v4 图片 AES key：候选 key 来自进程内存或内存 dump 文件
+func runImageKey(args []string) error {
+	fs := flag.NewFlagSet("image-key", flag.ExitOnError)
+	sample := fs.String("sample", "", "V2 格式的 .dat 样本")
+	dump := fs.String("dump", "", "内存 dump 或字符串文件，从可打印字符串中提取候选 key")
+	pid := fs.Uint("pid", 0, "从该 Weixin 进程的内存中提取候选 key")
+	fs.Parse(args)
+
+	if *sample == "" || (*dump == "" && *pid == 0) {
+		fs.Usage()
+		return fmt.Errorf("need -sample and one of -dump/-pid")
+	}
+	var candidates [][]byte
+	var err error
+	if *dump != "" {
+		candidates, err = wexin.ImageAesKeyCandidatesFromFile(*dump)
+	} else {
+		candidates, err = wexin.ImageAesKeyCandidatesFromProcess(uint32(*pid))
+	}
+	if err != nil {
+		return err
+	}
+	a := &wexin.Account{Version: 4}
+	if err := a.BruteForceImageAesKeyV4(*sample, candidates); err != nil {
+		return err
+	}
+	fmt.Printf("image aes key: %s\n", a.ImageAesKey)
+	return nil
+}
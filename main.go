@@ -3,6 +3,7 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"os"
 
 	"github.com/saucer-man/wxdump/pkg/wexin"
 	"github.com/sirupsen/logrus"
@@ -11,6 +12,27 @@ import (
 // GetAccounts 获取所有账号
 func main() {
 	logrus.SetLevel(logrus.DebugLevel)
+	// 子命令
+	if len(os.Args) > 1 {
+		var err error
+		switch os.Args[1] {
+		case "offsets":
+			err = runOffsets(os.Args[2:])
+		case "image-key":
+			err = runImageKey(os.Args[2:])
+		case "list-chats":
+			err = runListChats(os.Args[2:])
+		case "export":
+			err = runExport(os.Args[2:])
+		default:
+			err = fmt.Errorf("unknown command: %s", os.Args[1])
+		}
+		if err != nil {
+			logrus.Info(err)
+			os.Exit(1)
+		}
+		return
+	}
 	// 获取所有账号，并压缩到指定的地方
 	accounts := wexin.GetWexinList()
 	for _, account := range accounts {
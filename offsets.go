@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/saucer-man/wxdump/pkg/utils"
+	"github.com/saucer-man/wxdump/pkg/wexin"
+)
+
+// runOffsets 处理 offsets 子命令：list / merge / check
+func runOffsets(args []string) error {
+	fs := flag.NewFlagSet("offsets", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: wxdump offsets list")
+		fmt.Fprintln(os.Stderr, "       wxdump offsets merge <WX_OFFS.json>")
+		fmt.Fprintln(os.Stderr, "       wxdump offsets check")
+		fmt.Fprintln(os.Stderr, "       wxdump offsets calibrate -nickname <昵称> -account <微信号> -phone <手机号> [-pid <pid>]")
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return nil
+	}
+
+	switch fs.Arg(0) {
+	case "list":
+		for _, v := range wexin.SupportedVersionsV3() {
+			fmt.Printf("%-12s %-8s %v\n", v, wexin.OffSetSource[v], wexin.OffSetMap[v])
+		}
+		fmt.Printf("total: %d\n", len(wexin.OffSetMap))
+	case "merge":
+		if fs.NArg() < 2 {
+			fs.Usage()
+			return fmt.Errorf("missing WX_OFFS.json path")
+		}
+		result, err := wexin.MergeOffsetsFile(fs.Arg(1))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("added: %d %v\n", len(result.Added), result.Added)
+		fmt.Printf("updated: %d %v\n", len(result.Updated), result.Updated)
+		for _, s := range result.Skipped {
+			fmt.Printf("skipped: %s\n", s)
+		}
+	case "check":
+		unsupported, err := wexin.UnsupportedRunningV3()
+		if err != nil {
+			return err
+		}
+		if len(unsupported) == 0 {
+			fmt.Println("all running v3 processes are supported")
+			return nil
+		}
+		for _, proc := range unsupported {
+			fmt.Printf("unsupported: pid=%d version=%s exe=%s\n", proc.PID, proc.FullVersion, proc.ExePath)
+		}
+	case "calibrate":
+		return runCalibrate(fs.Args()[1:])
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown offsets command: %s", fs.Arg(0))
+	}
+	return nil
+}
+
+// runCalibrate 用已登录账号界面上的昵称、微信号、手机号校准当前 v3 版本的偏移
+func runCalibrate(args []string) error {
+	fs := flag.NewFlagSet("calibrate", flag.ExitOnError)
+	nickname := fs.String("nickname", "", "昵称")
+	account := fs.String("account", "", "微信号")
+	phone := fs.String("phone", "", "手机号")
+	pid := fs.Uint("pid", 0, "微信进程 pid，默认取第一个 v3 进程")
+	fs.Parse(args)
+
+	processes, err := utils.FindWeixinProcesses()
+	if err != nil {
+		return err
+	}
+	for _, proc := range processes {
+		if proc.Version != 3 || (*pid != 0 && proc.PID != uint32(*pid)) {
+			continue
+		}
+		a := wexin.NewAccount(proc)
+		offsets, err := a.CalibrateOffsetsV3(*nickname, *account, *phone)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\"%s\": %v\n", a.FullVersion, offsets)
+		fmt.Printf("key: %s\n", a.Key)
+		return nil
+	}
+	return fmt.Errorf("no running v3 process found")
+}
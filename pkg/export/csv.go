@@ -0,0 +1,248 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saucer-man/wxdump/pkg/wexin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	CSVAllFile   = "all.csv"
+	CSVChatDir   = "chats"
+	XLSXFile     = "messages.xlsx"
+	csvMediaDir  = "media"
+	csvTimeFmt   = "2006-01-02 15:04:05"
+	utf8BOM      = "\uFEFF"
+	csvColChatID = "chat_id"
+)
+
+// CSV 可选的列
+var csvColumns = map[string]struct {
+	title string
+	value func(r *csvRow) string
+}{
+	"time":        {"时间", func(r *csvRow) string { return r.time }},
+	"unix":        {"时间戳", func(r *csvRow) string { return strconv.FormatInt(r.m.CreateTime.Unix(), 10) }},
+	csvColChatID:  {"会话ID", func(r *csvRow) string { return r.sess.Username }},
+	"chat_name":   {"会话", func(r *csvRow) string { return r.sess.DisplayName }},
+	"sender":      {"发送者ID", func(r *csvRow) string { return r.m.Sender }},
+	"sender_name": {"发送者", func(r *csvRow) string { return r.m.SenderName }},
+	"is_self":     {"是否自己发送", func(r *csvRow) string { return strconv.FormatBool(r.m.IsSender) }},
+	"type":        {"类型", func(r *csvRow) string { return r.typeName() }},
+	"text":        {"内容", func(r *csvRow) string { return r.text() }},
+	"media":       {"媒体文件", func(r *csvRow) string { return r.mediaPath() }},
+	"server_id":   {"消息ID", func(r *csvRow) string { return strconv.FormatInt(r.m.ServerID, 10) }},
+	"local_id":    {"本地ID", func(r *csvRow) string { return strconv.FormatInt(r.m.LocalID, 10) }},
+	"reply_to":    {"引用消息ID", func(r *csvRow) string { return r.replyTo() }},
+}
+
+// DefaultCSVColumns 默认导出的列，合并的 all.csv 会在前面加上会话列
+var DefaultCSVColumns = []string{"time", "sender_name", "type", "text"}
+
+// CSVOptions CSV / xlsx 导出选项
+type CSVOptions struct {
+	Columns  []string       // 为空时使用 DefaultCSVColumns
+	Location *time.Location // 时间显示的时区，为空时使用本地时区
+	XLSX     bool           // 导出成 xlsx，每个会话一个 sheet
+}
+
+// CSVColumnNames 所有可选的列
+func CSVColumnNames() []string {
+	return []string{"time", "unix", csvColChatID, "chat_name", "sender", "sender_name", "is_self", "type", "text", "media", "server_id", "local_id", "reply_to"}
+}
+
+type csvRow struct {
+	m     *wexin.Message
+	sess  *wexin.Session
+	media *Media
+	time  string
+}
+
+func (r *csvRow) typeName() string {
+	name := MsgTypeName(r.m.Type)
+	if r.m.Type == wexin.MsgTypeApp {
+		if app, err := r.m.AppMsg(); err == nil {
+			name += ":" + AppKind(app)
+		}
+	}
+	return name
+}
+
+// text 可读内容，带媒体文件的消息在后面加上路径，例如 "[图片] media/xxx/image/1.jpg"
+func (r *csvRow) text() string {
+	text := Describe(r.m)
+	if r.media != nil {
+		text += " " + r.media.Path
+	}
+	return text
+}
+
+func (r *csvRow) mediaPath() string {
+	if r.media == nil {
+		return ""
+	}
+	return r.media.Path
+}
+
+func (r *csvRow) replyTo() string {
+	if r.m.ReplyTo == nil {
+		return ""
+	}
+	return strconv.FormatInt(r.m.ReplyTo.ServerID, 10)
+}
+
+// ExportCSV 每个会话导出一个 CSV（chats/<username>.csv），以及包含所有会话的 all.csv，
+// 文件带 UTF-8 BOM，Excel 可以直接打开；XLSX 为 true 时改为导出 messages.xlsx
+func ExportCSV(src *Source, outDir string, opts CSVOptions) error {
+	columns := opts.Columns
+	if len(columns) == 0 {
+		columns = DefaultCSVColumns
+	}
+	for _, col := range columns {
+		if _, ok := csvColumns[col]; !ok {
+			return fmt.Errorf("unknown column %q, available: %s", col, strings.Join(CSVColumnNames(), ","))
+		}
+	}
+	allColumns := columns
+	if !slices.Contains(columns, csvColChatID) && !slices.Contains(columns, "chat_name") {
+		allColumns = append([]string{"chat_name"}, columns...)
+	}
+	loc := opts.Location
+	if loc == nil {
+		loc = time.Local
+	}
+
+	chats, err := src.Chats()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return err
+	}
+	media := newMediaWriter(src, outDir, csvMediaDir)
+
+	var (
+		xw     *xlsxWriter
+		allOut *csv.Writer
+	)
+	if opts.XLSX {
+		f, err := os.Create(filepath.Join(outDir, XLSXFile))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		xw = newXLSXWriter(f)
+	} else {
+		if err := os.MkdirAll(filepath.Join(outDir, CSVChatDir), 0755); err != nil {
+			return err
+		}
+		f, w, err := createCSV(filepath.Join(outDir, CSVAllFile))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		allOut = w
+		allOut.Write(csvHeader(allColumns))
+	}
+
+	for _, sess := range chats {
+		msgs, err := src.Messages(sess.Username)
+		if err != nil {
+			logrus.Infof("export %s failed: %v", sess.Username, err)
+			continue
+		}
+		rows := [][]string{csvHeader(columns)}
+		for _, m := range msgs {
+			r := &csvRow{m: m, sess: sess, media: media.export(m), time: m.CreateTime.In(loc).Format(csvTimeFmt)}
+			rows = append(rows, csvValues(r, columns))
+			if allOut != nil {
+				allOut.Write(csvSafe(csvValues(r, allColumns)))
+			}
+		}
+		if xw != nil {
+			if err := xw.AddSheet(sess.DisplayName, rows); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := writeCSVFile(filepath.Join(outDir, CSVChatDir, safeName(sess.Username)+".csv"), rows); err != nil {
+			return err
+		}
+	}
+
+	if xw != nil {
+		return xw.Close()
+	}
+	allOut.Flush()
+	return allOut.Error()
+}
+
+func csvHeader(columns []string) []string {
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = csvColumns[col].title
+	}
+	return header
+}
+
+func csvValues(r *csvRow, columns []string) []string {
+	values := make([]string, len(columns))
+	for i, col := range columns {
+		values[i] = csvColumns[col].value(r)
+	}
+	return values
+}
+
+// createCSV 创建 CSV 文件并写入 BOM
+func createCSV(path string) (*os.File, *csv.Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := io.WriteString(f, utf8BOM); err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, csv.NewWriter(f), nil
+}
+
+func writeCSVFile(path string, rows [][]string) error {
+	f, w, err := createCSV(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	for _, row := range rows {
+		if err := w.Write(csvSafe(row)); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// csvSafe 防止 CSV 注入：以 = + - @ 制表符或回车开头的值在 Excel 中会被当作公式，前面加上 '；
+// 负数（包括按 int64 存的无符号 svrid）是合法的数值，保持原样
+func csvSafe(row []string) []string {
+	for i, v := range row {
+		if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) && !isCSVNumber(v) {
+			row[i] = "'" + v
+		}
+	}
+	return row
+}
+
+func isCSVNumber(v string) bool {
+	f, err := strconv.ParseFloat(v, 64)
+	return err == nil && !math.IsInf(f, 0) && !math.IsNaN(f)
+}
@@ -0,0 +1,83 @@
+package export
+
+import (
+	"archive/zip"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportCSV(t *testing.T) {
+	src := openSourceV3(t)
+	out := t.TempDir()
+	loc := time.FixedZone("CST", 8*3600)
+	if err := ExportCSV(src, out, CSVOptions{Location: loc, Columns: []string{"time", "sender_name", "text", "server_id"}}); err != nil {
+		t.Fatal(err)
+	}
+	chat := readFile(t, filepath.Join(out, CSVChatDir, "wxid_a.csv"))
+	if !strings.HasPrefix(chat, utf8BOM+"时间") {
+		t.Error("chat csv should start with BOM and header")
+	}
+	for _, want := range []string{"1970-01-01 08:01:40", "[转账] ￥12.00", ",11\n"} {
+		if !strings.Contains(chat, want) {
+			t.Errorf("chat csv missing %q", want)
+		}
+	}
+	if all := readFile(t, filepath.Join(out, CSVAllFile)); !strings.Contains(all, "会话,时间") {
+		t.Error("all.csv should start with the chat column")
+	}
+	if err := ExportCSV(src, out, CSVOptions{Columns: []string{"bogus"}}); err == nil {
+		t.Error("unknown column, want error")
+	}
+
+	if err := ExportCSV(src, out, CSVOptions{XLSX: true}); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.OpenReader(filepath.Join(out, XLSXFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	if len(zr.File) != 5 {
+		t.Errorf("xlsx has %d parts, want 5", len(zr.File))
+	}
+}
+
+func TestCSVSafe(t *testing.T) {
+	row := csvSafe([]string{"=1+1", "+a", "-2", "-9223372036854775808", "1.5", "-1+cmd", "@x", "\tt", "-Inf", "ok", ""})
+	want := []string{"'=1+1", "'+a", "-2", "-9223372036854775808", "1.5", "'-1+cmd", "'@x", "'\tt", "'-Inf", "ok", ""}
+	for i := range want {
+		if row[i] != want[i] {
+			t.Errorf("cell %d = %q, want %q", i, row[i], want[i])
+		}
+	}
+}
+
+func TestXLSXNames(t *testing.T) {
+	x := &xlsxWriter{names: make(map[string]bool)}
+	for _, c := range []struct{ in, want string }{
+		{"a/b", "a_b"},
+		{"A_B", "A_B(2)"},
+		{"'quoted'", "quoted"},
+		{"'", "Sheet"},
+		{"history", "history_"},
+		{strings.Repeat("长", 40), strings.Repeat("长", xlsxMaxSheetLen)},
+	} {
+		if got := x.sheetName(c.in); got != c.want {
+			t.Errorf("sheetName(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+	if xlsxColumn(0) != "A" || xlsxColumn(26) != "AA" {
+		t.Errorf("xlsxColumn = %s, %s", xlsxColumn(0), xlsxColumn(26))
+	}
+	for _, c := range []struct {
+		in   string
+		n    int
+		want string
+	}{{"a😀b", 2, "a"}, {"a😀b", 3, "a😀"}, {"中文", 5, "中文"}} {
+		if got := truncateUTF16(c.in, c.n); got != c.want {
+			t.Errorf("truncateUTF16(%q, %d) = %q, want %q", c.in, c.n, got, c.want)
+		}
+	}
+}
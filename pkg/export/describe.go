@@ -0,0 +1,131 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/saucer-man/wxdump/pkg/wexin"
+)
+
+// Describe 消息的可读文本，非文本消息渲染成 "[图片]"、"[转账] ￥12.00" 这样的形式
+func Describe(m *wexin.Message) string {
+	switch m.Type {
+	case wexin.MsgTypeText:
+		return m.Content
+	case wexin.MsgTypeImage:
+		return "[图片]"
+	case wexin.MsgTypeVoice:
+		return "[语音]"
+	case wexin.MsgTypeVideo:
+		return "[视频]"
+	case wexin.MsgTypeEmoji:
+		return "[表情]"
+	case wexin.MsgTypeVoip:
+		return "[通话]"
+	case wexin.MsgTypeCard:
+		return join("[名片]", xmlAttr(m.XML(), "msg", "nickname"))
+	case wexin.MsgTypeLocation:
+		label := xmlAttr(m.XML(), "location", "poiname")
+		if label == "" {
+			label = xmlAttr(m.XML(), "location", "label")
+		}
+		return join("[位置]", label)
+	case wexin.MsgTypeApp:
+		app, err := m.AppMsg()
+		if err != nil {
+			return "[应用消息]"
+		}
+		return describeApp(app)
+	case wexin.MsgTypeSystem, wexin.MsgTypeRevoke:
+		return describeSystem(m.Content)
+	}
+	if m.Content != "" && !strings.HasPrefix(strings.TrimSpace(m.Content), "<") {
+		return m.Content
+	}
+	return fmt.Sprintf("[消息类型 %d]", m.Type)
+}
+
+func describeApp(app wexin.AppMessage) string {
+	switch a := app.(type) {
+	case *wexin.TextAppMsg:
+		return a.Title
+	case *wexin.LinkMsg:
+		return join("[链接]", a.Title, a.URL)
+	case *wexin.FileMsg:
+		return join("[文件]", a.Title)
+	case *wexin.MusicMsg:
+		return join("[音乐]", a.Title, a.Des)
+	case *wexin.MiniProgramMsg:
+		return join("[小程序]", a.Title)
+	case *wexin.QuoteMsg:
+		return a.Title
+	case *wexin.RecordMsg:
+		return join("[聊天记录]", a.Title)
+	case *wexin.TransferMsg:
+		return join("[转账]", a.FeeDesc, a.Memo)
+	case *wexin.RedPacketMsg:
+		return join("[红包]", a.Title)
+	case *wexin.LocationShareMsg:
+		return join("[位置共享]", a.Title)
+	case *wexin.ChannelsMsg:
+		return join("[视频号]", a.Nickname, a.Desc)
+	case *wexin.PatMsg:
+		return a.Title
+	case *wexin.UnknownAppMsg:
+		return join("[应用消息]", a.Title)
+	}
+	return "[应用消息]"
+}
+
+// describeSystem 系统消息、撤回消息有时是 XML（<sysmsg>、<revokemsg>），取其中的文本
+func describeSystem(content string) string {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "<") {
+		return content
+	}
+	var x struct {
+		RevokeMsg struct {
+			ReplaceMsg string `xml:"replacemsg"`
+		} `xml:"revokemsg"`
+	}
+	dec := xml.NewDecoder(strings.NewReader(content))
+	dec.Strict = false
+	if dec.Decode(&x) == nil && x.RevokeMsg.ReplaceMsg != "" {
+		return x.RevokeMsg.ReplaceMsg
+	}
+	return "[系统消息]"
+}
+
+// xmlAttr 取 XML 中第一个 elem 元素的 attr 属性
+func xmlAttr(raw, elem, attr string) string {
+	if i := strings.Index(raw, "<"); i > 0 {
+		raw = raw[i:]
+	}
+	dec := xml.NewDecoder(strings.NewReader(raw))
+	dec.Strict = false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		if se, ok := tok.(xml.StartElement); ok && se.Name.Local == elem {
+			for _, a := range se.Attr {
+				if a.Name.Local == attr {
+					return a.Value
+				}
+			}
+			return ""
+		}
+	}
+}
+
+func join(parts ...string) string {
+	var out []string
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, " ")
+}
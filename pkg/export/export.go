@@ -0,0 +1,199 @@
+// Package export 把解密后的数据库导出成 HTML、JSON Lines 等格式，v3 和 v4 使用同一套消息模型。
+package export
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/saucer-man/wxdump/pkg/wexin"
+	"github.com/sirupsen/logrus"
+)
+
+// Source 一个账号的解密数据：消息、联系人、群聊、会话列表
+type Source struct {
+	Account      *wexin.Account
+	DecryptedDir string
+	Contacts     *wexin.Contacts
+	ChatRooms    *wexin.ChatRooms
+	Sessions     []*wexin.Session
+	// 只导出这些会话，为空时导出全部
+	Talkers []string
+
+	store wexin.MessageStore
+	names *wexin.NameResolver
+}
+
+// Open 打开解密目录，联系人、群聊和会话列表读取失败时只记录日志
+func Open(a *wexin.Account, decryptedDir string) (*Source, error) {
+	store, err := a.OpenMessageStore(decryptedDir)
+	if err != nil {
+		return nil, err
+	}
+	s := &Source{Account: a, DecryptedDir: decryptedDir, store: store}
+	if s.Contacts, err = a.LoadContacts(decryptedDir); err != nil {
+		logrus.Infof("load contacts failed: %v", err)
+	}
+	if s.ChatRooms, err = a.LoadChatRooms(decryptedDir); err != nil {
+		logrus.Infof("load chatrooms failed: %v", err)
+	}
+	if s.Sessions, err = a.LoadSessions(decryptedDir); err != nil {
+		logrus.Infof("load sessions failed: %v", err)
+	}
+	s.names = &wexin.NameResolver{Contacts: s.Contacts, ChatRooms: s.ChatRooms}
+
+	// 解码图片前先推算一次异或 key，推算不出时逐个文件推算
+	if a.DataDir == "" {
+		return s, nil
+	}
+	if a.Version == 4 {
+		_, err = a.GetImageXorKeyV4()
+	} else {
+		_, err = a.GetImageXorKeyV3()
+	}
+	if err != nil {
+		logrus.Infof("get image xor key failed: %v", err)
+	}
+	return s, nil
+}
+
+// Close 关闭消息库
+func (s *Source) Close() error {
+	return s.store.Close()
+}
+
+// DisplayName username 的显示名
+func (s *Source) DisplayName(username string) string {
+	if s.Contacts == nil {
+		return username
+	}
+	return s.Contacts.DisplayName(username)
+}
+
+// AvatarURL username 的头像地址，没有联系人记录时为空
+func (s *Source) AvatarURL(username string) string {
+	if s.Contacts == nil || username == "" {
+		return ""
+	}
+	if c, ok := s.Contacts.Get(username); ok {
+		return c.AvatarURL()
+	}
+	return ""
+}
+
+// Chats 要导出的会话，顺序与客户端一致；没有会话列表时从消息中收集
+func (s *Source) Chats() ([]*wexin.Session, error) {
+	sessions := s.Sessions
+	if len(sessions) == 0 {
+		var err error
+		if sessions, err = s.sessionsFromMessages(); err != nil {
+			return nil, err
+		}
+	}
+	if len(s.Talkers) == 0 {
+		return sessions, nil
+	}
+	want := make(map[string]bool, len(s.Talkers))
+	for _, t := range s.Talkers {
+		want[t] = true
+	}
+	var chats []*wexin.Session
+	for _, sess := range sessions {
+		if want[sess.Username] {
+			chats = append(chats, sess)
+			delete(want, sess.Username)
+		}
+	}
+	// 会话列表里没有（已删除）的会话
+	for _, t := range s.Talkers {
+		if want[t] {
+			chats = append(chats, &wexin.Session{Username: t, DisplayName: s.DisplayName(t)})
+		}
+	}
+	return chats, nil
+}
+
+func (s *Source) sessionsFromMessages() ([]*wexin.Session, error) {
+	it, err := s.store.Messages("")
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	byTalker := make(map[string]*wexin.Session)
+	for it.Next() {
+		m := it.Message()
+		sess, ok := byTalker[m.Talker]
+		if !ok {
+			sess = &wexin.Session{Username: m.Talker, DisplayName: s.DisplayName(m.Talker)}
+			byTalker[m.Talker] = sess
+		}
+		sess.LastTime = m.CreateTime
+		sess.LastMsgType = m.Type
+		sess.Summary = Describe(m)
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	sessions := make([]*wexin.Session, 0, len(byTalker))
+	for _, sess := range byTalker {
+		sessions = append(sessions, sess)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastTime.After(sessions[j].LastTime) })
+	return sessions, nil
+}
+
+// Messages 一个会话的所有消息：填充发送者显示名、关联引用回复、展开合并转发
+func (s *Source) Messages(talker string) ([]*wexin.Message, error) {
+	msgs, err := wexin.CollectMessages(s.store, talker)
+	if err != nil {
+		return nil, fmt.Errorf("read messages of %s failed: %v", talker, err)
+	}
+	for _, m := range msgs {
+		s.names.Annotate(m)
+		if m.Type == wexin.MsgTypeApp && m.SubType == wexin.AppMsgRecord {
+			if err := s.Account.ExpandRecord(m); err != nil {
+				logrus.Debugf("expand record %d of %s failed: %v", m.LocalID, talker, err)
+			}
+		}
+	}
+	wexin.LinkReplies(msgs)
+	return msgs, nil
+}
+
+var (
+	unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_@.\-]`)
+	// Windows 的设备名不能做文件名，带扩展名也不行
+	windowsReservedName = regexp.MustCompile(`(?i)^(CON|PRN|AUX|NUL|COM[0-9]|LPT[0-9])(\.|$)`)
+)
+
+// safeName 把 username 转成可以做文件名的字符串
+func safeName(username string) string {
+	if username == "" {
+		return "unknown"
+	}
+	return avoidReservedName(unsafeFileChars.ReplaceAllString(username, "_"))
+}
+
+// safeFileName 按 Windows 的规则清理任意文本做文件名：替换 <>:"/\|?* 和控制字符，
+// 去掉结尾的点和空格，设备名前加 _，清理后为空时返回空串
+func safeFileName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>:"/\|?*`, r) || r < 0x20 || r == 0x7f {
+			return '_'
+		}
+		return r
+	}, s)
+	s = strings.TrimRight(strings.TrimSpace(s), ". ")
+	if s == "" {
+		return ""
+	}
+	return avoidReservedName(s)
+}
+
+func avoidReservedName(s string) string {
+	if windowsReservedName.MatchString(s) {
+		return "_" + s
+	}
+	return s
+}
@@ -0,0 +1,190 @@
+package export
+
+import (
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saucer-man/wxdump/pkg/wexin"
+)
+
+// createDB 在 path 创建明文库并执行 stmts
+func createDB(t *testing.T, path string, stmts ...string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, s := range stmts {
+		if _, err := db.Exec(s); err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+	}
+}
+
+const (
+	testQuoteXML  = `<msg><appmsg><title>回复你好</title><type>57</type><refermsg><svrid>11</svrid><displayname>Me</displayname><content>hi</content></refermsg></appmsg></msg>`
+	testRecordXML = `<msg><appmsg><title>聊天记录</title><type>19</type><recorditem><![CDATA[<recordinfo><datalist><dataitem datatype="1"><datadesc>inner text</datadesc><sourcename>X</sourcename></dataitem></datalist></recordinfo>]]></recorditem></appmsg></msg>`
+	testPayXML    = `<msg><appmsg><type>2000</type><wcpayinfo><feedesc>￥12.00</feedesc></wcpayinfo></appmsg></msg>`
+)
+
+// sourceDirV3 和 sourceDirV4 构造内容相同的解密目录：与 wxid_a 的 5 条消息，
+// 依次为文本、引用、合并转发、转账和系统消息
+func sourceDirV3(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	createDB(t, filepath.Join(dir, "wxid_me", "Msg", "Multi", "MSG0.db"),
+		`CREATE TABLE MSG(localId INTEGER PRIMARY KEY, TalkerId INT, MsgSvrID INT, Type INT, SubType INT, IsSender INT, CreateTime INT,
+			Sequence INT, Status INT, StrTalker TEXT, StrContent TEXT, CompressContent BLOB, BytesExtra BLOB)`,
+		`INSERT INTO MSG VALUES (1, 0, 11, 1, 0, 1, 100, 100000, 2, 'wxid_a', 'hi <b>', NULL, NULL),
+			(2, 0, 12, 49, 57, 0, 200, 200000, 2, 'wxid_a', '`+testQuoteXML+`', NULL, NULL),
+			(3, 0, 13, 49, 19, 0, 300, 300000, 2, 'wxid_a', '`+testRecordXML+`', NULL, NULL),
+			(4, 0, 14, 49, 2000, 0, 400, 400000, 2, 'wxid_a', '`+testPayXML+`', NULL, NULL),
+			(5, 0, 15, 10000, 0, 0, 500, 500000, 2, 'wxid_a', '你撤回了一条消息', NULL, NULL)`)
+	createDB(t, filepath.Join(dir, "wxid_me", "Msg", "MicroMsg.db"),
+		`CREATE TABLE Contact(UserName TEXT, Alias TEXT, Type INT, VerifyFlag INT, Remark TEXT, NickName TEXT, LabelIDList TEXT,
+			QuanPin TEXT, RemarkQuanPin TEXT, BigHeadImgUrl TEXT, SmallHeadImgUrl TEXT, ExtraBuf BLOB)`,
+		`CREATE TABLE ContactHeadImgUrl(usrName TEXT, smallHeadImgUrl TEXT, bigHeadImgUrl TEXT)`,
+		`CREATE TABLE ContactLabel(LabelId INT, LabelName TEXT)`,
+		`INSERT INTO Contact(UserName, Alias, Type, VerifyFlag, Remark, NickName) VALUES
+			('wxid_me', '', 1, 0, '', 'Me'), ('wxid_a', '', 2049, 0, '', 'Alice')`,
+		`INSERT INTO ContactHeadImgUrl VALUES ('wxid_a', 'http://small/a', 'http://big/a')`,
+		`CREATE TABLE Session(strUsrName TEXT, nOrder INT, nUnReadCount INT, strNickName TEXT, nStatus INT, nIsSend INT,
+			strContent TEXT, nMsgType INT, nMsgLocalID INT, nTime INT)`,
+		`INSERT INTO Session VALUES ('wxid_a', 500, 1, '', 0, 0, '你撤回了一条消息', 10000, 5, 500)`)
+	return dir
+}
+
+func sourceDirV4(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	table, insert := msgTableV4("wxid_a")
+	createDB(t, filepath.Join(dir, "wxid_me", "message", "message_0.db"),
+		`CREATE TABLE Name2Id(user_name TEXT PRIMARY KEY, is_session INTEGER)`,
+		`INSERT INTO Name2Id(user_name) VALUES ('wxid_me'), ('wxid_a')`,
+		table,
+		insert(11, 1, 100, 1, "hi <b>"),
+		insert(12, 57<<32|49, 200, 2, testQuoteXML),
+		insert(13, 19<<32|49, 300, 2, testRecordXML),
+		insert(14, 2000<<32|49, 400, 2, testPayXML),
+		insert(15, 10000, 500, 2, "你撤回了一条消息"))
+	createDB(t, filepath.Join(dir, "wxid_me", "contact", "contact.db"),
+		`CREATE TABLE contact(id INTEGER PRIMARY KEY, username TEXT, local_type INTEGER, alias TEXT, remark TEXT, remark_quan_pin TEXT,
+			nick_name TEXT, quan_pin TEXT, big_head_url TEXT, small_head_url TEXT, description TEXT, extra_buffer BLOB, flag INTEGER,
+			verify_flag INTEGER)`,
+		`INSERT INTO contact(username, local_type, alias, remark, nick_name, big_head_url, small_head_url, description, flag, verify_flag)
+			VALUES ('wxid_me', 1, '', '', 'Me', '', '', '', 3, 0), ('wxid_a', 1, '', '', 'Alice', 'http://big/a', 'http://small/a', '', 3, 0)`)
+	createDB(t, filepath.Join(dir, "wxid_me", "session", "session.db"),
+		`CREATE TABLE SessionTable(username TEXT, summary TEXT, last_timestamp INTEGER, sort_timestamp INTEGER, unread_count INTEGER,
+			is_hidden INTEGER, last_msg_type INTEGER, last_msg_sender TEXT)`,
+		`INSERT INTO SessionTable VALUES ('wxid_a', '你撤回了一条消息', 500, 500, 1, 0, 10000, 'wxid_a')`)
+	return dir
+}
+
+func openSourceV3(t *testing.T) *Source {
+	return openTestSource(t, &wexin.Account{Version: 3, Wxid: "wxid_me"}, sourceDirV3(t))
+}
+
+func openSourceV4(t *testing.T) *Source {
+	return openTestSource(t, &wexin.Account{Version: 4, Wxid: "wxid_me"}, sourceDirV4(t))
+}
+
+// msgTableV4 返回 talker 的 v4 消息表的建表语句和生成插入语句的函数，sender 为 Name2Id 的 rowid
+func msgTableV4(talker string) (string, func(serverID, localType, createTime, sender int64, content string) string) {
+	sum := md5.Sum([]byte(talker))
+	table := "Msg_" + hex.EncodeToString(sum[:])
+	create := `CREATE TABLE "` + table + `"(local_id INTEGER PRIMARY KEY AUTOINCREMENT, server_id INTEGER, local_type INTEGER,
+		sort_seq INTEGER, real_sender_id INTEGER, create_time INTEGER, status INTEGER, message_content TEXT, compress_content TEXT,
+		packed_info_data BLOB)`
+	return create, func(serverID, localType, createTime, sender int64, content string) string {
+		return fmt.Sprintf(`INSERT INTO "%s"(server_id, local_type, sort_seq, real_sender_id, create_time, status, message_content)
+			VALUES (%d, %d, %d, %d, %d, 2, '%s')`, table, serverID, localType, createTime*1000, sender, createTime, content)
+	}
+}
+
+func openTestSource(t *testing.T, a *wexin.Account, dir string) *Source {
+	t.Helper()
+	src, err := Open(a, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { src.Close() })
+	return src
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestExportHTML(t *testing.T) {
+	out := t.TempDir()
+	if err := ExportHTML(openSourceV3(t), out); err != nil {
+		t.Fatal(err)
+	}
+	page := readFile(t, filepath.Join(out, "chats", "wxid_a.html"))
+	for _, want := range []string{"hi &lt;b&gt;", `href="#m11"`, "inner text", "[转账] ￥12.00", "你撤回了一条消息", "Alice", `src="http://big/a"`} {
+		if !strings.Contains(page, want) {
+			t.Errorf("chat page missing %q", want)
+		}
+	}
+	if index := readFile(t, filepath.Join(out, "index.html")); !strings.Contains(index, "chats/wxid_a.html") {
+		t.Error("index doesn't link the chat page")
+	}
+}
+
+func TestSafeFileName(t *testing.T) {
+	for in, want := range map[string]string{
+		"a:b.txt":    "a_b.txt",
+		"../x":       ".._x",
+		"..":         "",
+		"CON":        "_CON",
+		"con.txt":    "_con.txt",
+		"COM1.log":   "_COM1.log",
+		"console.md": "console.md",
+		"a?b* ":      "a_b_",
+	} {
+		if got := safeFileName(in); got != want {
+			t.Errorf("safeFileName(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := escapePath("media/u/file/1_a #1?.txt"); got != "media/u/file/1_a%20%231%3F.txt" {
+		t.Errorf("escapePath = %q", got)
+	}
+}
+
+func TestExportVoice(t *testing.T) {
+	dir := t.TempDir()
+	createDB(t, filepath.Join(dir, "wxid_me", "Msg", "Multi", "MediaMSG0.db"),
+		`CREATE TABLE Media(Key TEXT, Reserved0 INT, Buf BLOB)`,
+		`INSERT INTO Media VALUES ('a', 21, x'02232153494c4b5f56330a006162'), ('b', 22, x'00')`)
+	src := &Source{Account: &wexin.Account{Version: 3, Wxid: "wxid_me"}, DecryptedDir: dir}
+	out := t.TempDir()
+	w := newMediaWriter(src, out, "media")
+
+	// 不是完整的 SILK，解码失败时导出原始数据
+	f := w.export(&wexin.Message{Type: wexin.MsgTypeVoice, ServerID: 21, Talker: "wxid_a", CreateTime: time.Unix(100, 0)})
+	if f == nil || f.Kind != MediaVoice || !strings.HasSuffix(f.Path, ".silk") {
+		t.Fatalf("voice = %+v", f)
+	}
+	if got := readFile(t, filepath.Join(out, filepath.FromSlash(f.Path))); got != "\x02#!SILK_V3\n\x00ab" {
+		t.Errorf("voice data = %q", got)
+	}
+	if f := w.export(&wexin.Message{Type: wexin.MsgTypeVoice, ServerID: 99, Talker: "wxid_a"}); f != nil {
+		t.Errorf("unknown voice = %+v, want nil", f)
+	}
+}
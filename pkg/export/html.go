@@ -0,0 +1,259 @@
+package export
+
+import (
+	"fmt"
+	"hash/fnv"
+	"html/template"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/saucer-man/wxdump/pkg/wexin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	htmlChatDir  = "chats"
+	htmlMediaDir = "media"
+	htmlTimeFmt  = "2006-01-02 15:04:05"
+)
+
+var avatarColors = []string{"#5b8ff9", "#5ad8a6", "#f6bd16", "#e8684a", "#6dc8ec", "#9270ca", "#ff9d4d", "#269a99"}
+
+type htmlQuote struct {
+	Anchor string // 原消息在页面中的锚点，原消息不存在时为空
+	Sender string
+	Text   string
+}
+
+type htmlMessage struct {
+	Anchor      string
+	Self        bool
+	System      bool
+	Sender      string
+	Avatar      string // 名字的第一个字，没有头像或头像加载失败时显示
+	AvatarColor string
+	AvatarURL   string
+	Time        string
+	Text        string
+	Media       *Media
+	MediaSrc    string
+	Quote       *htmlQuote
+	RecordTitle string
+	Record      []*htmlMessage
+	Replies     int
+}
+
+type htmlChat struct {
+	Title    string
+	Username string
+	Messages []*htmlMessage
+}
+
+type htmlIndexItem struct {
+	Href     string
+	Name     string
+	Username string
+	LastTime string
+	Summary  string
+	Unread   int64
+	Sticky   bool
+	Muted    bool
+	Count    int
+}
+
+type htmlIndex struct {
+	Title string
+	Chats []htmlIndexItem
+}
+
+// ExportHTML 每个会话导出一个 HTML 页面（chats/<username>.html），图片、语音、文件导出到 media/，
+// 并生成会话列表 index.html；页面之间都是相对链接，可以打包后离线查看
+func ExportHTML(src *Source, outDir string) error {
+	chats, err := src.Chats()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(outDir, htmlChatDir), 0755); err != nil {
+		return err
+	}
+	media := newMediaWriter(src, outDir, htmlMediaDir)
+	index := htmlIndex{Title: fmt.Sprintf("%s 的聊天记录", src.DisplayName(src.Account.Wxid))}
+	for _, sess := range chats {
+		msgs, err := src.Messages(sess.Username)
+		if err != nil {
+			logrus.Infof("export %s failed: %v", sess.Username, err)
+			continue
+		}
+		page := filepath.Join(htmlChatDir, safeName(sess.Username)+".html")
+		chat := htmlChat{Title: sess.DisplayName, Username: sess.Username}
+		for _, m := range msgs {
+			chat.Messages = append(chat.Messages, newHTMLMessage(src, m, media))
+		}
+		if err := writeTemplate(filepath.Join(outDir, page), "chat", chat); err != nil {
+			return err
+		}
+		index.Chats = append(index.Chats, htmlIndexItem{
+			Href:     filepath.ToSlash(page),
+			Name:     sess.DisplayName,
+			Username: sess.Username,
+			LastTime: formatTime(sess.LastTime),
+			Summary:  sess.Summary,
+			Unread:   sess.Unread,
+			Sticky:   sess.Sticky,
+			Muted:    sess.Muted,
+			Count:    len(msgs),
+		})
+		logrus.Infof("[HTML] %s: %d messages", sess.DisplayName, len(msgs))
+	}
+	return writeTemplate(filepath.Join(outDir, "index.html"), "index", index)
+}
+
+// escapePath 对 / 分隔的相对路径逐段做百分号编码，文件名中的 #、?、% 不会被当成 URL 的一部分
+func escapePath(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+func newHTMLMessage(src *Source, m *wexin.Message, media *mediaWriter) *htmlMessage {
+	hm := &htmlMessage{
+		Anchor: messageAnchor(m),
+		Self:   m.IsSender,
+		System: m.Type == wexin.MsgTypeSystem || m.Type == wexin.MsgTypeRevoke,
+		Sender: m.SenderName,
+		Time:   formatTime(m.CreateTime),
+		Text:   Describe(m),
+	}
+	if hm.Sender == "" {
+		hm.Sender = m.Sender
+	}
+	hm.Avatar, hm.AvatarColor = avatar(hm.Sender)
+	hm.AvatarURL = src.AvatarURL(m.Sender)
+	if f := media.export(m); f != nil {
+		hm.Media = f
+		hm.MediaSrc = "../" + escapePath(f.Path)
+	}
+	if m.ReplyTo != nil {
+		hm.Quote = &htmlQuote{Anchor: messageAnchor(m.ReplyTo), Sender: m.ReplyTo.SenderName, Text: Describe(m.ReplyTo)}
+	} else if m.Type == wexin.MsgTypeApp && m.SubType == wexin.AppMsgQuote {
+		if app, err := m.AppMsg(); err == nil {
+			if q, ok := app.(*wexin.QuoteMsg); ok {
+				hm.Quote = &htmlQuote{Sender: q.Refer.DisplayName, Text: q.Refer.Content}
+			}
+		}
+	}
+	if len(m.Children) > 0 {
+		hm.RecordTitle = hm.Text
+		for _, c := range m.Children {
+			hm.Record = append(hm.Record, newHTMLMessage(src, c, media))
+		}
+	}
+	hm.Replies = len(m.Replies)
+	return hm
+}
+
+// messageAnchor 消息在页面中的锚点，引用回复通过它跳转到原消息
+func messageAnchor(m *wexin.Message) string {
+	if m.ServerID != 0 {
+		return fmt.Sprintf("m%d", m.ServerID)
+	}
+	if m.LocalID != 0 {
+		return fmt.Sprintf("l%d", m.LocalID)
+	}
+	return ""
+}
+
+// avatar 头像的占位：名字的第一个字和固定的颜色。头像地址是微信的 CDN，离线或过期时显示占位
+func avatar(name string) (string, string) {
+	r := []rune(strings.TrimSpace(name))
+	if len(r) == 0 {
+		return "?", avatarColors[0]
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return strings.ToUpper(string(r[0])), avatarColors[(h.Sum32()>>16)%uint32(len(avatarColors))]
+}
+
+func writeTemplate(path, name string, data any) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return htmlTemplates.ExecuteTemplate(f, name, data)
+}
+
+var htmlTemplates = template.Must(template.New("").Funcs(template.FuncMap{
+	"hasSuffix": strings.HasSuffix,
+}).Parse(htmlTemplateText))
+
+const htmlTemplateText = `
+{{define "style"}}<style>
+body{margin:0;background:#ededed;font:14px/1.5 -apple-system,"Microsoft YaHei",sans-serif;color:#191919}
+header{position:sticky;top:0;background:#f7f7f7;border-bottom:1px solid #ddd;padding:10px 16px;font-size:16px;z-index:1}
+header a{color:#576b95;text-decoration:none;margin-right:12px}
+main{max-width:860px;margin:0 auto;padding:12px}
+.msg{display:flex;margin:12px 0;align-items:flex-start}
+.msg.self{flex-direction:row-reverse}
+.avatar{flex:none;position:relative;overflow:hidden;width:36px;height:36px;border-radius:4px;color:#fff;text-align:center;line-height:36px;font-size:16px}
+.avatar img{position:absolute;left:0;top:0;width:100%;height:100%}
+.body{max-width:70%;margin:0 10px}
+.self .body{text-align:right}
+.meta{font-size:12px;color:#999}
+.bubble{display:inline-block;text-align:left;background:#fff;border-radius:4px;padding:8px 10px;white-space:pre-wrap;word-break:break-word}
+.self .bubble{background:#95ec69}
+.bubble img,.bubble video{max-width:300px;max-height:300px;display:block}
+.system{text-align:center;color:#999;font-size:12px;margin:12px 0}
+.quote{margin-top:4px;font-size:12px;color:#666;background:#e2e2e2;border-radius:4px;padding:4px 8px;display:inline-block;text-align:left}
+.quote a{color:#666}
+.record{background:#f7f7f7;border-radius:4px;margin-top:4px}
+.record summary{cursor:pointer;padding:6px 10px}
+.record .msg{margin:8px}
+:target .bubble{outline:2px solid #fa9d3b}
+table{border-collapse:collapse;width:100%;background:#fff}
+td,th{border-bottom:1px solid #eee;padding:8px;text-align:left}
+td.summary{color:#888;max-width:360px;overflow:hidden;text-overflow:ellipsis;white-space:nowrap}
+.tag{font-size:12px;color:#fff;background:#fa5151;border-radius:8px;padding:0 6px}
+</style>{{end}}
+
+{{define "media"}}{{if eq .Media.Kind "image"}}<a href="{{.MediaSrc}}"><img src="{{.MediaSrc}}" loading="lazy"></a>
+{{- else if eq .Media.Kind "voice"}}{{if hasSuffix .MediaSrc ".wav"}}<audio controls preload="none" src="{{.MediaSrc}}"></audio>{{else}}<a href="{{.MediaSrc}}">[语音] SILK</a>{{end}}
+{{- else if eq .Media.Kind "video"}}<video controls preload="none" src="{{.MediaSrc}}"></video>
+{{- else}}<a href="{{.MediaSrc}}" download>{{if .Media.Name}}{{.Media.Name}}{{else}}{{.Text}}{{end}}</a> <span class="meta">{{.Media.Size}} B</span>{{end}}{{end}}
+
+{{define "message"}}{{if .System}}<div class="system"{{if .Anchor}} id="{{.Anchor}}"{{end}}>{{.Time}} {{.Text}}</div>
+{{- else}}<div class="msg{{if .Self}} self{{end}}"{{if .Anchor}} id="{{.Anchor}}"{{end}}>
+<div class="avatar" style="background:{{.AvatarColor}}">{{.Avatar}}{{if .AvatarURL}}<img src="{{.AvatarURL}}" alt="" loading="lazy" referrerpolicy="no-referrer" onerror="this.remove()">{{end}}</div>
+<div class="body"><div class="meta">{{.Sender}} {{.Time}}{{if .Replies}} · {{.Replies}} 条回复{{end}}</div>
+<div class="bubble">{{if .Media}}{{template "media" .}}{{else if .Record}}<details class="record"><summary>{{.RecordTitle}}</summary>{{range .Record}}{{template "message" .}}{{end}}</details>{{else}}{{.Text}}{{end}}</div>
+{{- with .Quote}}<div class="quote">{{if .Anchor}}<a href="#{{.Anchor}}">{{.Sender}}: {{.Text}}</a>{{else}}{{.Sender}}: {{.Text}}{{end}}</div>{{end}}
+</div></div>{{end}}
+{{end}}
+
+{{define "chat"}}<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Title}}</title>{{template "style"}}</head>
+<body><header><a href="../index.html">&larr; 会话列表</a>{{.Title}} <span class="meta">{{.Username}}</span></header>
+<main>{{range .Messages}}{{template "message" .}}{{end}}</main></body></html>
+{{end}}
+
+{{define "index"}}<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Title}}</title>{{template "style"}}</head>
+<body><header>{{.Title}}</header>
+<main><table><tr><th>会话</th><th>最后消息</th><th>时间</th><th>消息数</th></tr>
+{{range .Chats}}<tr><td><a href="{{.Href}}">{{.Name}}</a>{{if .Sticky}} 📌{{end}}{{if .Muted}} 🔕{{end}}{{if .Unread}} <span class="tag">{{.Unread}}</span>{{end}}<div class="meta">{{.Username}}</div></td>
+<td class="summary">{{.Summary}}</td><td>{{.LastTime}}</td><td>{{.Count}}</td></tr>
+{{end}}</table></main></body></html>
+{{end}}
+`
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(htmlTimeFmt)
+}
@@ -0,0 +1,209 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/saucer-man/wxdump/pkg/wexin"
+	"github.com/sirupsen/logrus"
+)
+
+// JSONLSchemaVersion JSON Lines 的格式版本，字段有不兼容的变化时加一
+const JSONLSchemaVersion = 1
+
+const (
+	JSONLFile     = "messages.jsonl"
+	jsonlMediaDir = "media"
+)
+
+// JSONLMessage messages.jsonl 中的一行，字段说明：
+//
+//	schema       格式版本，见 JSONLSchemaVersion
+//	wechat       微信版本：3 或 4
+//	chat_id      会话 username，群聊为 xxx@chatroom
+//	chat_name    会话显示名（备注 > 昵称 > 微信号）
+//	server_id    消息的服务端 ID（字符串，避免超出 JS 的整数精度），合并转发中的消息为空
+//	local_id     消息在本地库中的 ID
+//	sender       发送者 username，sender_name 为显示名（群聊中优先群昵称）
+//	time         RFC3339 时间，unix 为秒级时间戳
+//	type         消息类型（v3 Type / v4 local_type 低 32 位），type_name 为可读名称
+//	sub_type     子类型，appmsg 为 <appmsg><type>
+//	text         可读文本，非文本消息为 "[图片]" 这样的描述
+//	app          appmsg 解析结果：kind 为类型名称，payload 为 wexin 包中对应结构体（LinkMsg、QuoteMsg 等）
+//	media        导出的媒体文件，path 为相对导出目录的路径
+//	reply_to     引用回复所引用消息的 server_id
+//	record       合并转发展开后的消息，结构相同
+type JSONLMessage struct {
+	Schema     int             `json:"schema,omitempty"`
+	WeChat     int             `json:"wechat"`
+	ChatID     string          `json:"chat_id"`
+	ChatName   string          `json:"chat_name"`
+	IsChatRoom bool            `json:"is_chatroom"`
+	ServerID   int64           `json:"server_id,omitempty,string"`
+	LocalID    int64           `json:"local_id,omitempty"`
+	Sender     string          `json:"sender"`
+	SenderName string          `json:"sender_name"`
+	IsSelf     bool            `json:"is_self"`
+	Time       string          `json:"time"`
+	Unix       int64           `json:"unix"`
+	Type       int64           `json:"type"`
+	TypeName   string          `json:"type_name"`
+	SubType    int64           `json:"sub_type"`
+	Text       string          `json:"text"`
+	App        *JSONLApp       `json:"app,omitempty"`
+	Media      []*Media        `json:"media,omitempty"`
+	ReplyTo    int64           `json:"reply_to,omitempty,string"`
+	Record     []*JSONLMessage `json:"record,omitempty"`
+}
+
+// JSONLApp appmsg 的解析结果
+type JSONLApp struct {
+	Type    int              `json:"type"`
+	Kind    string           `json:"kind"`
+	Payload wexin.AppMessage `json:"payload"`
+}
+
+var msgTypeNames = map[int64]string{
+	wexin.MsgTypeText:     "text",
+	wexin.MsgTypeImage:    "image",
+	wexin.MsgTypeVoice:    "voice",
+	wexin.MsgTypeCard:     "card",
+	wexin.MsgTypeVideo:    "video",
+	wexin.MsgTypeEmoji:    "emoji",
+	wexin.MsgTypeLocation: "location",
+	wexin.MsgTypeApp:      "app",
+	wexin.MsgTypeVoip:     "voip",
+	wexin.MsgTypeSystem:   "system",
+	wexin.MsgTypeRevoke:   "revoke",
+}
+
+// MsgTypeName 消息类型的名称，未知类型为 unknown
+func MsgTypeName(t int64) string {
+	if name, ok := msgTypeNames[t]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// AppKind appmsg 类型的名称
+func AppKind(app wexin.AppMessage) string {
+	switch app.(type) {
+	case *wexin.TextAppMsg:
+		return "text"
+	case *wexin.LinkMsg:
+		return "link"
+	case *wexin.FileMsg:
+		return "file"
+	case *wexin.MusicMsg:
+		return "music"
+	case *wexin.MiniProgramMsg:
+		return "miniprogram"
+	case *wexin.QuoteMsg:
+		return "quote"
+	case *wexin.RecordMsg:
+		return "record"
+	case *wexin.TransferMsg:
+		return "transfer"
+	case *wexin.RedPacketMsg:
+		return "redpacket"
+	case *wexin.LocationShareMsg:
+		return "location_share"
+	case *wexin.ChannelsMsg:
+		return "channels"
+	case *wexin.PatMsg:
+		return "pat"
+	}
+	return "unknown"
+}
+
+// ExportJSONL 把所有会话的消息按会话、时间顺序写入 outDir/messages.jsonl，每行一条消息，
+// 媒体文件导出到 outDir/media/
+func ExportJSONL(src *Source, outDir string) error {
+	chats, err := src.Chats()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(filepath.Join(outDir, JSONLFile))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	media := newMediaWriter(src, outDir, jsonlMediaDir)
+	total := 0
+	for _, sess := range chats {
+		msgs, err := src.Messages(sess.Username)
+		if err != nil {
+			logrus.Infof("export %s failed: %v", sess.Username, err)
+			continue
+		}
+		for _, m := range msgs {
+			line := newJSONLMessage(m, sess, media)
+			line.Schema = JSONLSchemaVersion
+			if err := enc.Encode(line); err != nil {
+				return err
+			}
+		}
+		total += len(msgs)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	logrus.Infof("[JSONL] %d chats, %d messages: %s", len(chats), total, f.Name())
+	return nil
+}
+
+func newJSONLMessage(m *wexin.Message, sess *wexin.Session, media *mediaWriter) *JSONLMessage {
+	jm := &JSONLMessage{
+		WeChat:     m.Version,
+		ChatID:     sess.Username,
+		ChatName:   sess.DisplayName,
+		IsChatRoom: m.IsChatRoom(),
+		ServerID:   m.ServerID,
+		LocalID:    m.LocalID,
+		Sender:     m.Sender,
+		SenderName: m.SenderName,
+		IsSelf:     m.IsSender,
+		Type:       m.Type,
+		TypeName:   MsgTypeName(m.Type),
+		SubType:    m.SubType,
+		Text:       Describe(m),
+	}
+	if !m.CreateTime.IsZero() {
+		jm.Time = m.CreateTime.Format(time.RFC3339)
+		jm.Unix = m.CreateTime.Unix()
+	}
+	if m.Type == wexin.MsgTypeApp && m.XML() != "" {
+		if app, err := m.AppMsg(); err == nil {
+			jm.App = &JSONLApp{Type: app.AppType(), Kind: AppKind(app), Payload: app}
+		}
+	}
+	if f := media.export(m); f != nil {
+		jm.Media = append(jm.Media, f)
+	}
+	if m.ReplyTo != nil {
+		jm.ReplyTo = m.ReplyTo.ServerID
+	} else if q, ok := jm.appPayload().(*wexin.QuoteMsg); ok {
+		jm.ReplyTo = q.Refer.SvrID
+	}
+	for _, c := range m.Children {
+		jm.Record = append(jm.Record, newJSONLMessage(c, sess, media))
+	}
+	return jm
+}
+
+func (jm *JSONLMessage) appPayload() wexin.AppMessage {
+	if jm.App == nil {
+		return nil
+	}
+	return jm.App.Payload
+}
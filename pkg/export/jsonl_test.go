@@ -0,0 +1,75 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// exportJSONL 导出并逐行解析
+func exportJSONL(t *testing.T, src *Source) []map[string]any {
+	t.Helper()
+	out := t.TempDir()
+	if err := ExportJSONL(src, out); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filepath.Join(out, JSONLFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []map[string]any
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var m map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			t.Fatalf("%s: %v", sc.Text(), err)
+		}
+		lines = append(lines, m)
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return lines
+}
+
+// dropWeChat 去掉消息及合并转发中的 wechat 版本字段
+func dropWeChat(m map[string]any) {
+	delete(m, "wechat")
+	items, _ := m["record"].([]any)
+	for _, item := range items {
+		dropWeChat(item.(map[string]any))
+	}
+}
+
+func TestExportJSONL(t *testing.T) {
+	v3, v4 := exportJSONL(t, openSourceV3(t)), exportJSONL(t, openSourceV4(t))
+	if len(v3) != 5 || len(v4) != 5 {
+		t.Fatalf("got %d and %d lines, want 5", len(v3), len(v4))
+	}
+	for i := range v3 {
+		if v3[i]["wechat"] != 3.0 || v4[i]["wechat"] != 4.0 {
+			t.Errorf("line %d: wechat = %v, %v", i, v3[i]["wechat"], v4[i]["wechat"])
+		}
+		dropWeChat(v3[i])
+		dropWeChat(v4[i])
+		if !reflect.DeepEqual(v3[i], v4[i]) {
+			t.Errorf("line %d differs:\nv3 %v\nv4 %v", i, v3[i], v4[i])
+		}
+	}
+
+	first, quote, record := v4[0], v4[1], v4[2]
+	if first["time"] != "1970-01-01T00:01:40Z" || first["unix"] != 100.0 || first["is_self"] != true || first["text"] != "hi <b>" {
+		t.Errorf("text message = %v", first)
+	}
+	if quote["server_id"] != "12" || quote["reply_to"] != "11" || quote["app"].(map[string]any)["kind"] != "quote" {
+		t.Errorf("quote message = %v", quote)
+	}
+	items, _ := record["record"].([]any)
+	if len(items) != 1 || items[0].(map[string]any)["text"] != "inner text" || items[0].(map[string]any)["sender_name"] != "X" {
+		t.Errorf("record = %v", record["record"])
+	}
+}
@@ -0,0 +1,315 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/saucer-man/wxdump/pkg/wexin"
+	"github.com/sirupsen/logrus"
+)
+
+// Markdown 导出的目录结构，适合直接作为 Obsidian vault 打开：
+//
+//	Chats/<会话>.md              会话索引，链接到每天的笔记和群成员
+//	Chats/<会话>/<2024-01-02>.md 每个会话每天一篇笔记
+//	Contacts/<联系人>.md         联系人笔记，链接到出现过的会话
+//	attachments/                 图片、语音、文件
+const (
+	mdChatDir     = "Chats"
+	mdContactDir  = "Contacts"
+	mdMediaDir    = "attachments"
+	mdDateFmt     = "2006-01-02"
+	mdTimeFmt     = "15:04:05"
+	mdUnsafeChars = `[]#^|\/:*?"<>`
+)
+
+// mdVault 记录 Chats/ 和 Contacts/ 下的笔记名以及联系人出现过的会话
+type mdVault struct {
+	src          *Source
+	outDir       string
+	chatNotes    *mdNotes
+	contactNotes *mdNotes
+	contacts     map[string]map[string]bool // username => 出现过的会话 username
+	chatNames    []string
+}
+
+// mdNotes 一个目录下的笔记名（username => 文件名），重名只在同一目录内检查
+type mdNotes struct {
+	names map[string]string
+	used  map[string]bool
+}
+
+func newMdNotes() *mdNotes {
+	return &mdNotes{names: make(map[string]string), used: make(map[string]bool)}
+}
+
+// ExportMarkdown 每个会话每天导出一篇带 YAML front-matter 的 Markdown 笔记，
+// 联系人和会话之间用 wiki-link 互相链接，媒体文件复制到 attachments/
+func ExportMarkdown(src *Source, outDir string) error {
+	chats, err := src.Chats()
+	if err != nil {
+		return err
+	}
+	v := &mdVault{
+		src:          src,
+		outDir:       outDir,
+		chatNotes:    newMdNotes(),
+		contactNotes: newMdNotes(),
+		contacts:     make(map[string]map[string]bool),
+	}
+	media := newMediaWriter(src, outDir, mdMediaDir)
+	for _, sess := range chats {
+		msgs, err := src.Messages(sess.Username)
+		if err != nil {
+			logrus.Infof("export %s failed: %v", sess.Username, err)
+			continue
+		}
+		if err := v.writeChat(sess, msgs, media); err != nil {
+			return err
+		}
+		logrus.Infof("[MARKDOWN] %s: %d messages", sess.DisplayName, len(msgs))
+	}
+	return v.writeContacts()
+}
+
+// chatNote 会话在 Chats/ 下的笔记名
+func (v *mdVault) chatNote(username string) string {
+	return v.chatNotes.name(username, v.src.DisplayName(username))
+}
+
+// contactNote 联系人在 Contacts/ 下的笔记名
+func (v *mdVault) contactNote(username string) string {
+	return v.contactNotes.name(username, v.src.DisplayName(username))
+}
+
+// name username 对应的笔记名：显示名去掉不能做文件名的字符，重名时加上 username
+func (n *mdNotes) name(username, displayName string) string {
+	if name, ok := n.names[username]; ok {
+		return name
+	}
+	name := mdSafeName(displayName)
+	if name == "" || n.used[strings.ToLower(name)] {
+		name = mdSafeName(fmt.Sprintf("%s (%s)", name, username))
+	}
+	n.names[username] = name
+	n.used[strings.ToLower(name)] = true
+	return name
+}
+
+// mdSafeName 替换 Obsidian 链接和 Windows 文件名不允许的字符，去掉开头的点（隐藏文件）、
+// 结尾的点和空格，设备名前加 _
+func mdSafeName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(mdUnsafeChars, r) || r < 0x20 || r == 0x7f {
+			return '_'
+		}
+		return r
+	}, s)
+	s = strings.TrimRight(strings.TrimLeft(strings.TrimSpace(s), "."), ". ")
+	return avoidReservedName(s)
+}
+
+func (v *mdVault) contactLink(username string) string {
+	return mdLink(mdContactDir, v.contactNote(username), v.src.DisplayName(username))
+}
+
+// mdLink 生成 [[dir/note|显示名]]，显示名中的 | 和方括号会破坏 wiki-link
+func mdLink(dir, note, alias string) string {
+	alias = strings.NewReplacer("|", "_", "[", "(", "]", ")").Replace(alias)
+	return fmt.Sprintf("[[%s/%s|%s]]", dir, note, alias)
+}
+
+func (v *mdVault) addParticipant(username, chat string) {
+	if username == "" {
+		return
+	}
+	if v.contacts[username] == nil {
+		v.contacts[username] = make(map[string]bool)
+	}
+	v.contacts[username][chat] = true
+}
+
+func (v *mdVault) writeChat(sess *wexin.Session, msgs []*wexin.Message, media *mediaWriter) error {
+	chatName := v.chatNote(sess.Username)
+	chatDir := filepath.Join(v.outDir, mdChatDir, chatName)
+	if err := os.MkdirAll(chatDir, 0755); err != nil {
+		return err
+	}
+
+	var days []string
+	byDay := make(map[string][]*wexin.Message)
+	for _, m := range msgs {
+		day := m.CreateTime.Format(mdDateFmt)
+		if _, ok := byDay[day]; !ok {
+			days = append(days, day)
+		}
+		byDay[day] = append(byDay[day], m)
+	}
+	if !strings.HasSuffix(sess.Username, "@chatroom") {
+		v.addParticipant(sess.Username, sess.Username)
+	}
+	for _, day := range days {
+		if err := v.writeDay(sess, chatName, day, byDay[day], media); err != nil {
+			return err
+		}
+	}
+
+	// 会话索引
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "---\nchat_id: %s\nmessages: %d\ndays: %d\ntags: [wechat]\n---\n\n# %s\n\n", yamlString(sess.Username), len(msgs), len(days), chatName)
+	if v.src.ChatRooms != nil {
+		if room, ok := v.src.ChatRooms.Get(sess.Username); ok && len(room.Members) > 0 {
+			sb.WriteString("## 群成员\n\n")
+			for _, member := range room.Members {
+				fmt.Fprintf(&sb, "- %s\n", v.contactLink(member.Username))
+			}
+			sb.WriteString("\n")
+		}
+	}
+	sb.WriteString("## 聊天记录\n\n")
+	for _, day := range days {
+		fmt.Fprintf(&sb, "- [[%s/%s/%s|%s]] (%d)\n", mdChatDir, chatName, day, day, len(byDay[day]))
+	}
+	v.chatNames = append(v.chatNames, chatName)
+	return os.WriteFile(filepath.Join(v.outDir, mdChatDir, chatName+".md"), []byte(sb.String()), 0644)
+}
+
+func (v *mdVault) writeDay(sess *wexin.Session, chatName, day string, msgs []*wexin.Message, media *mediaWriter) error {
+	var body strings.Builder
+	participants := make(map[string]bool)
+	mediaCount := 0
+	for _, m := range msgs {
+		if m.Sender != "" {
+			participants[m.Sender] = true
+			v.addParticipant(m.Sender, sess.Username)
+		}
+		mediaCount += v.writeMessage(&body, m, media, "")
+	}
+
+	var names []string
+	for username := range participants {
+		names = append(names, username)
+	}
+	sort.Strings(names)
+	var fm strings.Builder
+	fm.WriteString("---\n")
+	fmt.Fprintf(&fm, "chat: %s\n", yamlString(mdLink(mdChatDir, chatName, chatName)))
+	fmt.Fprintf(&fm, "chat_id: %s\n", yamlString(sess.Username))
+	fmt.Fprintf(&fm, "date: %s\n", day)
+	fm.WriteString("participants:\n")
+	for _, username := range names {
+		fmt.Fprintf(&fm, "  - %s\n", yamlString(v.contactLink(username)))
+	}
+	fmt.Fprintf(&fm, "messages: %d\nmedia: %d\ntags: [wechat]\n---\n\n", len(msgs), mediaCount)
+	fmt.Fprintf(&fm, "# %s %s\n\n", chatName, day)
+
+	return os.WriteFile(filepath.Join(v.outDir, mdChatDir, chatName, day+".md"), []byte(fm.String()+body.String()), 0644)
+}
+
+// writeMessage 写入一条消息，prefix 为合并转发中的引用前缀；返回导出的媒体文件数
+func (v *mdVault) writeMessage(sb *strings.Builder, m *wexin.Message, media *mediaWriter, prefix string) int {
+	sender := m.SenderName
+	if m.Sender != "" {
+		sender = v.contactLink(m.Sender)
+		if m.SenderName != "" && m.SenderName != v.src.DisplayName(m.Sender) {
+			sender = mdLink(mdContactDir, v.contactNote(m.Sender), m.SenderName)
+		}
+	}
+	count := 0
+	fmt.Fprintf(sb, "%s**%s** %s\n", prefix, m.CreateTime.Format(mdTimeFmt), sender)
+	if r := m.ReplyTo; r != nil {
+		// 被引用的消息可能在另一天的笔记里
+		fmt.Fprintf(sb, "%s> 引用 %s: %s ([[%s/%s/%s#^m%d|原消息]])\n%s\n", prefix, r.SenderName, mdQuoted(Describe(r), prefix+"> "),
+			mdChatDir, v.chatNote(r.Talker), r.CreateTime.Format(mdDateFmt), r.ServerID, prefix)
+	}
+
+	text := mdInline(Describe(m))
+	if f := media.export(m); f != nil {
+		count++
+		// 笔记在 Chats/<会话>/ 下，媒体在根目录的 attachments/ 下
+		link := path.Join("..", "..", f.Path)
+		if f.Kind == MediaImage {
+			text = fmt.Sprintf("![%s](%s)", text, mdURL(link))
+		} else {
+			text = fmt.Sprintf("[%s](%s)", text, mdURL(link))
+		}
+	}
+	// block id 放在段落末尾，供引用消息跳转
+	if m.ServerID != 0 {
+		text += fmt.Sprintf(" ^m%d", m.ServerID)
+	}
+	// 多行文本的每一行都要带上引用前缀，否则会跳出合并转发的引用块
+	fmt.Fprintf(sb, "%s%s\n%s\n", prefix, strings.ReplaceAll(text, "\n", "\n"+prefix), prefix)
+	for _, c := range m.Children {
+		count += v.writeMessage(sb, c, media, prefix+"> ")
+	}
+	if len(m.Children) > 0 {
+		fmt.Fprintf(sb, "%s\n", prefix)
+	}
+	return count
+}
+
+// writeContacts 每个出现过的联系人一篇笔记，链接到出现过的会话
+func (v *mdVault) writeContacts() error {
+	dir := filepath.Join(v.outDir, mdContactDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for username, chats := range v.contacts {
+		var sb strings.Builder
+		sb.WriteString("---\n")
+		fmt.Fprintf(&sb, "username: %s\n", yamlString(username))
+		if v.src.Contacts != nil {
+			if c, ok := v.src.Contacts.Get(username); ok {
+				fmt.Fprintf(&sb, "alias: %s\nnickname: %s\nremark: %s\n", yamlString(c.Alias), yamlString(c.Nickname), yamlString(c.Remark))
+				if len(c.Labels) > 0 {
+					fmt.Fprintf(&sb, "labels: [%s]\n", strings.Join(yamlStrings(c.Labels), ", "))
+				}
+			}
+		}
+		sb.WriteString("tags: [wechat/contact]\n---\n\n")
+		fmt.Fprintf(&sb, "# %s\n\n## 会话\n\n", v.src.DisplayName(username))
+		var links []string
+		for chat := range chats {
+			links = append(links, "- "+mdLink(mdChatDir, v.chatNote(chat), v.src.DisplayName(chat))+"\n")
+		}
+		sort.Strings(links)
+		sb.WriteString(strings.Join(links, ""))
+		if err := os.WriteFile(filepath.Join(dir, v.contactNote(username)+".md"), []byte(sb.String()), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mdInline 转义会被当作 HTML 的尖括号，多行文本在 Markdown 中保持换行
+func mdInline(s string) string {
+	s = strings.NewReplacer("<", "\\<", ">", "\\>").Replace(strings.TrimRight(s, "\n"))
+	return strings.ReplaceAll(s, "\n", "  \n")
+}
+
+// mdQuoted 引用块中的多行文本，每一行都加上 prefix
+func mdQuoted(s, prefix string) string {
+	return strings.ReplaceAll(mdInline(s), "\n", "\n"+prefix)
+}
+
+// mdURL Markdown 链接中的路径，空格等字符需要转义
+func mdURL(p string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(p)
+}
+
+func yamlString(s string) string {
+	return fmt.Sprintf("%q", s)
+}
+
+func yamlStrings(list []string) []string {
+	out := make([]string, len(list))
+	for i, s := range list {
+		out[i] = yamlString(s)
+	}
+	return out
+}
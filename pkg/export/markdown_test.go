@@ -0,0 +1,60 @@
+package export
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportMarkdown(t *testing.T) {
+	out := t.TempDir()
+	if err := ExportMarkdown(openSourceV3(t), out); err != nil {
+		t.Fatal(err)
+	}
+	day := readFile(t, filepath.Join(out, mdChatDir, "Alice", "1970-01-01.md"))
+	for _, want := range []string{
+		`chat_id: "wxid_a"`,
+		"messages: 5",
+		"[[Contacts/Me|Me]]",
+		`hi \<b\> ^m11`,
+		"> 引用 Me: hi \\<b\\> ([[Chats/Alice/1970-01-01#^m11|原消息]])",
+		"> inner text",
+		"[转账] ￥12.00 ^m14",
+	} {
+		if !strings.Contains(day, want) {
+			t.Errorf("day note missing %q", want)
+		}
+	}
+	if chat := readFile(t, filepath.Join(out, mdChatDir, "Alice.md")); !strings.Contains(chat, "[[Chats/Alice/1970-01-01|1970-01-01]] (5)") {
+		t.Errorf("chat note doesn't link the day note:\n%s", chat)
+	}
+	if contact := readFile(t, filepath.Join(out, mdContactDir, "Me.md")); !strings.Contains(contact, "- [[Chats/Alice|Alice]]") {
+		t.Errorf("contact note doesn't link the chat:\n%s", contact)
+	}
+}
+
+func TestMdNames(t *testing.T) {
+	for in, want := range map[string]string{
+		"a/b:c?":  "a_b_c_",
+		"[[x]]|#": "__x____",
+		"con.txt": "_con.txt",
+		".x. ":    "x",
+	} {
+		if got := mdSafeName(in); got != want {
+			t.Errorf("mdSafeName(%q) = %q, want %q", in, got, want)
+		}
+	}
+	n := newMdNotes()
+	if a, b := n.name("wxid_a", "Alice"), n.name("wxid_b", "alice"); a != "Alice" || b != "alice (wxid_b)" {
+		t.Errorf("names = %q, %q", a, b)
+	}
+	if got := n.name("wxid_a", "Other"); got != "Alice" {
+		t.Errorf("name is not stable: %q", got)
+	}
+	if got := mdLink("Contacts", "A", "x|[y]"); got != "[[Contacts/A|x_(y)]]" {
+		t.Errorf("mdLink = %q", got)
+	}
+	if got := mdQuoted("a\nb", "> > "); got != "a  \n> > b" {
+		t.Errorf("mdQuoted = %q", got)
+	}
+}
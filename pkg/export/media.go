@@ -0,0 +1,158 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/saucer-man/wxdump/pkg/silk"
+	"github.com/saucer-man/wxdump/pkg/wexin"
+	"github.com/sirupsen/logrus"
+)
+
+// 媒体类型
+const (
+	MediaImage = "image"
+	MediaVoice = "voice"
+	MediaVideo = "video"
+	MediaFile  = "file"
+)
+
+// Media 导出的媒体文件，Path 为相对导出目录的路径（/ 分隔）
+type Media struct {
+	Kind string `json:"kind"`
+	Path string `json:"path"`
+	Name string `json:"name,omitempty"`
+	Size int64  `json:"size"`
+}
+
+// mediaWriter 把消息的图片、语音、视频、文件解码或复制到 outDir/<dir>/<talker>/<kind>/
+type mediaWriter struct {
+	src    *Source
+	outDir string
+	dir    string
+
+	voices *wexin.VoiceIndex // 语音索引，第一次用到时加载
+	seq    int               // 合并转发中的消息没有 ID，用序号区分
+}
+
+func newMediaWriter(src *Source, outDir, dir string) *mediaWriter {
+	return &mediaWriter{src: src, outDir: outDir, dir: dir}
+}
+
+// mediaKind 消息对应的媒体类型，没有媒体时返回空串
+func mediaKind(m *wexin.Message) string {
+	switch {
+	case m.Type == wexin.MsgTypeImage:
+		return MediaImage
+	case m.Type == wexin.MsgTypeVoice:
+		return MediaVoice
+	case m.Type == wexin.MsgTypeVideo:
+		return MediaVideo
+	case m.Type == wexin.MsgTypeApp && m.SubType == wexin.AppMsgFile:
+		return MediaFile
+	}
+	return ""
+}
+
+// export 导出消息的媒体文件，没有媒体或找不到文件时返回 nil
+func (w *mediaWriter) export(m *wexin.Message) *Media {
+	kind := mediaKind(m)
+	if kind == "" {
+		return nil
+	}
+	var (
+		f   *Media
+		err error
+	)
+	if kind == MediaVoice {
+		f, err = w.exportVoice(m)
+	} else {
+		f, err = w.exportFile(m, kind)
+	}
+	if err != nil {
+		logrus.Debugf("export %s of %s %d failed: %v", kind, m.Talker, m.LocalID, err)
+		return nil
+	}
+	return f
+}
+
+func (w *mediaWriter) baseName(m *wexin.Message) string {
+	id := m.ServerID
+	if id == 0 {
+		id = m.LocalID
+	}
+	if id == 0 {
+		w.seq++
+		return fmt.Sprintf("%d_r%d", m.CreateTime.Unix(), w.seq)
+	}
+	return fmt.Sprintf("%d_%d", m.CreateTime.Unix(), id)
+}
+
+func (w *mediaWriter) exportFile(m *wexin.Message, kind string) (*Media, error) {
+	var name string
+	if kind == MediaFile {
+		if app, err := m.AppMsg(); err == nil {
+			if f, ok := app.(*wexin.FileMsg); ok {
+				name = f.Title
+			}
+		} else if m.MediaPath != "" {
+			// 合并转发中的文件
+			name = filepath.Base(m.MediaPath)
+		}
+	}
+	if w.src.Account.DataDir == "" && m.MediaPath == "" {
+		return nil, errors.New("data dir not set")
+	}
+	data, ext, err := w.src.Account.ReadMessageMedia(m)
+	if err != nil {
+		return nil, err
+	}
+	// 文件名前加上消息 ID，避免同名文件互相覆盖；标题清理后只能是当前目录下的文件名
+	base := w.baseName(m)
+	fileName := base + "." + ext
+	if clean := safeFileName(name); clean != "" {
+		fileName = base + "_" + clean
+	}
+	if !filepath.IsLocal(fileName) || filepath.Base(fileName) != fileName {
+		return nil, fmt.Errorf("unsafe file name %q", name)
+	}
+	return w.write(m.Talker, kind, fileName, name, data)
+}
+
+func (w *mediaWriter) exportVoice(m *wexin.Message) (*Media, error) {
+	if w.voices == nil {
+		idx, err := w.src.Account.LoadVoiceIndex(w.src.DecryptedDir)
+		if err != nil {
+			logrus.Infof("load voice index failed: %v", err)
+		}
+		if idx == nil {
+			idx = &wexin.VoiceIndex{}
+		}
+		w.voices = idx
+	}
+	data, err := w.voices.Data(m.ServerID)
+	if err != nil {
+		return nil, err
+	}
+	wav, err := silk.ToWAV(data, silk.SampleRate)
+	if err != nil {
+		// 解码失败时导出原始 SILK
+		logrus.Debugf("decode voice %d failed: %v", m.ServerID, err)
+		return w.write(m.Talker, MediaVoice, w.baseName(m)+".silk", "", data)
+	}
+	return w.write(m.Talker, MediaVoice, w.baseName(m)+".wav", "", wav)
+}
+
+func (w *mediaWriter) write(talker, kind, fileName, name string, data []byte) (*Media, error) {
+	rel := filepath.Join(w.dir, safeName(talker), kind, fileName)
+	dst := filepath.Join(w.outDir, rel)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(dst, data, 0644); err != nil {
+		return nil, err
+	}
+	return &Media{Kind: kind, Path: filepath.ToSlash(rel), Name: name, Size: int64(len(data))}, nil
+}
@@ -0,0 +1,368 @@
+package export
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/saucer-man/wxdump/pkg/wexin"
+	"github.com/sirupsen/logrus"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteSchemaVersion 归档库的表结构版本，记录在 meta 表中
+const SQLiteSchemaVersion = 1
+
+const (
+	SQLiteFile     = "wechat.db"
+	sqliteMediaDir = "media"
+)
+
+// sqliteSchema 归档库的表结构，v3 和 v4 写入相同的表：
+//
+//	contacts      联系人（包括群聊、公众号），labels 为逗号分隔的标签名
+//	chats         导出的会话，messages.chat_id 引用 chats.id；群聊的 created_at、creator 从会话开头的建群系统消息推断，
+//	              本地记录不是从建群开始时为 NULL
+//	chat_members  群成员，display_name 为群昵称
+//	messages      消息，time 为秒级时间戳；合并转发中的消息 parent_id 指向所在的消息，
+//	              reply_to 为引用回复所引用消息的 server_id
+//	media         导出的媒体文件，path 为相对归档库所在目录的路径
+//	sessions      会话列表，sort_order 为客户端的排序值（v4 sort_timestamp、v3 nOrder），越大越靠前；
+//	              chat_id 引用 chats.id，会话没有导出消息时为 NULL
+//	messages_fts  messages.text 的全文索引（trigram 分词，中文关键字至少 3 个字符）
+const sqliteSchema = `
+CREATE TABLE meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT
+);
+CREATE TABLE contacts (
+	username     TEXT PRIMARY KEY,
+	alias        TEXT,
+	nickname     TEXT,
+	remark       TEXT,
+	display_name TEXT,
+	description  TEXT,
+	avatar_url   TEXT,
+	labels       TEXT,
+	is_friend    INTEGER NOT NULL DEFAULT 0,
+	is_chatroom  INTEGER NOT NULL DEFAULT 0,
+	is_official  INTEGER NOT NULL DEFAULT 0,
+	is_blocked   INTEGER NOT NULL DEFAULT 0,
+	is_star      INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE chats (
+	id            INTEGER PRIMARY KEY,
+	username      TEXT NOT NULL UNIQUE,
+	display_name  TEXT,
+	is_chatroom   INTEGER NOT NULL DEFAULT 0,
+	owner         TEXT,
+	announcement  TEXT,
+	created_at    INTEGER,
+	creator       TEXT,
+	message_count INTEGER NOT NULL DEFAULT 0,
+	first_time    INTEGER,
+	last_time     INTEGER
+);
+CREATE TABLE chat_members (
+	chat_id      INTEGER NOT NULL REFERENCES chats(id),
+	username     TEXT NOT NULL,
+	display_name TEXT,
+	PRIMARY KEY (chat_id, username)
+);
+CREATE INDEX chat_members_username ON chat_members(username);
+CREATE TABLE messages (
+	id          INTEGER PRIMARY KEY,
+	chat_id     INTEGER NOT NULL REFERENCES chats(id),
+	parent_id   INTEGER REFERENCES messages(id),
+	server_id   INTEGER,
+	local_id    INTEGER,
+	sender      TEXT,
+	sender_name TEXT,
+	is_self     INTEGER NOT NULL DEFAULT 0,
+	time        INTEGER,
+	type        INTEGER,
+	type_name   TEXT,
+	sub_type    INTEGER,
+	app_kind    TEXT,
+	text        TEXT,
+	content     TEXT,
+	reply_to    INTEGER
+);
+CREATE INDEX messages_chat_time ON messages(chat_id, time);
+CREATE INDEX messages_sender ON messages(sender);
+CREATE INDEX messages_server_id ON messages(server_id);
+CREATE INDEX messages_parent ON messages(parent_id);
+CREATE INDEX messages_type ON messages(type, sub_type);
+CREATE TABLE media (
+	id         INTEGER PRIMARY KEY,
+	message_id INTEGER NOT NULL REFERENCES messages(id),
+	kind       TEXT,
+	path       TEXT,
+	name       TEXT,
+	size       INTEGER
+);
+CREATE INDEX media_message ON media(message_id);
+CREATE TABLE sessions (
+	username      TEXT PRIMARY KEY,
+	chat_id       INTEGER REFERENCES chats(id),
+	display_name  TEXT,
+	summary       TEXT,
+	last_time     INTEGER,
+	last_msg_type INTEGER,
+	last_sender   TEXT,
+	unread        INTEGER,
+	sticky        INTEGER NOT NULL DEFAULT 0,
+	muted         INTEGER NOT NULL DEFAULT 0,
+	hidden        INTEGER NOT NULL DEFAULT 0,
+	sort_order    INTEGER
+);
+CREATE VIRTUAL TABLE messages_fts USING fts5(text, content='messages', content_rowid='id', tokenize='trigram');
+`
+
+// ExportSQLite 把联系人、群成员、会话列表和所有消息写入 outDir/wechat.db，
+// 已存在时重新生成；媒体文件导出到 outDir/media/
+func ExportSQLite(src *Source, outDir string) error {
+	chats, err := src.Chats()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return err
+	}
+	dbPath := filepath.Join(outDir, SQLiteFile)
+	if err := os.Remove(dbPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return fmt.Errorf("create schema: %v", err)
+	}
+
+	w := &sqliteWriter{db: db, src: src, media: newMediaWriter(src, outDir, sqliteMediaDir)}
+	if err := w.writeMeta(); err != nil {
+		return err
+	}
+	if err := w.writeContacts(); err != nil {
+		return fmt.Errorf("write contacts: %v", err)
+	}
+	total := 0
+	for _, sess := range chats {
+		msgs, err := src.Messages(sess.Username)
+		if err != nil {
+			logrus.Infof("export %s failed: %v", sess.Username, err)
+			continue
+		}
+		if err := w.writeChat(sess, msgs); err != nil {
+			return fmt.Errorf("write %s: %v", sess.Username, err)
+		}
+		total += len(msgs)
+	}
+	// 会话列表在 chats 写完之后写入，才能关联 chat_id
+	if err := w.writeSessions(); err != nil {
+		return fmt.Errorf("write sessions: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO messages_fts(messages_fts) VALUES('rebuild')`); err != nil {
+		return fmt.Errorf("build fts: %v", err)
+	}
+	logrus.Infof("[SQLITE] %d chats, %d messages: %s", len(chats), total, dbPath)
+	return nil
+}
+
+type sqliteWriter struct {
+	db    *sql.DB
+	src   *Source
+	media *mediaWriter
+}
+
+func (w *sqliteWriter) writeMeta() error {
+	meta := [][2]string{
+		{"schema_version", strconv.Itoa(SQLiteSchemaVersion)},
+		{"wechat_version", strconv.Itoa(w.src.Account.Version)},
+		{"wxid", w.src.Account.Wxid},
+		{"exported_at", time.Now().Format(time.RFC3339)},
+	}
+	for _, kv := range meta {
+		if _, err := w.db.Exec(`INSERT INTO meta (key, value) VALUES (?, ?)`, kv[0], kv[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *sqliteWriter) writeContacts() error {
+	if w.src.Contacts == nil {
+		return nil
+	}
+	return w.inTx(func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare(`INSERT OR REPLACE INTO contacts (username, alias, nickname, remark, display_name, description, avatar_url, labels,
+			is_friend, is_chatroom, is_official, is_blocked, is_star) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, c := range w.src.Contacts.All() {
+			if _, err := stmt.Exec(c.Username, c.Alias, c.Nickname, c.Remark, c.DisplayName(), c.Description, c.AvatarURL(),
+				strings.Join(c.Labels, ","), c.IsFriend, c.IsChatRoom, c.IsOfficial, c.IsBlocked, c.IsStar); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (w *sqliteWriter) writeSessions() error {
+	return w.inTx(func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare(`INSERT OR REPLACE INTO sessions (username, chat_id, display_name, summary, last_time, last_msg_type, last_sender,
+			unread, sticky, muted, hidden, sort_order) VALUES (?1, (SELECT id FROM chats WHERE username = ?1), ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, s := range w.src.Sessions {
+			if _, err := stmt.Exec(s.Username, s.DisplayName, s.Summary, unixOrNil(s.LastTime), s.LastMsgType, s.LastSender,
+				s.Unread, s.Sticky, s.Muted, s.Hidden, s.SortOrder); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// writeChat 一个会话在一个事务中写入：会话、群成员、消息和媒体
+func (w *sqliteWriter) writeChat(sess *wexin.Session, msgs []*wexin.Message) error {
+	return w.inTx(func(tx *sql.Tx) error {
+		var first, last any
+		if len(msgs) > 0 {
+			first, last = unixOrNil(msgs[0].CreateTime), unixOrNil(msgs[len(msgs)-1].CreateTime)
+		}
+		var owner, announcement string
+		var createdAt, creator any
+		var room *wexin.ChatRoom
+		if w.src.ChatRooms != nil {
+			if r, ok := w.src.ChatRooms.Get(sess.Username); ok {
+				room, owner, announcement = r, r.Owner, r.Announcement
+				if r.InferCreation(msgs) {
+					createdAt, creator = unixOrNil(r.InferredCreatedAt), r.InferredCreator
+				}
+			}
+		}
+		res, err := tx.Exec(`INSERT INTO chats (username, display_name, is_chatroom, owner, announcement, created_at, creator, message_count,
+			first_time, last_time) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, sess.Username, sess.DisplayName,
+			strings.HasSuffix(sess.Username, "@chatroom"), owner, announcement, createdAt, creator, len(msgs), first, last)
+		if err != nil {
+			return err
+		}
+		chatID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if room != nil {
+			for _, m := range room.Members {
+				if _, err := tx.Exec(`INSERT OR IGNORE INTO chat_members (chat_id, username, display_name) VALUES (?, ?, ?)`,
+					chatID, m.Username, m.DisplayName); err != nil {
+					return err
+				}
+			}
+		}
+
+		msgStmt, err := tx.Prepare(`INSERT INTO messages (chat_id, parent_id, server_id, local_id, sender, sender_name, is_self, time,
+			type, type_name, sub_type, app_kind, text, content, reply_to) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			return err
+		}
+		defer msgStmt.Close()
+		mediaStmt, err := tx.Prepare(`INSERT INTO media (message_id, kind, path, name, size) VALUES (?, ?, ?, ?, ?)`)
+		if err != nil {
+			return err
+		}
+		defer mediaStmt.Close()
+		for _, m := range msgs {
+			if err := w.writeMessage(msgStmt, mediaStmt, chatID, nil, m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// writeMessage 写入一条消息，合并转发中的消息递归写入，parent_id 指向所在的消息
+func (w *sqliteWriter) writeMessage(msgStmt, mediaStmt *sql.Stmt, chatID int64, parentID any, m *wexin.Message) error {
+	var appKind, replyTo any
+	if m.Type == wexin.MsgTypeApp && m.XML() != "" {
+		if app, err := m.AppMsg(); err == nil {
+			appKind = AppKind(app)
+			if q, ok := app.(*wexin.QuoteMsg); ok && q.Refer.SvrID != 0 {
+				replyTo = q.Refer.SvrID
+			}
+		}
+	}
+	if m.ReplyTo != nil {
+		replyTo = m.ReplyTo.ServerID
+	}
+	res, err := msgStmt.Exec(chatID, parentID, nilIfZero(m.ServerID), nilIfZero(m.LocalID), m.Sender, m.SenderName, m.IsSender,
+		unixOrNil(m.CreateTime), m.Type, MsgTypeName(m.Type), m.SubType, appKind, Describe(m), xmlContent(m), replyTo)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	if f := w.media.export(m); f != nil {
+		if _, err := mediaStmt.Exec(id, f.Kind, f.Path, f.Name, f.Size); err != nil {
+			return err
+		}
+	}
+	for _, c := range m.Children {
+		if err := w.writeMessage(msgStmt, mediaStmt, chatID, id, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// xmlContent 写入 content 列的原文：XML() 只含解压成功的数据，
+// 仍不是有效的 UTF-8 时（无法识别的二进制）写 NULL
+func xmlContent(m *wexin.Message) any {
+	if s := m.XML(); utf8.ValidString(s) {
+		return s
+	}
+	return nil
+}
+
+func (w *sqliteWriter) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// unixOrNil 秒级时间戳，零值时间写入 NULL
+func unixOrNil(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.Unix()
+}
+
+func nilIfZero(n int64) any {
+	if n == 0 {
+		return nil
+	}
+	return n
+}
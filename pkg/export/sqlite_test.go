@@ -0,0 +1,123 @@
+package export
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/saucer-man/wxdump/pkg/wexin"
+)
+
+// 群聊 g@chatroom：建群系统消息和自己发的一条消息
+const testRoomInvite = `"张三"邀请你和"李四"加入了群聊`
+
+func addChatRoomV3(t *testing.T, dir string) {
+	createDB(t, filepath.Join(dir, "wxid_me", "Msg", "Multi", "MSG0.db"),
+		`INSERT INTO MSG VALUES (6, 0, 21, 10000, 0, 0, 50, 50000, 2, 'g@chatroom', '`+testRoomInvite+`', NULL, NULL),
+			(7, 0, 22, 1, 0, 1, 60, 60000, 2, 'g@chatroom', 'hello group', NULL, NULL)`)
+	createDB(t, filepath.Join(dir, "wxid_me", "Msg", "MicroMsg.db"),
+		`INSERT INTO Contact(UserName, Alias, Type, VerifyFlag, Remark, NickName) VALUES ('g@chatroom', '', 2, 0, '', 'G')`,
+		`CREATE TABLE ChatRoom(ChatRoomName TEXT, UserNameList TEXT, Owner TEXT, SelfDisplayName TEXT, RoomData BLOB)`,
+		`INSERT INTO ChatRoom VALUES ('g@chatroom', 'wxid_me^Gwxid_a', 'wxid_a', '', NULL)`,
+		`INSERT INTO Session VALUES ('g@chatroom', 60, 0, '', 0, 1, 'hello group', 1, 7, 60)`)
+}
+
+func addChatRoomV4(t *testing.T, dir string) {
+	table, insert := msgTableV4("g@chatroom")
+	createDB(t, filepath.Join(dir, "wxid_me", "message", "message_0.db"),
+		`INSERT INTO Name2Id(user_name) VALUES ('g@chatroom')`,
+		table,
+		insert(21, 10000, 50, 0, testRoomInvite),
+		insert(22, 1, 60, 1, "hello group"))
+	createDB(t, filepath.Join(dir, "wxid_me", "contact", "contact.db"),
+		`INSERT INTO contact(username, local_type, nick_name) VALUES ('g@chatroom', 2, 'G')`,
+		`CREATE TABLE chat_room(id INTEGER PRIMARY KEY, username TEXT, owner TEXT, ext_buffer BLOB)`,
+		`CREATE TABLE chatroom_member(room_id INTEGER, member_id INTEGER)`,
+		`INSERT INTO chat_room VALUES (10, 'g@chatroom', 'wxid_a', NULL)`,
+		`INSERT INTO chatroom_member VALUES (10, 1), (10, 2)`)
+	createDB(t, filepath.Join(dir, "wxid_me", "session", "session.db"),
+		`INSERT INTO SessionTable VALUES ('g@chatroom', 'hello group', 60, 60, 0, 0, 1, 'wxid_me')`)
+}
+
+func TestExportSQLite(t *testing.T) {
+	for _, c := range []struct {
+		version int
+		dir     func(t *testing.T) string
+		addRoom func(t *testing.T, dir string)
+	}{
+		{3, sourceDirV3, addChatRoomV3},
+		{4, sourceDirV4, addChatRoomV4},
+	} {
+		dir := c.dir(t)
+		c.addRoom(t, dir)
+		src := openTestSource(t, &wexin.Account{Version: c.version, Wxid: "wxid_me"}, dir)
+		// 再次导出时重新生成归档库
+		out := t.TempDir()
+		for i := 0; i < 2; i++ {
+			if err := ExportSQLite(src, out); err != nil {
+				t.Fatalf("v%d: %v", c.version, err)
+			}
+		}
+		db, err := sql.Open("sqlite", filepath.Join(out, SQLiteFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		queryRow := func(query string, dest ...any) {
+			t.Helper()
+			if err := db.QueryRow(query).Scan(dest...); err != nil {
+				t.Fatalf("v%d: %s: %v", c.version, query, err)
+			}
+		}
+
+		var version string
+		queryRow(`SELECT value FROM meta WHERE key = 'wechat_version'`, &version)
+		if version != strconv.Itoa(c.version) {
+			t.Errorf("v%d: wechat_version = %s", c.version, version)
+		}
+		// 合并转发里的 1 条消息也写入 messages
+		for table, want := range map[string]int{
+			"contacts": 3, "chats": 2, "chat_members": 2, "messages": 8, "media": 0, "sessions": 2,
+		} {
+			var n int
+			queryRow(`SELECT count(*) FROM `+table, &n)
+			if n != want {
+				t.Errorf("v%d: %s has %d rows, want %d", c.version, table, n, want)
+			}
+		}
+
+		var linked int
+		queryRow(`SELECT count(*) FROM sessions s JOIN chats c ON c.id = s.chat_id AND c.username = s.username`, &linked)
+		if linked != 2 {
+			t.Errorf("v%d: %d sessions linked to chats, want 2", c.version, linked)
+		}
+		var count, first, last int64
+		queryRow(`SELECT message_count, first_time, last_time FROM chats WHERE username = 'wxid_a'`, &count, &first, &last)
+		if count != 5 || first != 100 || last != 500 {
+			t.Errorf("v%d: wxid_a chat = %d messages, %d-%d", c.version, count, first, last)
+		}
+		var owner, creator string
+		var createdAt int64
+		queryRow(`SELECT owner, creator, created_at FROM chats WHERE username = 'g@chatroom'`, &owner, &creator, &createdAt)
+		if owner != "wxid_a" || creator != "张三" || createdAt != 50 {
+			t.Errorf("v%d: chatroom owner = %q, creator = %q at %d", c.version, owner, creator, createdAt)
+		}
+
+		var replyTo, parent int64
+		queryRow(`SELECT reply_to FROM messages WHERE server_id = 12`, &replyTo)
+		queryRow(`SELECT p.server_id FROM messages m JOIN messages p ON p.id = m.parent_id`, &parent)
+		if replyTo != 11 || parent != 13 {
+			t.Errorf("v%d: reply_to = %d, record parent = %d", c.version, replyTo, parent)
+		}
+
+		// trigram 分词，关键字至少 3 个字符
+		for query, want := range map[string]int64{`'"回复你"'`: 12, `'"inner text"'`: 0, `'"hello group"'`: 22} {
+			var serverID sql.NullInt64
+			queryRow(`SELECT m.server_id FROM messages_fts f JOIN messages m ON m.id = f.rowid WHERE messages_fts MATCH `+query, &serverID)
+			if serverID.Int64 != want {
+				t.Errorf("v%d: MATCH %s = %v, want %d", c.version, query, serverID, want)
+			}
+		}
+	}
+}
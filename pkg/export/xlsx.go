@@ -0,0 +1,164 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// xlsx 单元格最多 32767 个字符，sheet 名最多 31 个字符，都按 UTF-16 计算；每个 sheet 最多 1048576 行
+const (
+	xlsxMaxCellLen  = 32767
+	xlsxMaxSheetLen = 31
+	xlsxMaxRows     = 1 << 20
+)
+
+// xlsxWriter 只写入行内字符串的最简 xlsx，不依赖第三方库。
+// 单元格都是 inlineStr 类型，以 = 开头的内容也不会被当作公式
+type xlsxWriter struct {
+	zw     *zip.Writer
+	sheets []string
+	names  map[string]bool
+}
+
+func newXLSXWriter(w io.Writer) *xlsxWriter {
+	return &xlsxWriter{zw: zip.NewWriter(w), names: make(map[string]bool)}
+}
+
+// sheetName 去掉 sheet 名中不允许的字符和首尾的单引号，截断并去重；History 是 Excel 保留的名字
+func (x *xlsxWriter) sheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	base := strings.Trim(truncateUTF16(name, xlsxMaxSheetLen), "'")
+	if base == "" {
+		base = "Sheet"
+	}
+	if strings.EqualFold(base, "History") {
+		base += "_"
+	}
+	name = base
+	for i := 2; x.names[strings.ToLower(name)]; i++ {
+		suffix := fmt.Sprintf("(%d)", i)
+		name = truncateUTF16(base, xlsxMaxSheetLen-len(suffix)) + suffix
+	}
+	x.names[strings.ToLower(name)] = true
+	return name
+}
+
+// AddSheet 写入一个 sheet，rows[0] 为表头；超过行数上限时拆成多个 sheet，每个都带表头
+func (x *xlsxWriter) AddSheet(name string, rows [][]string) error {
+	if len(rows) <= xlsxMaxRows {
+		return x.addSheet(name, rows)
+	}
+	header, body := rows[0], rows[1:]
+	for part := 1; len(body) > 0; part++ {
+		n := min(len(body), xlsxMaxRows-1)
+		suffix := fmt.Sprintf("(%d)", part)
+		chunk := append([][]string{header}, body[:n]...)
+		if err := x.addSheet(truncateUTF16(name, xlsxMaxSheetLen-len(suffix))+suffix, chunk); err != nil {
+			return err
+		}
+		body = body[n:]
+	}
+	return nil
+}
+
+func (x *xlsxWriter) addSheet(name string, rows [][]string) error {
+	x.sheets = append(x.sheets, x.sheetName(name))
+	w, err := x.zw.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", len(x.sheets)))
+	if err != nil {
+		return err
+	}
+	var sb strings.Builder
+	sb.WriteString(xml.Header)
+	sb.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for i, row := range rows {
+		fmt.Fprintf(&sb, `<row r="%d">`, i+1)
+		for j, cell := range row {
+			fmt.Fprintf(&sb, `<c r="%s%d" t="inlineStr"><is><t xml:space="preserve">`, xlsxColumn(j), i+1)
+			xml.EscapeText(&sb, []byte(truncateUTF16(cell, xlsxMaxCellLen)))
+			sb.WriteString(`</t></is></c>`)
+		}
+		sb.WriteString(`</row>`)
+	}
+	sb.WriteString(`</sheetData></worksheet>`)
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
+
+// Close 写入 workbook 和各种关系文件
+func (x *xlsxWriter) Close() error {
+	// 至少要有一个 sheet，否则 Excel 打不开
+	if len(x.sheets) == 0 {
+		if err := x.addSheet("Sheet1", nil); err != nil {
+			return err
+		}
+	}
+	var types, sheets, rels strings.Builder
+	for i, name := range x.sheets {
+		n := i + 1
+		fmt.Fprintf(&types, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+		fmt.Fprintf(&sheets, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(name), n, n)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, n, n)
+	}
+	files := []struct{ name, body string }{
+		{"[Content_Types].xml", `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			types.String() + `</Types>`},
+		{"_rels/.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+			`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>` +
+			sheets.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			rels.String() + `</Relationships>`},
+	}
+	for _, f := range files {
+		w, err := x.zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, xml.Header+f.body); err != nil {
+			return err
+		}
+	}
+	return x.zw.Close()
+}
+
+// xlsxColumn 列号转成 A、B ... Z、AA
+func xlsxColumn(i int) string {
+	var col []byte
+	for i++; i > 0; i = (i - 1) / 26 {
+		col = append([]byte{byte('A' + (i-1)%26)}, col...)
+	}
+	return string(col)
+}
+
+func xmlEscape(s string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
+
+// truncateUTF16 截断到 n 个 UTF-16 码元，不会把代理对截成两半
+func truncateUTF16(s string, n int) string {
+	units := 0
+	for i, r := range s {
+		w := max(utf16.RuneLen(r), 1)
+		if units+w > n {
+			return s[:i]
+		}
+		units += w
+	}
+	return s
+}
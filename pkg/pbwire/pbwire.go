@@ -0,0 +1,237 @@
+// Package pbwire 不依赖 .proto 定义，按 wire format 解析 protobuf，
+// 用于 v3 BytesExtra、v4 packed_info_data、contact.extra_buffer 等结构未公开的字段。
+//
+// length-delimited 字段无法区分字符串和嵌套消息：能完整解析为消息时填充 Message，
+// 原始字节始终保留在 Bytes 中，同一个字段可能既是可打印文本又能解析为消息。
+package pbwire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type WireType uint8
+
+const (
+	Varint     WireType = 0
+	Fixed64    WireType = 1
+	Bytes      WireType = 2
+	StartGroup WireType = 3
+	EndGroup   WireType = 4
+	Fixed32    WireType = 5
+)
+
+const (
+	maxDepth    = 32        // 嵌套解析的最大深度
+	maxFieldNum = 1<<29 - 1 // protobuf 允许的最大字段号
+)
+
+var (
+	ErrTruncated = errors.New("protobuf data truncated")
+	ErrOverflow  = errors.New("protobuf varint overflows 64 bits")
+	ErrWireType  = errors.New("invalid protobuf wire type")
+)
+
+// uvarint 读取一个 varint，区分数据不完整和超过 64 位
+func uvarint(data []byte) (uint64, int, error) {
+	v, n := binary.Uvarint(data)
+	switch {
+	case n == 0:
+		return 0, 0, ErrTruncated
+	case n < 0:
+		return 0, 0, ErrOverflow
+	}
+	return v, n, nil
+}
+
+// Field 一个字段
+type Field struct {
+	Num     int
+	Type    WireType
+	Varint  uint64  // Varint
+	Fixed   uint64  // Fixed32、Fixed64
+	Bytes   []byte  // Bytes
+	Message Message // Bytes 能解析为嵌套消息时
+}
+
+// Message 按出现顺序排列的字段，同一字段号可能出现多次（repeated）
+type Message []Field
+
+// Parse 解析 protobuf 数据，length-delimited 字段会尝试递归解析
+func Parse(data []byte) (Message, error) {
+	return parse(data, 0)
+}
+
+func parse(data []byte, depth int) (Message, error) {
+	var msg Message
+	for len(data) > 0 {
+		key, n, err := uvarint(data)
+		if err != nil {
+			return nil, err
+		}
+		data = data[n:]
+		if num := key >> 3; num == 0 || num > maxFieldNum {
+			return nil, fmt.Errorf("invalid field number %d", num)
+		}
+		f := Field{Num: int(key >> 3), Type: WireType(key & 7)}
+		switch f.Type {
+		case Varint:
+			v, n, err := uvarint(data)
+			if err != nil {
+				return nil, err
+			}
+			f.Varint = v
+			data = data[n:]
+		case Fixed64:
+			if len(data) < 8 {
+				return nil, ErrTruncated
+			}
+			f.Fixed = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case Fixed32:
+			if len(data) < 4 {
+				return nil, ErrTruncated
+			}
+			f.Fixed = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		case Bytes:
+			l, n, err := uvarint(data)
+			if err != nil {
+				return nil, err
+			}
+			// 长度按无符号数比较，负数（补码的超大值）也会被当作截断
+			if l > uint64(len(data)-n) {
+				return nil, ErrTruncated
+			}
+			f.Bytes = data[n : n+int(l)]
+			data = data[n+int(l):]
+			if depth < maxDepth && len(f.Bytes) > 0 {
+				if sub, err := parse(f.Bytes, depth+1); err == nil {
+					f.Message = sub
+				}
+			}
+		default:
+			// group 已废弃，微信的数据里不会出现
+			return nil, ErrWireType
+		}
+		msg = append(msg, f)
+	}
+	return msg, nil
+}
+
+// isPrintable 是否为可打印的 UTF-8 文本
+func isPrintable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsString 字段是否为可打印文本
+func (f Field) IsString() bool {
+	return f.Type == Bytes && isPrintable(f.Bytes)
+}
+
+// String length-delimited 字段的文本值
+func (f Field) String() string {
+	return string(f.Bytes)
+}
+
+// Get 返回字段号为 num 的所有字段
+func (m Message) Get(num int) []Field {
+	var fields []Field
+	for _, f := range m {
+		if f.Num == num {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// First 返回第一个字段号为 num 的字段
+func (m Message) First(num int) (Field, bool) {
+	for _, f := range m {
+		if f.Num == num {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// Find 按字段号路径查找，例如 Find(3, 2) 为所有字段 3 中的字段 2
+func (m Message) Find(path ...int) []Field {
+	if len(path) == 0 {
+		return nil
+	}
+	fields := m.Get(path[0])
+	if len(path) == 1 {
+		return fields
+	}
+	var found []Field
+	for _, f := range fields {
+		found = append(found, f.Message.Find(path[1:]...)...)
+	}
+	return found
+}
+
+// Walk 深度优先遍历所有字段，path 为从根开始的字段号
+func (m Message) Walk(fn func(path []int, f Field)) {
+	m.walk(nil, fn)
+}
+
+func (m Message) walk(prefix []int, fn func(path []int, f Field)) {
+	for _, f := range m {
+		path := append(append([]int(nil), prefix...), f.Num)
+		fn(path, f)
+		if f.Message != nil {
+			f.Message.walk(path, fn)
+		}
+	}
+}
+
+// Dump 类似 protoc --decode_raw 的文本视图，方便分析未知字段
+func (m Message) Dump() string {
+	var sb strings.Builder
+	m.dump(&sb, 0)
+	return sb.String()
+}
+
+func (m Message) dump(sb *strings.Builder, indent int) {
+	pad := strings.Repeat("  ", indent)
+	for _, f := range m {
+		switch {
+		case f.Type == Varint:
+			fmt.Fprintf(sb, "%s%d: %d\n", pad, f.Num, f.Varint)
+		case f.Type == Fixed32:
+			fmt.Fprintf(sb, "%s%d: 0x%08x\n", pad, f.Num, f.Fixed)
+		case f.Type == Fixed64:
+			fmt.Fprintf(sb, "%s%d: 0x%016x\n", pad, f.Num, f.Fixed)
+		case isPrintable(f.Bytes):
+			fmt.Fprintf(sb, "%s%d: %q\n", pad, f.Num, f.Bytes)
+		case f.Message != nil:
+			fmt.Fprintf(sb, "%s%d {\n", pad, f.Num)
+			f.Message.dump(sb, indent+1)
+			fmt.Fprintf(sb, "%s}\n", pad)
+		default:
+			fmt.Fprintf(sb, "%s%d: 0x%x\n", pad, f.Num, f.Bytes)
+		}
+	}
+}
+
+// Dump 解析并输出文本视图，解析失败时输出十六进制
+func Dump(data []byte) string {
+	m, err := Parse(data)
+	if err != nil {
+		return fmt.Sprintf("<%v> 0x%x\n", err, data)
+	}
+	return m.Dump()
+}
@@ -0,0 +1,118 @@
+package pbwire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// tag 拼出字段头
+func tag(num int, typ WireType) []byte {
+	return binary.AppendUvarint(nil, uint64(num)<<3|uint64(typ))
+}
+
+func lenField(num int, b []byte) []byte {
+	out := append(tag(num, Bytes), binary.AppendUvarint(nil, uint64(len(b)))...)
+	return append(out, b...)
+}
+
+func varintField(num int, v uint64) []byte {
+	return append(tag(num, Varint), binary.AppendUvarint(nil, v)...)
+}
+
+func cat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestParse(t *testing.T) {
+	inner := cat(varintField(1, 34), lenField(2, []byte("wxid_a")))
+	data := cat(
+		varintField(1, 1<<63),
+		lenField(3, inner),
+		append(tag(4, Fixed32), 1, 0, 0, 0),
+		append(tag(5, Fixed64), 2, 0, 0, 0, 0, 0, 0, 0),
+		lenField(3, []byte("text")),
+		lenField(6, nil),
+	)
+	msg, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msg) != 6 {
+		t.Fatalf("got %d fields, want 6", len(msg))
+	}
+	if msg[0].Varint != 1<<63 || msg[2].Fixed != 1 || msg[3].Fixed != 2 {
+		t.Errorf("scalar fields = %+v", msg[:4])
+	}
+	if got := msg.Find(3, 2); len(got) != 1 || got[0].String() != "wxid_a" {
+		t.Errorf("Find(3, 2) = %+v", got)
+	}
+	if f, _ := msg.First(6); f.Type != Bytes || len(f.Bytes) != 0 || f.Message != nil {
+		t.Errorf("empty bytes field = %+v", f)
+	}
+	// "text" 不是合法的嵌套消息，只保留文本
+	if texts := msg.Get(3); !texts[1].IsString() || texts[1].Message != nil {
+		t.Errorf("text field = %+v", texts[1])
+	}
+	if dump := msg.Dump(); !strings.Contains(dump, `2: "wxid_a"`) || !strings.Contains(dump, "4: 0x00000001") {
+		t.Errorf("dump:\n%s", dump)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	overflow := bytes.Repeat([]byte{0xff}, 10)
+	overflow = append(overflow, 0x01)
+	for _, c := range []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"truncated key", []byte{0x80}, ErrTruncated},
+		{"key overflow", overflow, ErrOverflow},
+		{"truncated varint", append(tag(1, Varint), 0x80, 0x80), ErrTruncated},
+		{"varint overflow", append(tag(1, Varint), overflow...), ErrOverflow},
+		{"truncated fixed32", append(tag(1, Fixed32), 1, 2, 3), ErrTruncated},
+		{"truncated fixed64", append(tag(1, Fixed64), 1, 2, 3, 4, 5, 6, 7), ErrTruncated},
+		{"truncated bytes", append(tag(1, Bytes), 5, 'a', 'b'), ErrTruncated},
+		{"missing length", tag(1, Bytes), ErrTruncated},
+		{"negative length", cat(tag(1, Bytes), binary.AppendUvarint(nil, uint64(1<<64-1)), []byte("abc")), ErrTruncated},
+		{"huge length", cat(tag(1, Bytes), binary.AppendUvarint(nil, 1<<62), []byte("abc")), ErrTruncated},
+		{"length overflow", cat(tag(1, Bytes), overflow), ErrOverflow},
+		{"start group", append(tag(1, StartGroup), tag(1, EndGroup)...), ErrWireType},
+		{"end group", tag(1, EndGroup), ErrWireType},
+		{"wire type 6", tag(1, 6), ErrWireType},
+		{"wire type 7", tag(1, 7), ErrWireType},
+		{"field 0", []byte{0x00}, nil},
+		{"field too large", binary.AppendUvarint(nil, uint64(maxFieldNum+1)<<3), nil},
+	} {
+		msg, err := Parse(c.data)
+		if err == nil {
+			t.Errorf("%s: got %+v, want error", c.name, msg)
+			continue
+		}
+		if c.err != nil && !errors.Is(err, c.err) {
+			t.Errorf("%s: err = %v, want %v", c.name, err, c.err)
+		}
+	}
+}
+
+func TestParseDepth(t *testing.T) {
+	// 超过 maxDepth 的嵌套不再展开，但不影响外层解析
+	data := varintField(1, 1)
+	for i := 0; i < maxDepth+5; i++ {
+		data = lenField(1, data)
+	}
+	msg, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	depth := 0
+	msg.Walk(func(path []int, f Field) {
+		depth = max(depth, len(path))
+	})
+	if depth != maxDepth+1 {
+		t.Errorf("walked depth = %d, want %d", depth, maxDepth+1)
+	}
+}
@@ -0,0 +1,92 @@
+// 移植自 SILK SDK 1.0.9，Copyright (c) 2006-2012, Skype Limited. All rights reserved.
+// 许可条款见同目录 LICENSE。
+
+package silk
+
+import "math"
+
+// 舒适噪声生成（CNG）
+const (
+	cngBufMaskMax  = 255
+	cngGainSmthQ16 = 4634  // 0.25^(1/4)
+	cngNLSFSmthQ16 = 16348 // 0.25
+)
+
+type cngState struct {
+	excBufQ10   [maxFrameLength]int32
+	smthNLSFQ15 [maxLPCOrder]int32
+	synthState  [maxLPCOrder]int32
+	smthGainQ16 int32
+	randSeed    int32
+	fsKHz       int
+}
+
+func (d *decoderState) cngReset() {
+	step := int32(math.MaxInt16 / (d.lpcOrder + 1))
+	var acc int32
+	for i := 0; i < d.lpcOrder; i++ {
+		acc += step
+		d.cng.smthNLSFQ15[i] = acc
+	}
+	d.cng.smthGainQ16 = 0
+	d.cng.randSeed = 3176576
+}
+
+// cngProcess 更新舒适噪声参数，丢包时把噪声混入输出
+func (d *decoderState) cngProcess(ctrl *decoderControl, signal []int16) {
+	cng := &d.cng
+	if d.fsKHz != cng.fsKHz {
+		d.cngReset()
+		cng.fsKHz = d.fsKHz
+	}
+	if d.lossCnt == 0 && d.vadFlag == noVoiceActivity {
+		for i := 0; i < d.lpcOrder; i++ {
+			cng.smthNLSFQ15[i] += smulwb(d.prevNLSFQ15[i]-cng.smthNLSFQ15[i], cngNLSFSmthQ16)
+		}
+		// 用增益最大的子帧更新激励缓存
+		var maxGainQ16 int32
+		subfr := 0
+		for i := 0; i < nbSubfr; i++ {
+			if ctrl.gainsQ16[i] > maxGainQ16 {
+				maxGainQ16 = ctrl.gainsQ16[i]
+				subfr = i
+			}
+		}
+		copy(cng.excBufQ10[d.subfrLength:nbSubfr*d.subfrLength], cng.excBufQ10[:(nbSubfr-1)*d.subfrLength])
+		copy(cng.excBufQ10[:d.subfrLength], d.excQ10[subfr*d.subfrLength:])
+
+		for i := 0; i < nbSubfr; i++ {
+			cng.smthGainQ16 += smulwb(ctrl.gainsQ16[i]-cng.smthGainQ16, cngGainSmthQ16)
+		}
+	}
+
+	if d.lossCnt == 0 {
+		for i := 0; i < d.lpcOrder; i++ {
+			cng.synthState[i] = 0
+		}
+		return
+	}
+
+	var (
+		cngSig [maxFrameLength]int16
+		lpcBuf [maxLPCOrder]int16
+	)
+	length := len(signal)
+	excMask := cngBufMaskMax
+	for excMask > length {
+		excMask >>= 1
+	}
+	seed := cng.randSeed
+	for i := 0; i < length; i++ {
+		seed = silkRand(seed)
+		idx := int(seed>>24) & excMask
+		cngSig[i] = sat16(rshiftRound(smulww(cng.excBufQ10[idx], cng.smthGainQ16), 10))
+	}
+	cng.randSeed = seed
+
+	nlsf2aStable(lpcBuf[:], cng.smthNLSFQ15[:], d.lpcOrder)
+	lpcSynthesisFilter(cngSig[:length], lpcBuf[:d.lpcOrder], 1<<26, cng.synthState[:d.lpcOrder], cngSig[:length])
+	for i := 0; i < length; i++ {
+		signal[i] = sat16(int32(signal[i]) + int32(cngSig[i]))
+	}
+}
@@ -0,0 +1,186 @@
+// 移植自 SILK SDK 1.0.9，Copyright (c) 2006-2012, Skype Limited. All rights reserved.
+// 许可条款见同目录 LICENSE。
+
+package silk
+
+import "math"
+
+// decodeCore 逆噪声整形量化：激励 -> LTP 合成 -> LPC 合成
+func (d *decoderState) decodeCore(ctrl *decoderControl, xq []int16, q []int32) {
+	var (
+		aQ12      []int16
+		lag       int
+		sLTP      [maxFrameLength]int16
+		vecQ10    [maxFrameLength / nbSubfr]int32
+		filtState [maxLPCOrder]int32
+	)
+
+	offsetQ10 := quantizationOffsetsQ10[ctrl.sigtype][ctrl.quantOffsetType]
+	nlsfInterpolationFlag := 0
+	if ctrl.nlsfInterpCoefQ2 < 1<<2 {
+		nlsfInterpolationFlag = 1
+	}
+
+	// 解码激励
+	randSeed := ctrl.seed
+	for i := 0; i < d.frameLength; i++ {
+		randSeed = silkRand(randSeed)
+		dither := randSeed >> 31
+		d.excQ10[i] = q[i]<<10 + offsetQ10
+		d.excQ10[i] = (d.excQ10[i] ^ dither) - dither
+		randSeed += q[i]
+	}
+
+	pexc := d.excQ10[:]
+	pres := d.resQ10[:]
+	pxq := d.outBuf[d.frameLength:]
+	sLTPBufIdx := d.frameLength
+	for k := 0; k < nbSubfr; k++ {
+		aQ12 = ctrl.predCoefQ12[k>>1][:]
+		bQ14 := ctrl.ltpCoefQ14[k*ltpOrder:]
+		gainQ16 := ctrl.gainsQ16[k]
+		sigtype := ctrl.sigtype
+
+		invGainQ16 := inverse32VarQ(max32(gainQ16, 1), 32)
+		invGainQ16 = min32(invGainQ16, math.MaxInt16)
+
+		// 增益变化时的调整系数
+		gainAdjQ16 := int32(1 << 16)
+		if invGainQ16 != d.prevInvGainQ16 {
+			gainAdjQ16 = div32VarQ(invGainQ16, d.prevInvGainQ16, 16)
+		}
+
+		// 避免从浊音 PLC 突变到清音正常解码
+		if d.lossCnt != 0 && d.prevSigtype == sigTypeVoiced &&
+			ctrl.sigtype == sigTypeUnvoiced && k < nbSubfr>>1 {
+			for i := 0; i < ltpOrder; i++ {
+				bQ14[i] = 0
+			}
+			bQ14[ltpOrder/2] = 1 << 12
+			sigtype = sigTypeVoiced
+			ctrl.pitchL[k] = d.lagPrev
+		}
+
+		if sigtype == sigTypeVoiced {
+			lag = ctrl.pitchL[k]
+			if k&(3-nlsfInterpolationFlag<<1) == 0 {
+				// 用新的 LPC 系数重新白化
+				startIdx := d.frameLength - lag - d.lpcOrder - ltpOrder/2
+				for i := 0; i < d.lpcOrder; i++ {
+					filtState[i] = 0
+				}
+				maPrediction(d.outBuf[startIdx+k*(d.frameLength>>2):], aQ12[:d.lpcOrder], filtState[:d.lpcOrder],
+					sLTP[startIdx:d.frameLength])
+
+				invGainQ32 := invGainQ16 << 16
+				if k == 0 {
+					// LTP 缩放
+					invGainQ32 = smulwb(invGainQ32, int32(ctrl.ltpScaleQ14)) << 2
+				}
+				for i := 0; i < lag+ltpOrder/2; i++ {
+					d.sLTPQ16[sLTPBufIdx-i-1] = smulwb(invGainQ32, int32(sLTP[d.frameLength-i-1]))
+				}
+			} else if gainAdjQ16 != 1<<16 {
+				for i := 0; i < lag+ltpOrder/2; i++ {
+					d.sLTPQ16[sLTPBufIdx-i-1] = smulww(gainAdjQ16, d.sLTPQ16[sLTPBufIdx-i-1])
+				}
+			}
+		}
+
+		for i := 0; i < maxLPCOrder; i++ {
+			d.sLPCQ14[i] = smulww(gainAdjQ16, d.sLPCQ14[i])
+		}
+		d.prevInvGainQ16 = invGainQ16
+
+		// 长时预测
+		if sigtype == sigTypeVoiced {
+			predLag := sLTPBufIdx - lag + ltpOrder/2
+			for i := 0; i < d.subfrLength; i++ {
+				p := d.sLTPQ16[predLag-4 : predLag+1]
+				ltpPredQ14 := smulwb(p[4], int32(bQ14[0]))
+				ltpPredQ14 = smlawb(ltpPredQ14, p[3], int32(bQ14[1]))
+				ltpPredQ14 = smlawb(ltpPredQ14, p[2], int32(bQ14[2]))
+				ltpPredQ14 = smlawb(ltpPredQ14, p[1], int32(bQ14[3]))
+				ltpPredQ14 = smlawb(ltpPredQ14, p[0], int32(bQ14[4]))
+				predLag++
+
+				pres[i] = pexc[i] + rshiftRound(ltpPredQ14, 4)
+				d.sLTPQ16[sLTPBufIdx] = pres[i] << 6
+				sLTPBufIdx++
+			}
+		} else {
+			copy(pres[:d.subfrLength], pexc[:d.subfrLength])
+		}
+
+		// 短时预测
+		for i := 0; i < d.subfrLength; i++ {
+			lpcPredQ10 := lpcPrediction(d.sLPCQ14[maxLPCOrder+i-d.lpcOrder:maxLPCOrder+i], aQ12[:d.lpcOrder])
+			vecQ10[i] = pres[i] + lpcPredQ10
+			d.sLPCQ14[maxLPCOrder+i] = vecQ10[i] << 4
+		}
+
+		for i := 0; i < d.subfrLength; i++ {
+			pxq[i] = sat16(rshiftRound(smulww(vecQ10[i], gainQ16), 10))
+		}
+
+		copy(d.sLPCQ14[:maxLPCOrder], d.sLPCQ14[d.subfrLength:d.subfrLength+maxLPCOrder])
+		pexc = pexc[d.subfrLength:]
+		pres = pres[d.subfrLength:]
+		pxq = pxq[d.subfrLength:]
+	}
+
+	copy(xq[:d.frameLength], d.outBuf[d.frameLength:2*d.frameLength])
+}
+
+// lpcPrediction 计算 sum(state[order-1-j] * a[j])，state 末尾是最新的样本
+func lpcPrediction(state []int32, a []int16) int32 {
+	n := len(a)
+	pred := smulwb(state[n-1], int32(a[0]))
+	for j := 1; j < n; j++ {
+		pred = smlawb(pred, state[n-1-j], int32(a[j]))
+	}
+	return pred
+}
+
+// lpcSynthesisFilter 偶数阶 AR 合成滤波，s 为 Q14 状态
+func lpcSynthesisFilter(in []int16, aQ12 []int16, gainQ26 int32, s []int32, out []int16) {
+	order := len(aQ12)
+	for k := range in {
+		out32Q10 := lpcPrediction(s[:order], aQ12)
+		copy(s[:order-1], s[1:order])
+		out32Q10 = addSat32(out32Q10, smulwb(gainQ26, int32(in[k])))
+		out[k] = sat16(rshiftRound(out32Q10, 10))
+		s[order-1] = lshiftSat32(out32Q10, 4)
+	}
+}
+
+// maPrediction 变阶 MA 预测误差滤波
+func maPrediction(in []int16, b []int16, s []int32, out []int16) {
+	order := len(b)
+	for k := range out {
+		in16 := int32(in[k])
+		out32 := rshiftRound(in16<<12-s[0], 12)
+		for d := 0; d < order-1; d++ {
+			s[d] = smlabbOvflw(s[d+1], in16, int32(b[d]))
+		}
+		s[order-1] = smulbb(in16, int32(b[order-1]))
+		out[k] = sat16(out32)
+	}
+}
+
+// biquad 二阶 IIR 滤波（解码输出的高通滤波）
+func biquad(in []int16, b, a []int16, s []int32, out []int16) {
+	a0Neg := -int32(a[0])
+	a1Neg := -int32(a[1])
+	s0, s1 := s[0], s[1]
+	for k := range in {
+		in16 := int32(in[k])
+		out32 := smlabb(s0, in16, int32(b[0]))
+		s0 = smlabb(s1, in16, int32(b[1]))
+		s0 += smulwb(out32, a0Neg) << 3
+		s1 = smulwb(out32, a1Neg) << 3
+		s1 = smlabb(s1, in16, int32(b[2]))
+		out[k] = sat16(rshiftRound(out32, 13) + 1)
+	}
+	s[0], s[1] = s0, s1
+}
@@ -0,0 +1,465 @@
+// 移植自 SILK SDK 1.0.9，Copyright (c) 2006-2012, Skype Limited. All rights reserved.
+// 许可条款见同目录 LICENSE。
+
+package silk
+
+import "fmt"
+
+const (
+	maxLPCOrder               = 16
+	minLPCOrder               = 10
+	nbSubfr                   = 4
+	ltpOrder                  = 5
+	maxFrameLength            = 480 // 20ms @ 24kHz
+	maxLPCStabilizeIterations = 20
+	maxFramesPerPacket        = 5
+	shellCodecFrameLength     = 16
+	maxPulses                 = 18
+	nRateLevels               = 10
+	bweAfterLossQ16           = 63570
+
+	sigTypeVoiced   = 0
+	sigTypeUnvoiced = 1
+	noVoiceActivity = 0
+	moreFrames      = 1
+
+	// 增益反量化
+	minQGainDB        = 6
+	maxQGainDB        = 86
+	nLevelsQGain      = 64
+	minDeltaGainQuant = -4
+	gainOffset        = (minQGainDB*128)/6 + 16*128
+	invScaleQ16       = (65536 * (((maxQGainDB - minQGainDB) * 128) / 6)) / (nLevelsQGain - 1)
+)
+
+// decoderControl 单帧的解码参数
+type decoderControl struct {
+	pitchL           [nbSubfr]int
+	gainsQ16         [nbSubfr]int32
+	seed             int32
+	predCoefQ12      [2][maxLPCOrder]int16
+	ltpCoefQ14       [ltpOrder * nbSubfr]int16
+	ltpScaleQ14      int32
+	perIndex         int
+	rateLevelIndex   int
+	quantOffsetType  int
+	sigtype          int
+	nlsfInterpCoefQ2 int
+}
+
+// decoderState 跨帧的解码器状态
+type decoderState struct {
+	rc                   rangeDecoder
+	prevInvGainQ16       int32
+	sLTPQ16              [2 * maxFrameLength]int32
+	sLPCQ14              [maxFrameLength/nbSubfr + maxLPCOrder]int32
+	excQ10               [maxFrameLength]int32
+	resQ10               [maxFrameLength]int32
+	outBuf               [2 * maxFrameLength]int16
+	lagPrev              int
+	lastGainIndex        int
+	typeOffsetPrev       int
+	hpState              [2]int32
+	hpA, hpB             []int16
+	fsKHz                int
+	frameLength          int
+	subfrLength          int
+	lpcOrder             int
+	prevNLSFQ15          [maxLPCOrder]int32
+	firstFrameAfterReset bool
+
+	nBytesLeft                int
+	nFramesDecoded            int
+	moreInternalDecoderFrames bool
+	frameTermination          int
+	vadFlag                   int
+	nlsfCB                    [2]*nlsfCodebook
+
+	cng         cngState
+	lossCnt     int
+	prevSigtype int
+	plc         plcState
+}
+
+func (d *decoderState) init() {
+	*d = decoderState{}
+	d.setFs(24)
+	d.firstFrameAfterReset = true
+	d.prevInvGainQ16 = 65536
+	d.cngReset()
+	d.plcReset()
+}
+
+// setFs 切换内部采样率（kHz）
+func (d *decoderState) setFs(fsKHz int) {
+	if d.fsKHz == fsKHz {
+		return
+	}
+	d.fsKHz = fsKHz
+	d.frameLength = 20 * fsKHz
+	d.subfrLength = 20 / nbSubfr * fsKHz
+	if fsKHz == 8 {
+		d.lpcOrder = minLPCOrder
+		d.nlsfCB = [2]*nlsfCodebook{nlsfVoiced10, nlsfUnvoiced10}
+	} else {
+		d.lpcOrder = maxLPCOrder
+		d.nlsfCB = [2]*nlsfCodebook{nlsfVoiced16, nlsfUnvoiced16}
+	}
+	for i := 0; i < maxLPCOrder; i++ {
+		d.sLPCQ14[i] = 0
+		d.prevNLSFQ15[i] = 0
+	}
+	for i := 0; i < maxFrameLength; i++ {
+		d.outBuf[i] = 0
+	}
+	d.lagPrev = 100
+	d.lastGainIndex = 1
+	d.prevSigtype = 0
+	d.firstFrameAfterReset = true
+
+	switch fsKHz {
+	case 24:
+		d.hpA, d.hpB = decAHP24, decBHP24
+	case 16:
+		d.hpA, d.hpB = decAHP16, decBHP16
+	case 12:
+		d.hpA, d.hpB = decAHP12, decBHP12
+	default:
+		d.hpA, d.hpB = decAHP8, decBHP8
+	}
+}
+
+// decodeFrame 解码一个内部帧（20ms），data 为 nil 或解码出错时做丢包补偿。
+// 返回输出的样本数
+func (d *decoderState) decodeFrame(out []int16, data []byte, lost bool) (n int, usedBytes int, err error) {
+	var ctrl decoderControl
+	var pulses [maxFrameLength]int32
+	L := d.frameLength
+
+	if !lost {
+		fsKHzOld := d.fsKHz
+		if d.nFramesDecoded == 0 {
+			d.rc.init(data)
+		}
+		d.decodeParameters(&ctrl, pulses[:])
+		if d.rc.err != 0 {
+			d.nBytesLeft = 0
+			lost = true
+			d.setFs(fsKHzOld)
+			usedBytes = d.rc.bufferLength
+			err = fmt.Errorf("range decoder error %d", d.rc.err)
+		} else {
+			usedBytes = d.rc.bufferLength - d.nBytesLeft
+			d.nFramesDecoded++
+			L = d.frameLength
+			d.decodeCore(&ctrl, out, pulses[:])
+			d.plcProcess(&ctrl, out[:L], false)
+			d.lossCnt = 0
+			d.prevSigtype = ctrl.sigtype
+			d.firstFrameAfterReset = false
+		}
+	}
+	if lost {
+		d.plcProcess(&ctrl, out[:L], true)
+	}
+
+	copy(d.outBuf[:L], out[:L])
+	d.plcGlueFrames(out[:L])
+	d.cngProcess(&ctrl, out[:L])
+	biquad(out[:L], d.hpB, d.hpA, d.hpState[:], out[:L])
+	d.lagPrev = ctrl.pitchL[nbSubfr-1]
+	return L, usedBytes, err
+}
+
+// decodeParameters 从码流中解出一帧的全部参数和脉冲
+func (d *decoderState) decodeParameters(ctrl *decoderControl, q []int32) {
+	rc := &d.rc
+
+	// 采样率只在包内第一帧出现
+	if d.nFramesDecoded == 0 {
+		ix := rc.decode(samplingRatesCDF, samplingRatesOffset)
+		if ix < 0 || ix > 3 {
+			rc.err = rangeCoderIllegalSamplingRate
+			return
+		}
+		d.setFs(samplingRatesTable[ix])
+	}
+
+	// 信号类型和量化偏移
+	var ix int
+	if d.nFramesDecoded == 0 {
+		ix = rc.decode(typeOffsetCDF, typeOffsetCDFOffset)
+	} else {
+		ix = rc.decode(typeOffsetJointCDF[d.typeOffsetPrev], typeOffsetCDFOffset)
+	}
+	ctrl.sigtype = ix >> 1
+	ctrl.quantOffsetType = ix & 1
+	d.typeOffsetPrev = ix
+
+	// 增益
+	var gainsIndices [nbSubfr]int
+	if d.nFramesDecoded == 0 {
+		gainsIndices[0] = rc.decode(gainCDF[ctrl.sigtype], gainCDFOffset)
+	} else {
+		gainsIndices[0] = rc.decode(deltaGainCDF, deltaGainCDFOffset)
+	}
+	for i := 1; i < nbSubfr; i++ {
+		gainsIndices[i] = rc.decode(deltaGainCDF, deltaGainCDFOffset)
+	}
+	d.gainsDequant(ctrl.gainsQ16[:], gainsIndices[:], d.nFramesDecoded != 0)
+
+	// NLSF
+	cb := d.nlsfCB[ctrl.sigtype]
+	nlsfIndices := cb.decodeIndices(rc)
+	var nlsfQ15, nlsf0Q15 [maxLPCOrder]int32
+	cb.msvqDecode(nlsfQ15[:], nlsfIndices, d.lpcOrder)
+
+	ctrl.nlsfInterpCoefQ2 = rc.decode(nlsfInterpFactorCDF, nlsfInterpFactorOffset)
+	// 刚重置时不做插值
+	if d.firstFrameAfterReset {
+		ctrl.nlsfInterpCoefQ2 = 4
+	}
+
+	nlsf2aStable(ctrl.predCoefQ12[1][:], nlsfQ15[:], d.lpcOrder)
+	if ctrl.nlsfInterpCoefQ2 < 4 {
+		for i := 0; i < d.lpcOrder; i++ {
+			nlsf0Q15[i] = d.prevNLSFQ15[i] + (int32(ctrl.nlsfInterpCoefQ2)*(nlsfQ15[i]-d.prevNLSFQ15[i]))>>2
+		}
+		nlsf2aStable(ctrl.predCoefQ12[0][:], nlsf0Q15[:], d.lpcOrder)
+	} else {
+		ctrl.predCoefQ12[0] = ctrl.predCoefQ12[1]
+	}
+	copy(d.prevNLSFQ15[:d.lpcOrder], nlsfQ15[:d.lpcOrder])
+
+	// 丢包后对 LPC 做带宽扩展
+	if d.lossCnt != 0 {
+		bwexpander(ctrl.predCoefQ12[0][:d.lpcOrder], bweAfterLossQ16)
+		bwexpander(ctrl.predCoefQ12[1][:d.lpcOrder], bweAfterLossQ16)
+	}
+
+	if ctrl.sigtype == sigTypeVoiced {
+		// 基音周期
+		var lagIndex, contourIndex int
+		switch d.fsKHz {
+		case 8:
+			lagIndex = rc.decode(pitchLagNBCDF, pitchLagNBCDFOffset)
+		case 12:
+			lagIndex = rc.decode(pitchLagMBCDF, pitchLagMBCDFOffset)
+		case 16:
+			lagIndex = rc.decode(pitchLagWBCDF, pitchLagWBCDFOffset)
+		default:
+			lagIndex = rc.decode(pitchLagSWBCDF, pitchLagSWBCDFOffset)
+		}
+		if d.fsKHz == 8 {
+			contourIndex = rc.decode(pitchContourNBCDF, pitchContourNBCDFOffset)
+		} else {
+			contourIndex = rc.decode(pitchContourCDF, pitchContourCDFOffset)
+		}
+		decodePitch(lagIndex, contourIndex, ctrl.pitchL[:], d.fsKHz)
+
+		// LTP 增益
+		ctrl.perIndex = rc.decode(ltpPerIndexCDF, ltpPerIndexCDFOffset)
+		cdf := [...][]uint16{ltpGainCDF0, ltpGainCDF1, ltpGainCDF2}[ctrl.perIndex]
+		cbk := [...][]int16{ltpGainVQ0Q14, ltpGainVQ1Q14, ltpGainVQ2Q14}[ctrl.perIndex]
+		for k := 0; k < nbSubfr; k++ {
+			ix := rc.decode(cdf, ltpGainCDFOffsets[ctrl.perIndex])
+			copy(ctrl.ltpCoefQ14[k*ltpOrder:(k+1)*ltpOrder], cbk[ix*ltpOrder:])
+		}
+
+		ix := rc.decode(ltpScaleCDF, ltpScaleOffset)
+		ctrl.ltpScaleQ14 = int32(ltpScalesQ14[ix])
+	} else {
+		ctrl.pitchL = [nbSubfr]int{}
+		ctrl.ltpCoefQ14 = [ltpOrder * nbSubfr]int16{}
+		ctrl.perIndex = 0
+		ctrl.ltpScaleQ14 = 0
+	}
+
+	ctrl.seed = int32(rc.decode(seedCDF, seedOffset))
+	d.decodePulses(ctrl, q)
+	d.vadFlag = rc.decode(vadFlagCDF, vadFlagOffset)
+	d.frameTermination = rc.decode(frameTerminationCDF, frameTerminationOffset)
+
+	_, nBytesUsed := rc.length()
+	d.nBytesLeft = rc.bufferLength - nBytesUsed
+	if d.nBytesLeft < 0 {
+		rc.err = rangeCoderReadBeyondBuffer
+	}
+	if d.nBytesLeft == 0 {
+		rc.checkAfterDecoding()
+	}
+}
+
+// gainsDequant 对数域均匀量化的增益反量化
+func (d *decoderState) gainsDequant(gainQ16 []int32, ind []int, conditional bool) {
+	for k := 0; k < nbSubfr; k++ {
+		if k == 0 && !conditional {
+			d.lastGainIndex = ind[k]
+		} else {
+			d.lastGainIndex += ind[k] + minDeltaGainQuant
+		}
+		gainQ16[k] = log2lin(min32(smulwb(invScaleQ16, int32(d.lastGainIndex))+gainOffset, 3967))
+	}
+}
+
+func decodePitch(lagIndex, contourIndex int, pitchLags []int, fsKHz int) {
+	lag := 2*fsKHz + lagIndex
+	cb := cbLagsStage3
+	if fsKHz == 8 {
+		cb = cbLagsStage2
+	}
+	for i := 0; i < nbSubfr; i++ {
+		pitchLags[i] = lag + cb[i][contourIndex]
+	}
+}
+
+// decodePulses 解码激励脉冲：速率等级、每块脉冲数、shell 拆分、低位和符号
+func (d *decoderState) decodePulses(ctrl *decoderControl, q []int32) {
+	rc := &d.rc
+	ctrl.rateLevelIndex = rc.decode(rateLevelsCDF[ctrl.sigtype], rateLevelsCDFOffset)
+
+	iter := d.frameLength / shellCodecFrameLength
+	var sumPulses, nLshifts [maxFrameLength / shellCodecFrameLength]int
+	cdf := pulsesPerBlockCDF[ctrl.rateLevelIndex]
+	for i := 0; i < iter; i++ {
+		sumPulses[i] = rc.decode(cdf, pulsesPerBlockCDFOffset)
+		for sumPulses[i] == maxPulses+1 {
+			nLshifts[i]++
+			sumPulses[i] = rc.decode(pulsesPerBlockCDF[nRateLevels-1], pulsesPerBlockCDFOffset)
+		}
+	}
+
+	for i := 0; i < iter; i++ {
+		block := q[i*shellCodecFrameLength : (i+1)*shellCodecFrameLength]
+		if sumPulses[i] > 0 {
+			shellDecoder(block, rc, sumPulses[i])
+		} else {
+			for k := range block {
+				block[k] = 0
+			}
+		}
+	}
+
+	for i := 0; i < iter; i++ {
+		if nLshifts[i] == 0 {
+			continue
+		}
+		block := q[i*shellCodecFrameLength : (i+1)*shellCodecFrameLength]
+		for k := range block {
+			absQ := block[k]
+			for j := 0; j < nLshifts[i]; j++ {
+				absQ <<= 1
+				absQ += int32(rc.decode(lsbCDF, 1))
+			}
+			block[k] = absQ
+		}
+	}
+
+	// 符号
+	ix := (nRateLevels-1)*(ctrl.sigtype<<1+ctrl.quantOffsetType) + ctrl.rateLevelIndex
+	cdf = []uint16{0, signCDF[ix], 65535}
+	for i := 0; i < d.frameLength; i++ {
+		if q[i] > 0 {
+			q[i] *= int32(rc.decode(cdf, 1)<<1 - 1)
+		}
+	}
+}
+
+func decodeSplit(rc *rangeDecoder, p int, table []uint16) (int32, int32) {
+	if p <= 0 {
+		return 0, 0
+	}
+	child1 := rc.decode(table[shellCodeTableOffsets[p]:], p>>1)
+	return int32(child1), int32(p - child1)
+}
+
+// shellDecoder 把 16 个样本的脉冲总数逐层二分
+func shellDecoder(pulses0 []int32, rc *rangeDecoder, pulses4 int) {
+	var pulses3 [2]int32
+	var pulses2 [4]int32
+	var pulses1 [8]int32
+
+	pulses3[0], pulses3[1] = decodeSplit(rc, pulses4, shellCodeTable3)
+
+	pulses2[0], pulses2[1] = decodeSplit(rc, int(pulses3[0]), shellCodeTable2)
+
+	pulses1[0], pulses1[1] = decodeSplit(rc, int(pulses2[0]), shellCodeTable1)
+	pulses0[0], pulses0[1] = decodeSplit(rc, int(pulses1[0]), shellCodeTable0)
+	pulses0[2], pulses0[3] = decodeSplit(rc, int(pulses1[1]), shellCodeTable0)
+
+	pulses1[2], pulses1[3] = decodeSplit(rc, int(pulses2[1]), shellCodeTable1)
+	pulses0[4], pulses0[5] = decodeSplit(rc, int(pulses1[2]), shellCodeTable0)
+	pulses0[6], pulses0[7] = decodeSplit(rc, int(pulses1[3]), shellCodeTable0)
+
+	pulses2[2], pulses2[3] = decodeSplit(rc, int(pulses3[1]), shellCodeTable2)
+
+	pulses1[4], pulses1[5] = decodeSplit(rc, int(pulses2[2]), shellCodeTable1)
+	pulses0[8], pulses0[9] = decodeSplit(rc, int(pulses1[4]), shellCodeTable0)
+	pulses0[10], pulses0[11] = decodeSplit(rc, int(pulses1[5]), shellCodeTable0)
+
+	pulses1[6], pulses1[7] = decodeSplit(rc, int(pulses2[3]), shellCodeTable1)
+	pulses0[12], pulses0[13] = decodeSplit(rc, int(pulses1[6]), shellCodeTable0)
+	pulses0[14], pulses0[15] = decodeSplit(rc, int(pulses1[7]), shellCodeTable0)
+}
+
+// Decoder 纯 Go 实现的 SILK 解码器，内部有跨帧状态，每段语音使用一个
+type Decoder struct {
+	st          decoderState
+	resampler   resampler
+	prevFsKHz   int
+	prevAPIRate int
+}
+
+// NewDecoder 创建解码器
+func NewDecoder() *Decoder {
+	d := &Decoder{}
+	d.st.init()
+	return d
+}
+
+// DecodeFrame 解码容器中的一帧（可能包含多个 20ms 内部帧），输出 sampleRate 采样率的 PCM。
+// 空帧视为丢包，做丢包补偿。sampleRate 只支持 24000~48000
+func (dec *Decoder) DecodeFrame(frame []byte, sampleRate int) ([]int16, error) {
+	if sampleRate < SampleRate || sampleRate > 48000 {
+		return nil, fmt.Errorf("unsupported sample rate %d", sampleRate)
+	}
+	if len(frame) > maxArithmBytes {
+		return nil, fmt.Errorf("frame too large: %d bytes", len(frame))
+	}
+	st := &dec.st
+	lost := len(frame) == 0
+	var pcm []int16
+	st.nFramesDecoded = 0
+	for {
+		var internal [maxFrameLength]int16
+		prevFsKHz := st.fsKHz
+		n, used, err := st.decodeFrame(internal[:], frame, lost)
+		if used > 0 && st.nBytesLeft > 0 && st.frameTermination == moreFrames && st.nFramesDecoded < maxFramesPerPacket {
+			st.moreInternalDecoderFrames = true
+		} else {
+			st.moreInternalDecoderFrames = false
+		}
+		pcm = append(pcm, dec.resample(internal[:n], prevFsKHz, sampleRate)...)
+		if err != nil {
+			return pcm, err
+		}
+		if !st.moreInternalDecoderFrames {
+			return pcm, nil
+		}
+	}
+}
+
+// resample 把内部采样率的输出转换为 apiRate
+func (dec *Decoder) resample(in []int16, prevFsKHz int, apiRate int) []int16 {
+	fs := dec.st.fsKHz * 1000
+	defer func() { dec.prevAPIRate = apiRate }()
+	if fs == apiRate {
+		return append([]int16(nil), in...)
+	}
+	if prevFsKHz != dec.st.fsKHz || dec.prevAPIRate != apiRate {
+		dec.resampler.init(fs, apiRate)
+	}
+	out := make([]int16, len(in)*apiRate/fs)
+	dec.resampler.process(out, in)
+	return out
+}
@@ -0,0 +1,265 @@
+// 移植自 SILK SDK 1.0.9，Copyright (c) 2006-2012, Skype Limited. All rights reserved.
+// 许可条款见同目录 LICENSE。
+
+package silk
+
+import "math"
+
+// SILK 是定点实现，这里的函数与 SDK 中同名宏一一对应，
+// 溢出语义保持 int32 回绕，保证与 C 版本逐位一致
+
+func smulwb(a, b int32) int32 {
+	return (a>>16)*int32(int16(b)) + ((a&0xFFFF)*int32(int16(b)))>>16
+}
+
+func smlawb(a, b, c int32) int32 {
+	return a + smulwb(b, c)
+}
+
+func smulwt(a, b int32) int32 {
+	return (a>>16)*(b>>16) + ((a&0xFFFF)*(b>>16))>>16
+}
+
+func smlawt(a, b, c int32) int32 {
+	return a + smulwt(b, c)
+}
+
+func smulbb(a, b int32) int32 {
+	return int32(int16(a)) * int32(int16(b))
+}
+
+func smlabb(a, b, c int32) int32 {
+	return a + int32(int16(b))*int32(int16(c))
+}
+
+func smulww(a, b int32) int32 {
+	return smulwb(a, b) + a*rshiftRound(b, 16)
+}
+
+func smlaww(a, b, c int32) int32 {
+	return smlawb(a, b, c) + b*rshiftRound(c, 16)
+}
+
+func smmul(a, b int32) int32 {
+	return int32((int64(a) * int64(b)) >> 32)
+}
+
+func rshiftRound(a int32, shift uint) int32 {
+	if shift == 1 {
+		return a>>1 + a&1
+	}
+	return (a>>(shift-1) + 1) >> 1
+}
+
+func addSat32(a, b int32) int32 {
+	s := uint32(a) + uint32(b)
+	if s&0x80000000 == 0 {
+		if uint32(a)&uint32(b)&0x80000000 != 0 {
+			return math.MinInt32
+		}
+	} else if (uint32(a)|uint32(b))&0x80000000 == 0 {
+		return math.MaxInt32
+	}
+	return int32(s)
+}
+
+func subSat32(a, b int32) int32 {
+	s := uint32(a) - uint32(b)
+	if s&0x80000000 == 0 {
+		if uint32(a)&(uint32(b)^0x80000000)&0x80000000 != 0 {
+			return math.MinInt32
+		}
+	} else if (uint32(a)^0x80000000)&uint32(b)&0x80000000 != 0 {
+		return math.MaxInt32
+	}
+	return int32(s)
+}
+
+func sat16(a int32) int16 {
+	if a > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if a < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(a)
+}
+
+func limit32(a, l1, l2 int32) int32 {
+	if l1 > l2 {
+		l1, l2 = l2, l1
+	}
+	if a > l2 {
+		return l2
+	}
+	if a < l1 {
+		return l1
+	}
+	return a
+}
+
+// lshiftSat32 先饱和再左移
+func lshiftSat32(a int32, shift uint) int32 {
+	return limit32(a, math.MinInt32>>shift, math.MaxInt32>>shift) << shift
+}
+
+func abs32(a int32) int32 {
+	if a > 0 {
+		return a
+	}
+	return -a
+}
+
+func min32(a, b int32) int32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max32(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// silkRand 线性同余随机数，SKP_RAND
+func silkRand(seed int32) int32 {
+	return int32(907633515 + uint32(seed)*196314165)
+}
+
+func clz32(in int32) int32 {
+	if in == 0 {
+		return 32
+	}
+	n := int32(0)
+	for v := uint32(in); v&0x80000000 == 0; v <<= 1 {
+		n++
+	}
+	return n
+}
+
+func ror32(a int32, rot int32) int32 {
+	x := uint32(a)
+	if rot <= 0 {
+		m := uint32(-rot)
+		return int32(x<<m | x>>(32-m))
+	}
+	r := uint32(rot)
+	return int32(x<<(32-r) | x>>r)
+}
+
+// clzFrac 返回前导零个数和最高位 1 之后的 7 位
+func clzFrac(in int32) (lz, fracQ7 int32) {
+	lz = clz32(in)
+	return lz, ror32(in, 24-lz) & 0x7f
+}
+
+func sqrtApprox(x int32) int32 {
+	if x <= 0 {
+		return 0
+	}
+	lz, fracQ7 := clzFrac(x)
+	var y int32 = 46214 // sqrt(2) * 32768
+	if lz&1 != 0 {
+		y = 32768
+	}
+	y >>= uint(lz >> 1)
+	return smlawb(y, y, smulbb(213, fracQ7))
+}
+
+// div32VarQ 近似计算 (a32 << Qres) / b32
+func div32VarQ(a32, b32 int32, qres int32) int32 {
+	aHeadrm := clz32(abs32(a32)) - 1
+	aNrm := a32 << uint(aHeadrm)
+	bHeadrm := clz32(abs32(b32)) - 1
+	bNrm := b32 << uint(bHeadrm)
+	bInv := (math.MaxInt32 >> 2) / (bNrm >> 16)
+	result := smulwb(aNrm, bInv)
+	aNrm -= smmul(bNrm, result) << 3
+	result = smlawb(result, aNrm, bInv)
+	return shiftResult(result, 29+aHeadrm-bHeadrm-qres)
+}
+
+// inverse32VarQ 近似计算 (1 << Qres) / b32
+func inverse32VarQ(b32 int32, qres int32) int32 {
+	bHeadrm := clz32(abs32(b32)) - 1
+	bNrm := b32 << uint(bHeadrm)
+	bInv := (math.MaxInt32 >> 2) / (bNrm >> 16)
+	result := bInv << 16
+	errQ32 := (-smulwb(bNrm, bInv)) << 3
+	result = smlaww(result, errQ32, bInv)
+	return shiftResult(result, 61-bHeadrm-qres)
+}
+
+func shiftResult(result, lshift int32) int32 {
+	if lshift <= 0 {
+		return lshiftSat32(result, uint(-lshift))
+	}
+	if lshift < 32 {
+		return result >> uint(lshift)
+	}
+	return 0
+}
+
+// log2lin 把 Q7 对数值转换回线性值
+func log2lin(inLogQ7 int32) int32 {
+	if inLogQ7 < 0 {
+		return 0
+	}
+	if inLogQ7 >= 31<<7 {
+		return math.MaxInt32
+	}
+	out := int32(1) << uint(inLogQ7>>7)
+	fracQ7 := inLogQ7 & 0x7F
+	if inLogQ7 < 2048 {
+		return out + (out*smlawb(fracQ7, fracQ7*(128-fracQ7), -174))>>7
+	}
+	return out + (out>>7)*smlawb(fracQ7, fracQ7*(128-fracQ7), -174)
+}
+
+// lin2log 近似计算 128 * log2(inLin)
+func lin2log(inLin int32) int32 {
+	lz, fracQ7 := clzFrac(inLin)
+	return (31-lz)<<7 + smlawb(fracQ7, fracQ7*(128-fracQ7), 179)
+}
+
+// sumSqrShift 计算能量并在溢出前右移，返回能量和移位数。
+// C 版本按 4 字节对齐成对读取，这里按对齐的情况实现
+func sumSqrShift(x []int16) (nrg int32, shift uint) {
+	n := len(x) - 1
+	i := 0
+	for i < n {
+		nrg = smlabbOvflw(nrg, int32(x[i]), int32(x[i]))
+		nrg = smlabbOvflw(nrg, int32(x[i+1]), int32(x[i+1]))
+		i += 2
+		if nrg < 0 {
+			nrg = int32(uint32(nrg) >> 2)
+			shift = 2
+			break
+		}
+	}
+	for ; i < n; i += 2 {
+		tmp := smulbb(int32(x[i]), int32(x[i]))
+		tmp = smlabbOvflw(tmp, int32(x[i+1]), int32(x[i+1]))
+		nrg = int32(uint32(nrg) + uint32(tmp)>>shift)
+		if nrg < 0 {
+			nrg = int32(uint32(nrg) >> 2)
+			shift += 2
+		}
+	}
+	if i == n {
+		tmp := smulbb(int32(x[i]), int32(x[i]))
+		nrg = int32(uint32(nrg) + uint32(tmp)>>shift)
+	}
+	if uint32(nrg)&0xC0000000 != 0 {
+		nrg = int32(uint32(nrg) >> 2)
+		shift += 2
+	}
+	return nrg, shift
+}
+
+func smlabbOvflw(a, b, c int32) int32 {
+	return int32(uint32(a) + uint32(int32(int16(b))*int32(int16(c))))
+}
@@ -0,0 +1,251 @@
+// 移植自 SILK SDK 1.0.9，Copyright (c) 2006-2012, Skype Limited. All rights reserved.
+// 许可条款见同目录 LICENSE。
+
+package silk
+
+import (
+	"math"
+	"sort"
+)
+
+// nlsfCodebook 多级矢量量化的 NLSF 码本
+type nlsfCodebook struct {
+	nStages      int
+	nVectors     []int   // 每一级的矢量个数
+	cbQ15        []int16 // 各级码本依次拼接
+	nDeltaMinQ15 []int32
+	cdf          []uint16
+	startIx      []int // 每一级在 cdf 中的起始位置
+	middleIx     []int
+}
+
+// decodeIndices 依次解码每一级的码本索引
+func (cb *nlsfCodebook) decodeIndices(rc *rangeDecoder) []int {
+	idx := make([]int, cb.nStages)
+	for s := range idx {
+		idx[s] = rc.decode(cb.cdf[cb.startIx[s]:], cb.middleIx[s])
+	}
+	return idx
+}
+
+// msvqDecode 把各级码本矢量相加得到 NLSF 并稳定化
+func (cb *nlsfCodebook) msvqDecode(nlsfQ15 []int32, indices []int, order int) {
+	offset := 0
+	for s := 0; s < cb.nStages; s++ {
+		vec := cb.cbQ15[offset+indices[s]*order:]
+		for i := 0; i < order; i++ {
+			if s == 0 {
+				nlsfQ15[i] = int32(vec[i])
+			} else {
+				nlsfQ15[i] += int32(vec[i])
+			}
+		}
+		offset += cb.nVectors[s] * order
+	}
+	nlsfStabilize(nlsfQ15[:order], cb.nDeltaMinQ15)
+}
+
+// nlsfStabilize 保证相邻 NLSF 之间至少相隔 NDeltaMin
+func nlsfStabilize(nlsf []int32, nDeltaMin []int32) {
+	const maxLoops = 20
+	L := len(nlsf)
+	loops := 0
+	for ; loops < maxLoops; loops++ {
+		minDiff := nlsf[0] - nDeltaMin[0]
+		I := 0
+		for i := 1; i <= L-1; i++ {
+			diff := nlsf[i] - (nlsf[i-1] + nDeltaMin[i])
+			if diff < minDiff {
+				minDiff = diff
+				I = i
+			}
+		}
+		diff := 1<<15 - (nlsf[L-1] + nDeltaMin[L])
+		if diff < minDiff {
+			minDiff = diff
+			I = L
+		}
+		if minDiff >= 0 {
+			return
+		}
+		switch I {
+		case 0:
+			nlsf[0] = nDeltaMin[0]
+		case L:
+			nlsf[L-1] = 1<<15 - nDeltaMin[L]
+		default:
+			var minCenter int32
+			for k := 0; k < I; k++ {
+				minCenter += nDeltaMin[k]
+			}
+			minCenter += nDeltaMin[I] >> 1
+			var maxCenter int32 = 1 << 15
+			for k := L; k > I; k-- {
+				maxCenter -= nDeltaMin[k]
+			}
+			maxCenter -= nDeltaMin[I] - nDeltaMin[I]>>1
+			center := limit32(rshiftRound(nlsf[I-1]+nlsf[I], 1), minCenter, maxCenter)
+			nlsf[I-1] = center - nDeltaMin[I]>>1
+			nlsf[I] = nlsf[I-1] + nDeltaMin[I]
+		}
+	}
+	// 兜底：排序后强制满足最小间隔
+	sort.Slice(nlsf, func(i, j int) bool { return nlsf[i] < nlsf[j] })
+	nlsf[0] = max32(nlsf[0], nDeltaMin[0])
+	for i := 1; i < L; i++ {
+		nlsf[i] = max32(nlsf[i], nlsf[i-1]+nDeltaMin[i])
+	}
+	nlsf[L-1] = min32(nlsf[L-1], 1<<15-nDeltaMin[L])
+	for i := L - 2; i >= 0; i-- {
+		nlsf[i] = min32(nlsf[i], nlsf[i+1]-nDeltaMin[i+1])
+	}
+}
+
+func nlsf2aFindPoly(out []int32, cLSF []int32, dd int) {
+	out[0] = 1 << 20
+	out[1] = -cLSF[0]
+	for k := 1; k < dd; k++ {
+		ftmp := int64(cLSF[2*k])
+		out[k+1] = out[k-1]<<1 - int32(rshiftRound64(ftmp*int64(out[k]), 20))
+		for n := k; n > 1; n-- {
+			out[n] += out[n-2] - int32(rshiftRound64(ftmp*int64(out[n-1]), 20))
+		}
+		out[1] -= int32(ftmp)
+	}
+}
+
+func rshiftRound64(a int64, shift uint) int64 {
+	return (a>>(shift-1) + 1) >> 1
+}
+
+// nlsf2a 把 NLSF（Q15）转换为 Q12 的预测系数
+func nlsf2a(a []int16, nlsf []int32, d int) {
+	var cosLSFQ20 [maxLPCOrder]int32
+	var P, Q [maxLPCOrder/2 + 1]int32
+	var a32 [maxLPCOrder]int32
+
+	for k := 0; k < d; k++ {
+		fInt := nlsf[k] >> (15 - 7)
+		fFrac := nlsf[k] - fInt<<(15-7)
+		cosVal := lsfCosTabQ12[fInt]
+		delta := lsfCosTabQ12[fInt+1] - cosVal
+		cosLSFQ20[k] = cosVal<<8 + delta*fFrac
+	}
+	dd := d >> 1
+	nlsf2aFindPoly(P[:], cosLSFQ20[0:], dd)
+	nlsf2aFindPoly(Q[:], cosLSFQ20[1:], dd)
+
+	for k := 0; k < dd; k++ {
+		ptmp := P[k+1] + P[k]
+		qtmp := Q[k+1] - Q[k]
+		a32[k] = -rshiftRound(ptmp+qtmp, 9)
+		a32[d-k-1] = rshiftRound(qtmp-ptmp, 9)
+	}
+
+	i := 0
+	for ; i < 10; i++ {
+		var maxabs int32
+		idx := 0
+		for k := 0; k < d; k++ {
+			if v := abs32(a32[k]); v > maxabs {
+				maxabs = v
+				idx = k
+			}
+		}
+		if maxabs <= math.MaxInt16 {
+			break
+		}
+		maxabs = min32(maxabs, 98369)
+		scQ16 := 65470 - ((65470>>2)*(maxabs-math.MaxInt16))/((maxabs*int32(idx+1))>>2)
+		bwexpander32(a32[:d], scQ16)
+	}
+	if i == 10 {
+		for k := 0; k < d; k++ {
+			a32[k] = int32(sat16(a32[k]))
+		}
+	}
+	for k := 0; k < d; k++ {
+		a[k] = int16(a32[k])
+	}
+}
+
+// nlsf2aStable 转换并保证滤波器稳定
+func nlsf2aStable(a []int16, nlsf []int32, order int) {
+	nlsf2a(a, nlsf, order)
+	i := 0
+	for ; i < maxLPCStabilizeIterations; i++ {
+		if !lpcInversePredGainUnstable(a[:order]) {
+			break
+		}
+		bwexpander(a[:order], 65536-smulbb(int32(10+i), int32(i)))
+	}
+	if i == maxLPCStabilizeIterations {
+		for k := 0; k < order; k++ {
+			a[k] = 0
+		}
+	}
+}
+
+// bwexpander 对 AR 滤波器做带宽扩展
+func bwexpander(ar []int16, chirpQ16 int32) {
+	d := len(ar)
+	chirpMinusOneQ16 := chirpQ16 - 65536
+	for i := 0; i < d-1; i++ {
+		ar[i] = int16(rshiftRound(chirpQ16*int32(ar[i]), 16))
+		chirpQ16 += rshiftRound(chirpQ16*chirpMinusOneQ16, 16)
+	}
+	ar[d-1] = int16(rshiftRound(chirpQ16*int32(ar[d-1]), 16))
+}
+
+func bwexpander32(ar []int32, chirpQ16 int32) {
+	d := len(ar)
+	tmp := chirpQ16
+	for i := 0; i < d-1; i++ {
+		ar[i] = smulww(ar[i], tmp)
+		tmp = smulww(chirpQ16, tmp)
+	}
+	ar[d-1] = smulww(ar[d-1], tmp)
+}
+
+// lpcInversePredGainUnstable 判断 Q12 预测系数是否不稳定，同时返回逆预测增益（Q30）
+func lpcInversePredGain(aQ12 []int16) (invGainQ30 int32, unstable bool) {
+	const qa = 16
+	const aLimit = 65520 // SKP_FIX_CONST(0.99975, 16)
+	order := len(aQ12)
+	var atmp [2][maxLPCOrder]int32
+	anew := &atmp[order&1]
+	for k := 0; k < order; k++ {
+		anew[k] = int32(aQ12[k]) << (qa - 12)
+	}
+	invGainQ30 = 1 << 30
+	for k := order - 1; k > 0; k-- {
+		if anew[k] > aLimit || anew[k] < -aLimit {
+			return invGainQ30, true
+		}
+		rcQ31 := -(anew[k] << (31 - qa))
+		rcMult1Q30 := int32(math.MaxInt32>>1) - smmul(rcQ31, rcQ31)
+		rcMult2Q16 := inverse32VarQ(rcMult1Q30, 46)
+		invGainQ30 = smmul(invGainQ30, rcMult1Q30) << 2
+
+		aold := anew
+		anew = &atmp[k&1]
+		headrm := clz32(rcMult2Q16) - 1
+		rcMult2Q16 <<= uint(headrm)
+		for n := 0; n < k; n++ {
+			tmp := aold[n] - smmul(aold[k-n-1], rcQ31)<<1
+			anew[n] = smmul(tmp, rcMult2Q16) << uint(16-headrm)
+		}
+	}
+	if anew[0] > aLimit || anew[0] < -aLimit {
+		return invGainQ30, true
+	}
+	rcQ31 := -(anew[0] << (31 - qa))
+	rcMult1Q30 := int32(math.MaxInt32>>1) - smmul(rcQ31, rcQ31)
+	invGainQ30 = smmul(invGainQ30, rcMult1Q30) << 2
+	return invGainQ30, false
+}
+
+func lpcInversePredGainUnstable(aQ12 []int16) bool {
+	_, unstable := lpcInversePredGain(aQ12)
+	return unstable
+}
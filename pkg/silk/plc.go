@@ -0,0 +1,253 @@
+// 移植自 SILK SDK 1.0.9，Copyright (c) 2006-2012, Skype Limited. All rights reserved.
+// 许可条款见同目录 LICENSE。
+
+package silk
+
+// 丢包补偿（PLC）
+const (
+	bweCoefQ16              = 64880 // 0.99
+	vPitchGainStartMinQ14   = 11469 // 0.7
+	vPitchGainStartMaxQ14   = 15565 // 0.95
+	maxPitchLagMs           = 18
+	randBufSize             = 128
+	randBufMask             = randBufSize - 1
+	log2InvLPCGainHighThres = 3
+	log2InvLPCGainLowThres  = 8
+	pitchDriftFacQ16        = 655 // 0.01
+)
+
+var (
+	harmAttQ15            = [2]int32{32440, 31130} // 0.99, 0.95
+	plcRandAttenuateVQ15  = [2]int32{31130, 26214} // 0.95, 0.8
+	plcRandAttenuateUVQ15 = [2]int32{32440, 29491} // 0.99, 0.9
+)
+
+type plcState struct {
+	pitchLQ8        int32
+	ltpCoefQ14      [ltpOrder]int16
+	prevLPCQ12      [maxLPCOrder]int16
+	lastFrameLost   bool
+	randSeed        int32
+	randScaleQ14    int16
+	concEnergy      int32
+	concEnergyShift uint
+	prevLTPScaleQ14 int16
+	prevGainQ16     [nbSubfr]int32
+	fsKHz           int
+}
+
+func (d *decoderState) plcReset() {
+	d.plc.pitchLQ8 = int32(d.frameLength >> 1)
+}
+
+func (d *decoderState) plcProcess(ctrl *decoderControl, signal []int16, lost bool) {
+	if d.fsKHz != d.plc.fsKHz {
+		d.plcReset()
+		d.plc.fsKHz = d.fsKHz
+	}
+	if lost {
+		d.plcConceal(ctrl, signal)
+		d.lossCnt++
+	} else {
+		d.plcUpdate(ctrl)
+	}
+}
+
+// plcUpdate 记录最近一帧的参数，供丢包时外推
+func (d *decoderState) plcUpdate(ctrl *decoderControl) {
+	plc := &d.plc
+	d.prevSigtype = ctrl.sigtype
+	var ltpGainQ14 int32
+	if ctrl.sigtype == sigTypeVoiced {
+		// 找到包含基音脉冲的最后一个子帧
+		for j := 0; j*d.subfrLength < ctrl.pitchL[nbSubfr-1]; j++ {
+			var tmp int32
+			for i := 0; i < ltpOrder; i++ {
+				tmp += int32(ctrl.ltpCoefQ14[(nbSubfr-1-j)*ltpOrder+i])
+			}
+			if tmp > ltpGainQ14 {
+				ltpGainQ14 = tmp
+				copy(plc.ltpCoefQ14[:], ctrl.ltpCoefQ14[(nbSubfr-1-j)*ltpOrder:])
+				plc.pitchLQ8 = int32(ctrl.pitchL[nbSubfr-1-j]) << 8
+			}
+		}
+		// 只保留单抽头
+		plc.ltpCoefQ14 = [ltpOrder]int16{}
+		plc.ltpCoefQ14[ltpOrder/2] = int16(ltpGainQ14)
+
+		if ltpGainQ14 < vPitchGainStartMinQ14 {
+			scaleQ10 := (vPitchGainStartMinQ14 << 10) / max32(ltpGainQ14, 1)
+			for i := range plc.ltpCoefQ14 {
+				plc.ltpCoefQ14[i] = int16(smulbb(int32(plc.ltpCoefQ14[i]), scaleQ10) >> 10)
+			}
+		} else if ltpGainQ14 > vPitchGainStartMaxQ14 {
+			scaleQ14 := (vPitchGainStartMaxQ14 << 14) / max32(ltpGainQ14, 1)
+			for i := range plc.ltpCoefQ14 {
+				plc.ltpCoefQ14[i] = int16(smulbb(int32(plc.ltpCoefQ14[i]), scaleQ14) >> 14)
+			}
+		}
+	} else {
+		plc.pitchLQ8 = smulbb(int32(d.fsKHz), 18) << 8
+		plc.ltpCoefQ14 = [ltpOrder]int16{}
+	}
+
+	copy(plc.prevLPCQ12[:d.lpcOrder], ctrl.predCoefQ12[1][:d.lpcOrder])
+	plc.prevLTPScaleQ14 = int16(ctrl.ltpScaleQ14)
+	plc.prevGainQ16 = ctrl.gainsQ16
+}
+
+// plcConceal 根据上一帧的参数外推出丢失的一帧
+func (d *decoderState) plcConceal(ctrl *decoderControl, signal []int16) {
+	plc := &d.plc
+	var (
+		excBuf [maxFrameLength]int16
+		sigQ10 [maxFrameLength]int32
+	)
+
+	copy(d.sLTPQ16[:d.frameLength], d.sLTPQ16[d.frameLength:2*d.frameLength])
+
+	bwexpander(plc.prevLPCQ12[:d.lpcOrder], bweCoefQ16)
+
+	// 用后两个子帧中能量较低的一个作为随机噪声源
+	n := 0
+	for k := nbSubfr >> 1; k < nbSubfr; k++ {
+		for i := 0; i < d.subfrLength; i++ {
+			excBuf[n] = int16(smulww(d.excQ10[i+k*d.subfrLength], plc.prevGainQ16[k]) >> 10)
+			n++
+		}
+	}
+	energy1, shift1 := sumSqrShift(excBuf[:d.subfrLength])
+	energy2, shift2 := sumSqrShift(excBuf[d.subfrLength : 2*d.subfrLength])
+	var randBuf []int32
+	if energy1>>shift2 < energy2>>shift1 {
+		randBuf = d.excQ10[max(0, 3*d.subfrLength-randBufSize):]
+	} else {
+		randBuf = d.excQ10[max(0, d.frameLength-randBufSize):]
+	}
+
+	bQ14 := plc.ltpCoefQ14[:]
+	randScaleQ14 := plc.randScaleQ14
+
+	att := min(1, d.lossCnt)
+	harmGainQ15 := harmAttQ15[att]
+	randGainQ15 := plcRandAttenuateUVQ15[att]
+	if d.prevSigtype == sigTypeVoiced {
+		randGainQ15 = plcRandAttenuateVQ15[att]
+	}
+
+	// 丢失的第一帧
+	if d.lossCnt == 0 {
+		randScaleQ14 = 1 << 14
+		if d.prevSigtype == sigTypeVoiced {
+			for i := 0; i < ltpOrder; i++ {
+				randScaleQ14 -= bQ14[i]
+			}
+			randScaleQ14 = max(3277, randScaleQ14) // 0.2
+			randScaleQ14 = int16(smulbb(int32(randScaleQ14), int32(plc.prevLTPScaleQ14)) >> 14)
+		}
+		// LPC 增益较高的清音帧降低噪声
+		if d.prevSigtype == sigTypeUnvoiced {
+			invGainQ30, _ := lpcInversePredGain(plc.prevLPCQ12[:d.lpcOrder])
+			downScaleQ30 := min32((1<<30)>>log2InvLPCGainHighThres, invGainQ30)
+			downScaleQ30 = max32((1<<30)>>log2InvLPCGainLowThres, downScaleQ30)
+			downScaleQ30 <<= log2InvLPCGainHighThres
+			randGainQ15 = smulwb(downScaleQ30, randGainQ15) >> 14
+		}
+	}
+
+	randSeed := plc.randSeed
+	lag := int(rshiftRound(plc.pitchLQ8, 8))
+	sLTPBufIdx := d.frameLength
+
+	// LTP 合成
+	n = 0
+	for k := 0; k < nbSubfr; k++ {
+		predLag := sLTPBufIdx - lag + ltpOrder/2
+		for i := 0; i < d.subfrLength; i++ {
+			randSeed = silkRand(randSeed)
+			idx := (randSeed >> 25) & randBufMask
+
+			p := d.sLTPQ16[predLag-4 : predLag+1]
+			ltpPredQ14 := smulwb(p[4], int32(bQ14[0]))
+			ltpPredQ14 = smlawb(ltpPredQ14, p[3], int32(bQ14[1]))
+			ltpPredQ14 = smlawb(ltpPredQ14, p[2], int32(bQ14[2]))
+			ltpPredQ14 = smlawb(ltpPredQ14, p[1], int32(bQ14[3]))
+			ltpPredQ14 = smlawb(ltpPredQ14, p[0], int32(bQ14[4]))
+			predLag++
+
+			lpcExcQ10 := smulwb(randBuf[idx], int32(randScaleQ14)) << 2
+			lpcExcQ10 += rshiftRound(ltpPredQ14, 4)
+
+			d.sLTPQ16[sLTPBufIdx] = lpcExcQ10 << 6
+			sLTPBufIdx++
+			sigQ10[n] = lpcExcQ10
+			n++
+		}
+		// 逐步减小 LTP 增益和激励增益
+		for j := 0; j < ltpOrder; j++ {
+			bQ14[j] = int16(smulbb(harmGainQ15, int32(bQ14[j])) >> 15)
+		}
+		randScaleQ14 = int16(smulbb(int32(randScaleQ14), randGainQ15) >> 15)
+
+		// 基音周期缓慢增大
+		plc.pitchLQ8 += smulwb(plc.pitchLQ8, pitchDriftFacQ16)
+		plc.pitchLQ8 = min32(plc.pitchLQ8, smulbb(maxPitchLagMs, int32(d.fsKHz))<<8)
+		lag = int(rshiftRound(plc.pitchLQ8, 8))
+	}
+
+	// LPC 合成
+	aQ12 := plc.prevLPCQ12[:d.lpcOrder]
+	n = 0
+	for k := 0; k < nbSubfr; k++ {
+		for i := 0; i < d.subfrLength; i++ {
+			lpcPredQ10 := lpcPrediction(d.sLPCQ14[maxLPCOrder+i-d.lpcOrder:maxLPCOrder+i], aQ12)
+			sigQ10[n] += lpcPredQ10
+			d.sLPCQ14[maxLPCOrder+i] = sigQ10[n] << 4
+			n++
+		}
+		copy(d.sLPCQ14[:maxLPCOrder], d.sLPCQ14[d.subfrLength:d.subfrLength+maxLPCOrder])
+	}
+
+	for i := 0; i < d.frameLength; i++ {
+		signal[i] = sat16(rshiftRound(smulww(sigQ10[i], plc.prevGainQ16[nbSubfr-1]), 10))
+	}
+
+	plc.randSeed = randSeed
+	plc.randScaleQ14 = randScaleQ14
+	for i := range ctrl.pitchL {
+		ctrl.pitchL[i] = lag
+	}
+}
+
+// plcGlueFrames 丢包后收到的第一帧做能量渐变，和补偿帧平滑衔接
+func (d *decoderState) plcGlueFrames(signal []int16) {
+	plc := &d.plc
+	if d.lossCnt != 0 {
+		plc.concEnergy, plc.concEnergyShift = sumSqrShift(signal)
+		plc.lastFrameLost = true
+		return
+	}
+	if plc.lastFrameLost {
+		energy, energyShift := sumSqrShift(signal)
+		if energyShift > plc.concEnergyShift {
+			plc.concEnergy >>= energyShift - plc.concEnergyShift
+		} else if energyShift < plc.concEnergyShift {
+			energy >>= plc.concEnergyShift - energyShift
+		}
+		if energy > plc.concEnergy {
+			lz := clz32(plc.concEnergy) - 1
+			plc.concEnergy <<= uint(lz)
+			energy >>= uint(max32(24-lz, 0))
+			fracQ24 := plc.concEnergy / max32(energy, 1)
+
+			gainQ12 := sqrtApprox(fracQ24)
+			slopeQ12 := (1<<12 - gainQ12) / int32(len(signal))
+			for i := range signal {
+				signal[i] = int16((gainQ12 * int32(signal[i])) >> 12)
+				gainQ12 += slopeQ12
+				gainQ12 = min32(gainQ12, 1<<12)
+			}
+		}
+	}
+	plc.lastFrameLost = false
+}
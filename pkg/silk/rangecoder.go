@@ -0,0 +1,146 @@
+// 移植自 SILK SDK 1.0.9，Copyright (c) 2006-2012, Skype Limited. All rights reserved.
+// 许可条款见同目录 LICENSE。
+
+package silk
+
+const maxArithmBytes = 1024
+
+// 区间解码错误码，与 SDK 保持一致
+const (
+	rangeCoderCDFOutOfRange       = -2
+	rangeCoderNormalizationFailed = -3
+	rangeCoderZeroIntervalWidth   = -4
+	rangeCoderDecoderCheckFailed  = -5
+	rangeCoderReadBeyondBuffer    = -6
+	rangeCoderIllegalSamplingRate = -7
+	rangeCoderDecPayloadTooLong   = -8
+)
+
+// rangeDecoder 区间解码器。
+// buffer 跨帧复用且不清零，读取位置可能越过本帧数据几个字节，与 C 版本行为一致
+type rangeDecoder struct {
+	bufferLength int
+	bufferIx     int
+	baseQ32      uint32
+	rangeQ16     uint32
+	err          int
+	buffer       [maxArithmBytes]byte
+}
+
+func (rc *rangeDecoder) init(data []byte) {
+	if len(data) > maxArithmBytes {
+		rc.err = rangeCoderDecPayloadTooLong
+		return
+	}
+	copy(rc.buffer[:], data)
+	rc.bufferLength = len(data)
+	rc.bufferIx = 0
+	rc.baseQ32 = uint32(rc.buffer[0])<<24 | uint32(rc.buffer[1])<<16 | uint32(rc.buffer[2])<<8 | uint32(rc.buffer[3])
+	rc.rangeQ16 = 0xFFFF
+	rc.err = 0
+}
+
+// decode 按累积分布表 prob 解码一个符号，probIx 为查找起点
+func (rc *rangeDecoder) decode(prob []uint16, probIx int) int {
+	if rc.err != 0 {
+		return 0
+	}
+	baseQ32 := rc.baseQ32
+	rangeQ16 := rc.rangeQ16
+	bufferIx := rc.bufferIx
+	buffer := rc.buffer[4:]
+
+	var lowQ16 uint32
+	highQ16 := uint32(prob[probIx])
+	baseTmp := rangeQ16 * highQ16
+	if baseTmp > baseQ32 {
+		for {
+			probIx--
+			lowQ16 = uint32(prob[probIx])
+			baseTmp = rangeQ16 * lowQ16
+			if baseTmp <= baseQ32 {
+				break
+			}
+			highQ16 = lowQ16
+			if highQ16 == 0 {
+				rc.err = rangeCoderCDFOutOfRange
+				return 0
+			}
+		}
+	} else {
+		for {
+			lowQ16 = highQ16
+			probIx++
+			highQ16 = uint32(prob[probIx])
+			baseTmp = rangeQ16 * highQ16
+			if baseTmp > baseQ32 {
+				probIx--
+				break
+			}
+			if highQ16 == 0xFFFF {
+				rc.err = rangeCoderCDFOutOfRange
+				return 0
+			}
+		}
+	}
+	data := probIx
+	baseQ32 -= rangeQ16 * lowQ16
+	rangeQ32 := rangeQ16 * (highQ16 - lowQ16)
+
+	if rangeQ32&0xFF000000 != 0 {
+		rangeQ16 = rangeQ32 >> 16
+	} else {
+		if rangeQ32&0xFFFF0000 != 0 {
+			rangeQ16 = rangeQ32 >> 8
+			if baseQ32>>24 != 0 {
+				rc.err = rangeCoderNormalizationFailed
+				return 0
+			}
+		} else {
+			rangeQ16 = rangeQ32
+			if baseQ32>>16 != 0 {
+				rc.err = rangeCoderNormalizationFailed
+				return 0
+			}
+			baseQ32 <<= 8
+			if bufferIx < rc.bufferLength {
+				baseQ32 |= uint32(buffer[bufferIx])
+				bufferIx++
+			}
+		}
+		baseQ32 <<= 8
+		if bufferIx < rc.bufferLength {
+			baseQ32 |= uint32(buffer[bufferIx])
+			bufferIx++
+		}
+	}
+	if rangeQ16 == 0 {
+		rc.err = rangeCoderZeroIntervalWidth
+		return 0
+	}
+	rc.baseQ32 = baseQ32
+	rc.rangeQ16 = rangeQ16
+	rc.bufferIx = bufferIx
+	return data
+}
+
+// length 返回已消耗的位数和字节数
+func (rc *rangeDecoder) length() (nBits, nBytes int) {
+	nBits = rc.bufferIx<<3 + int(clz32(int32(rc.rangeQ16-1))) - 14
+	return nBits, (nBits + 7) >> 3
+}
+
+// checkAfterDecoding 检查最后一个字节剩余的位是否全为 1
+func (rc *rangeDecoder) checkAfterDecoding() {
+	bits, nBytes := rc.length()
+	if nBytes-1 >= rc.bufferLength {
+		rc.err = rangeCoderDecoderCheckFailed
+		return
+	}
+	if bits&7 != 0 {
+		mask := byte(0xFF >> uint(bits&7))
+		if rc.buffer[nBytes-1]&mask != mask {
+			rc.err = rangeCoderDecoderCheckFailed
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// 移植自 SILK SDK 1.0.9，Copyright (c) 2006-2012, Skype Limited. All rights reserved.
+// 许可条款见同目录 LICENSE。
+
+package silk
+
+// 只实现升采样：内部采样率不超过 24kHz，输出采样率不低于 24kHz
+const (
+	resamplerOrderFIR144    = 6
+	resamplerMaxBatchSizeIn = 480
+)
+
+type resampler struct {
+	sIIR        [6]int32
+	sFIR        [resamplerOrderFIR144]int16
+	batchSize   int
+	invRatioQ16 int32
+	up2Only     bool // 正好 2 倍时直接用 up2HQ
+}
+
+func (r *resampler) init(fsIn, fsOut int) {
+	*r = resampler{}
+	r.batchSize = fsIn / 100
+	if fsOut == 2*fsIn {
+		r.up2Only = true
+	}
+	const up2 = 1
+	r.invRatioQ16 = int32((fsIn<<(14+up2))/fsOut) << 2
+	for smulww(r.invRatioQ16, int32(fsOut)) < int32(fsIn<<up2) {
+		r.invRatioQ16++
+	}
+}
+
+func (r *resampler) process(out, in []int16) {
+	if r.up2Only {
+		up2HQ(r.sIIR[:], out, in)
+		return
+	}
+	r.iirFIR(out, in)
+}
+
+// iirFIR 先 2 倍升采样，再用 144 相位的分数延时 FIR 插值
+func (r *resampler) iirFIR(out, in []int16) {
+	var buf [2*resamplerMaxBatchSizeIn + resamplerOrderFIR144]int16
+	copy(buf[:resamplerOrderFIR144], r.sFIR[:])
+
+	var nSamplesIn int
+	for {
+		nSamplesIn = min(len(in), r.batchSize)
+		up2HQ(r.sIIR[:], buf[resamplerOrderFIR144:], in[:nSamplesIn])
+
+		maxIndexQ16 := int32(nSamplesIn) << 17
+		for indexQ16 := int32(0); indexQ16 < maxIndexQ16; indexQ16 += r.invRatioQ16 {
+			ti := smulwb(indexQ16&0xFFFF, 144)
+			p := buf[indexQ16>>16:]
+			lo := resamplerFracFIR144[ti]
+			hi := resamplerFracFIR144[143-ti]
+			resQ15 := smulbb(int32(p[0]), int32(lo[0]))
+			resQ15 = smlabb(resQ15, int32(p[1]), int32(lo[1]))
+			resQ15 = smlabb(resQ15, int32(p[2]), int32(lo[2]))
+			resQ15 = smlabb(resQ15, int32(p[3]), int32(hi[2]))
+			resQ15 = smlabb(resQ15, int32(p[4]), int32(hi[1]))
+			resQ15 = smlabb(resQ15, int32(p[5]), int32(hi[0]))
+			out[0] = sat16(rshiftRound(resQ15, 15))
+			out = out[1:]
+		}
+		in = in[nSamplesIn:]
+		if len(in) == 0 {
+			break
+		}
+		copy(buf[:resamplerOrderFIR144], buf[nSamplesIn<<1:])
+	}
+	copy(r.sFIR[:], buf[nSamplesIn<<1:])
+}
+
+// up2HQ 高质量 2 倍升采样：两级全通滤波加一个略高于奈奎斯特频率的陷波
+func up2HQ(s []int32, out, in []int16) {
+	for k := range in {
+		in32 := int32(in[k]) << 10
+
+		// 偶数输出样本
+		y := in32 - s[0]
+		x := smulwb(y, int32(resamplerUp2HQ0[0]))
+		out1 := s[0] + x
+		s[0] = in32 + x
+
+		y = out1 - s[1]
+		x = smlawb(y, y, int32(resamplerUp2HQ0[1]))
+		out2 := s[1] + x
+		s[1] = out1 + x
+
+		out2 = smlawb(out2, s[5], int32(resamplerUp2HQNotch[2]))
+		out2 = smlawb(out2, s[4], int32(resamplerUp2HQNotch[1]))
+		out1 = smlawb(out2, s[4], int32(resamplerUp2HQNotch[0]))
+		s[5] = out2 - s[5]
+		out[2*k] = sat16(smlawb(256, out1, int32(resamplerUp2HQNotch[3])) >> 9)
+
+		// 奇数输出样本
+		y = in32 - s[2]
+		x = smulwb(y, int32(resamplerUp2HQ1[0]))
+		out1 = s[2] + x
+		s[2] = in32 + x
+
+		y = out1 - s[3]
+		x = smlawb(y, y, int32(resamplerUp2HQ1[1]))
+		out2 = s[3] + x
+		s[3] = out1 + x
+
+		out2 = smlawb(out2, s[4], int32(resamplerUp2HQNotch[2]))
+		out2 = smlawb(out2, s[5], int32(resamplerUp2HQNotch[1]))
+		out1 = smlawb(out2, s[5], int32(resamplerUp2HQNotch[0]))
+		s[4] = out2 - s[4]
+		out[2*k+1] = sat16(smlawb(256, out1, int32(resamplerUp2HQNotch[3])) >> 9)
+	}
+}
@@ -0,0 +1,134 @@
+// Package silk 解析微信语音使用的 SILK v3 容器，并把解码后的 PCM 封装成 WAV。
+//
+// 微信语音文件格式：可选的 0x02 前缀 + "#!SILK_V3" + 若干帧，
+// 每帧为 2 字节小端长度 + 帧数据，以长度 0xFFFF 或文件结尾结束。
+package silk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	Header = "#!SILK_V3"
+	// 微信语音默认采样率
+	SampleRate = 24000
+	// 每帧 20ms
+	FrameMs = 20
+)
+
+var (
+	ErrNotSilk = errors.New("not silk v3 data")
+	// ErrDecoderUnavailable 没有注册帧解码器时返回
+	ErrDecoderUnavailable = errors.New("silk frame decoder unavailable")
+)
+
+// FrameDecoder 把一帧 SILK 数据解码成 PCM（16 位单声道）
+type FrameDecoder interface {
+	DecodeFrame(frame []byte, sampleRate int) ([]int16, error)
+}
+
+// NewFrameDecoder 创建帧解码器，解码器内部有跨帧状态，每段语音需要单独创建。
+// 默认使用包内的纯 Go 解码器
+var NewFrameDecoder = func() FrameDecoder { return NewDecoder() }
+
+// IsSilk 判断是否为 SILK v3 数据
+func IsSilk(data []byte) bool {
+	return bytes.HasPrefix(trimPrefix(data), []byte(Header))
+}
+
+// 微信的语音在 "#!SILK_V3" 前面多了一个 0x02
+func trimPrefix(data []byte) []byte {
+	if len(data) > 0 && data[0] == 0x02 {
+		return data[1:]
+	}
+	return data
+}
+
+// Frames 拆分 SILK v3 容器中的所有帧
+func Frames(data []byte) ([][]byte, error) {
+	data = trimPrefix(data)
+	if !bytes.HasPrefix(data, []byte(Header)) {
+		return nil, ErrNotSilk
+	}
+	data = data[len(Header):]
+	var frames [][]byte
+	for len(data) >= 2 {
+		n := int(binary.LittleEndian.Uint16(data))
+		data = data[2:]
+		if n == 0xFFFF {
+			break
+		}
+		if n > len(data) {
+			return frames, fmt.Errorf("truncated frame: want %d bytes, got %d", n, len(data))
+		}
+		frames = append(frames, data[:n])
+		data = data[n:]
+	}
+	return frames, nil
+}
+
+// Duration 根据帧数估算语音时长（毫秒）
+func Duration(data []byte) (int, error) {
+	frames, err := Frames(data)
+	return len(frames) * FrameMs, err
+}
+
+// Decode 把 SILK v3 数据解码成 PCM
+func Decode(data []byte, sampleRate int) ([]int16, error) {
+	if NewFrameDecoder == nil {
+		return nil, ErrDecoderUnavailable
+	}
+	frames, err := Frames(data)
+	if err != nil && len(frames) == 0 {
+		return nil, err
+	}
+	dec := NewFrameDecoder()
+	var pcm []int16
+	for i, frame := range frames {
+		out, err := dec.DecodeFrame(frame, sampleRate)
+		if err != nil {
+			return pcm, fmt.Errorf("decode frame %d: %v", i, err)
+		}
+		pcm = append(pcm, out...)
+	}
+	return pcm, nil
+}
+
+// ToWAV 把 SILK v3 数据解码并封装为 WAV
+func ToWAV(data []byte, sampleRate int) ([]byte, error) {
+	pcm, err := Decode(data, sampleRate)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := WriteWAV(&buf, pcm, sampleRate); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteWAV 写入 16 位单声道 PCM WAV
+func WriteWAV(w io.Writer, pcm []int16, sampleRate int) error {
+	const (
+		channels      = 1
+		bitsPerSample = 16
+	)
+	dataSize := uint32(len(pcm) * 2)
+	blockAlign := uint16(channels * bitsPerSample / 8)
+	header := []any{
+		[]byte("RIFF"), 36 + dataSize, []byte("WAVE"),
+		[]byte("fmt "), uint32(16), uint16(1), uint16(channels), uint32(sampleRate),
+		uint32(sampleRate) * uint32(blockAlign), blockAlign, uint16(bitsPerSample),
+		[]byte("data"), dataSize,
+	}
+	for _, v := range header {
+		if err := binary.Write(w, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+	return binary.Write(w, binary.LittleEndian, pcm)
+}
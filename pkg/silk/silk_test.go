@@ -0,0 +1,75 @@
+package silk
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"testing"
+)
+
+// testdata 下的样本由 SILK SDK 1.0.9 编码，期望值是官方 C 解码器在 24kHz 下输出 PCM 的 SHA-256
+func TestDecodeFixtures(t *testing.T) {
+	cases := []struct {
+		name    string
+		samples int
+		sha256  string
+	}{
+		{"v24", 36000, "d27cf87b5fd9c2106de6d581ab1193df1a646bae7b663142f29454ff801f2b92"}, // 第 30 帧丢失
+		{"v16", 24000, "d77026f0a1f4e7fc0ea27c5e68a3ffe4da60ca29ad3c4184a113ace0ee0e7832"},
+		{"v12", 14400, "37aa4eb8920c5bd5cbb74f202114b72aa3998d82d01a4e9cba21a98382c6f272"},
+		{"v8", 24000, "dda9eb79070995482e3eeb43fffc299c3707cfb1112506a520b329bd7080533e"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data, err := os.ReadFile("testdata/" + c.name + ".silk")
+			if err != nil {
+				t.Fatal(err)
+			}
+			pcm, err := Decode(data, SampleRate)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if len(pcm) != c.samples {
+				t.Fatalf("samples = %d, want %d", len(pcm), c.samples)
+			}
+			raw := make([]byte, len(pcm)*2)
+			for i, v := range pcm {
+				binary.LittleEndian.PutUint16(raw[i*2:], uint16(v))
+			}
+			sum := sha256.Sum256(raw)
+			if got := hex.EncodeToString(sum[:]); got != c.sha256 {
+				t.Errorf("pcm sha256 = %s, want %s", got, c.sha256)
+			}
+		})
+	}
+}
+
+func TestToWAV(t *testing.T) {
+	data, err := os.ReadFile("testdata/v16.silk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wav, err := ToWAV(data, SampleRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(wav[:4]) != "RIFF" || string(wav[8:12]) != "WAVE" {
+		t.Fatalf("bad wav header % x", wav[:12])
+	}
+	if rate := binary.LittleEndian.Uint32(wav[24:]); rate != SampleRate {
+		t.Errorf("sample rate = %d", rate)
+	}
+	if size := binary.LittleEndian.Uint32(wav[40:]); size != 24000*2 {
+		t.Errorf("data size = %d", size)
+	}
+	if ms, _ := Duration(data); ms != 1000 {
+		t.Errorf("duration = %d", ms)
+	}
+}
+
+func TestDecodeFrameSampleRate(t *testing.T) {
+	if _, err := NewDecoder().DecodeFrame(nil, 16000); err == nil {
+		t.Error("expected error for unsupported sample rate")
+	}
+}
@@ -0,0 +1,570 @@
+// 移植自 SILK SDK 1.0.9，Copyright (c) 2006-2012, Skype Limited. All rights reserved.
+// 许可条款见同目录 LICENSE。
+
+package silk
+
+// 以下表格由 SILK SDK 的 C 源码转换而来，不要手工修改数值
+
+var samplingRatesTable = []int{
+	8, 12, 16, 24,
+}
+
+var samplingRatesCDF = []uint16{
+	0, 16000, 32000, 48000, 65535,
+}
+
+const samplingRatesOffset = 2
+
+var typeOffsetCDF = []uint16{
+	0, 37522, 41030, 44212, 65535,
+}
+
+var typeOffsetJointCDF = [][]uint16{
+	{0, 57686, 61230, 62358, 65535},
+	{0, 18346, 40067, 43659, 65535},
+	{0, 22694, 24279, 35507, 65535},
+	{0, 6067, 7215, 13010, 65535},
+}
+
+const typeOffsetCDFOffset = 2
+
+var gainCDF = [][]uint16{
+	{0, 18, 45, 94, 181, 320, 519, 777, 1093, 1468, 1909, 2417, 2997, 3657, 4404, 5245, 6185, 7228, 8384, 9664, 11069, 12596, 14244, 16022, 17937, 19979, 22121, 24345, 26646, 29021, 31454, 33927, 36438, 38982, 41538, 44068, 46532, 48904, 51160, 53265, 55184, 56904, 58422, 59739, 60858, 61793, 62568, 63210, 63738, 64165, 64504, 64769, 64976, 65133, 65249, 65330, 65386, 65424, 65451, 65471, 65487, 65501, 65513, 65524, 65535},
+	{0, 214, 581, 1261, 2376, 3920, 5742, 7632, 9449, 11157, 12780, 14352, 15897, 17427, 18949, 20462, 21957, 23430, 24889, 26342, 27780, 29191, 30575, 31952, 33345, 34763, 36200, 37642, 39083, 40519, 41930, 43291, 44602, 45885, 47154, 48402, 49619, 50805, 51959, 53069, 54127, 55140, 56128, 57101, 58056, 58979, 59859, 60692, 61468, 62177, 62812, 63368, 63845, 64242, 64563, 64818, 65023, 65184, 65306, 65391, 65447, 65482, 65505, 65521, 65535},
+}
+
+const gainCDFOffset = 32
+
+var deltaGainCDF = []uint16{
+	0, 2358, 3856, 7023, 15376, 53058, 59135, 61555, 62784, 63498, 63949, 64265,
+	64478, 64647, 64783, 64894, 64986, 65052, 65113, 65169, 65213, 65252, 65284, 65314,
+	65338, 65359, 65377, 65392, 65403, 65415, 65424, 65432, 65440, 65448, 65455, 65462,
+	65470, 65477, 65484, 65491, 65499, 65506, 65513, 65521, 65528, 65535,
+}
+
+const deltaGainCDFOffset = 5
+
+var nlsfInterpFactorCDF = []uint16{
+	0, 3706, 8703, 19226, 30926, 65535,
+}
+
+const nlsfInterpFactorOffset = 4
+
+var pitchLagNBCDF = []uint16{
+	0, 194, 395, 608, 841, 1099, 1391, 1724, 2105, 2544, 3047, 3624,
+	4282, 5027, 5865, 6799, 7833, 8965, 10193, 11510, 12910, 14379, 15905, 17473,
+	19065, 20664, 22252, 23814, 25335, 26802, 28206, 29541, 30803, 31992, 33110, 34163,
+	35156, 36098, 36997, 37861, 38698, 39515, 40319, 41115, 41906, 42696, 43485, 44273,
+	45061, 45847, 46630, 47406, 48175, 48933, 49679, 50411, 51126, 51824, 52502, 53161,
+	53799, 54416, 55011, 55584, 56136, 56666, 57174, 57661, 58126, 58570, 58993, 59394,
+	59775, 60134, 60472, 60790, 61087, 61363, 61620, 61856, 62075, 62275, 62458, 62625,
+	62778, 62918, 63045, 63162, 63269, 63368, 63459, 63544, 63623, 63698, 63769, 63836,
+	63901, 63963, 64023, 64081, 64138, 64194, 64248, 64301, 64354, 64406, 64457, 64508,
+	64558, 64608, 64657, 64706, 64754, 64803, 64851, 64899, 64946, 64994, 65041, 65088,
+	65135, 65181, 65227, 65272, 65317, 65361, 65405, 65449, 65492, 65535,
+}
+
+const pitchLagNBCDFOffset = 43
+
+var pitchLagMBCDF = []uint16{
+	0, 132, 266, 402, 542, 686, 838, 997, 1167, 1349, 1546, 1760,
+	1993, 2248, 2528, 2835, 3173, 3544, 3951, 4397, 4882, 5411, 5984, 6604,
+	7270, 7984, 8745, 9552, 10405, 11300, 12235, 13206, 14209, 15239, 16289, 17355,
+	18430, 19507, 20579, 21642, 22688, 23712, 24710, 25677, 26610, 27507, 28366, 29188,
+	29971, 30717, 31427, 32104, 32751, 33370, 33964, 34537, 35091, 35630, 36157, 36675,
+	37186, 37692, 38195, 38697, 39199, 39701, 40206, 40713, 41222, 41733, 42247, 42761,
+	43277, 43793, 44309, 44824, 45336, 45845, 46351, 46851, 47347, 47836, 48319, 48795,
+	49264, 49724, 50177, 50621, 51057, 51484, 51902, 52312, 52714, 53106, 53490, 53866,
+	54233, 54592, 54942, 55284, 55618, 55944, 56261, 56571, 56873, 57167, 57453, 57731,
+	58001, 58263, 58516, 58762, 58998, 59226, 59446, 59656, 59857, 60050, 60233, 60408,
+	60574, 60732, 60882, 61024, 61159, 61288, 61410, 61526, 61636, 61742, 61843, 61940,
+	62033, 62123, 62210, 62293, 62374, 62452, 62528, 62602, 62674, 62744, 62812, 62879,
+	62945, 63009, 63072, 63135, 63196, 63256, 63316, 63375, 63434, 63491, 63549, 63605,
+	63661, 63717, 63772, 63827, 63881, 63935, 63988, 64041, 64094, 64147, 64199, 64252,
+	64304, 64356, 64409, 64461, 64513, 64565, 64617, 64669, 64721, 64773, 64824, 64875,
+	64925, 64975, 65024, 65072, 65121, 65168, 65215, 65262, 65308, 65354, 65399, 65445,
+	65490, 65535,
+}
+
+const pitchLagMBCDFOffset = 64
+
+var pitchLagWBCDF = []uint16{
+	0, 106, 213, 321, 429, 539, 651, 766, 884, 1005, 1132, 1264,
+	1403, 1549, 1705, 1870, 2047, 2236, 2439, 2658, 2893, 3147, 3420, 3714,
+	4030, 4370, 4736, 5127, 5546, 5993, 6470, 6978, 7516, 8086, 8687, 9320,
+	9985, 10680, 11405, 12158, 12938, 13744, 14572, 15420, 16286, 17166, 18057, 18955,
+	19857, 20759, 21657, 22547, 23427, 24293, 25141, 25969, 26774, 27555, 28310, 29037,
+	29736, 30406, 31048, 31662, 32248, 32808, 33343, 33855, 34345, 34815, 35268, 35704,
+	36127, 36537, 36938, 37330, 37715, 38095, 38471, 38844, 39216, 39588, 39959, 40332,
+	40707, 41084, 41463, 41844, 42229, 42615, 43005, 43397, 43791, 44186, 44583, 44982,
+	45381, 45780, 46179, 46578, 46975, 47371, 47765, 48156, 48545, 48930, 49312, 49690,
+	50064, 50433, 50798, 51158, 51513, 51862, 52206, 52544, 52877, 53204, 53526, 53842,
+	54152, 54457, 54756, 55050, 55338, 55621, 55898, 56170, 56436, 56697, 56953, 57204,
+	57449, 57689, 57924, 58154, 58378, 58598, 58812, 59022, 59226, 59426, 59620, 59810,
+	59994, 60173, 60348, 60517, 60681, 60840, 60993, 61141, 61284, 61421, 61553, 61679,
+	61800, 61916, 62026, 62131, 62231, 62326, 62417, 62503, 62585, 62663, 62737, 62807,
+	62874, 62938, 62999, 63057, 63113, 63166, 63217, 63266, 63314, 63359, 63404, 63446,
+	63488, 63528, 63567, 63605, 63642, 63678, 63713, 63748, 63781, 63815, 63847, 63879,
+	63911, 63942, 63973, 64003, 64033, 64063, 64092, 64121, 64150, 64179, 64207, 64235,
+	64263, 64291, 64319, 64347, 64374, 64401, 64428, 64455, 64481, 64508, 64534, 64560,
+	64585, 64610, 64635, 64660, 64685, 64710, 64734, 64758, 64782, 64807, 64831, 64855,
+	64878, 64902, 64926, 64950, 64974, 64998, 65022, 65045, 65069, 65093, 65116, 65139,
+	65163, 65186, 65209, 65231, 65254, 65276, 65299, 65321, 65343, 65364, 65386, 65408,
+	65429, 65450, 65471, 65493, 65514, 65535,
+}
+
+const pitchLagWBCDFOffset = 86
+
+var pitchLagSWBCDF = []uint16{
+	0, 253, 505, 757, 1008, 1258, 1507, 1755, 2003, 2249, 2494, 2738,
+	2982, 3225, 3469, 3713, 3957, 4202, 4449, 4698, 4949, 5203, 5460, 5720,
+	5983, 6251, 6522, 6798, 7077, 7361, 7650, 7942, 8238, 8539, 8843, 9150,
+	9461, 9775, 10092, 10411, 10733, 11057, 11383, 11710, 12039, 12370, 12701, 13034,
+	13368, 13703, 14040, 14377, 14716, 15056, 15398, 15742, 16087, 16435, 16785, 17137,
+	17492, 17850, 18212, 18577, 18946, 19318, 19695, 20075, 20460, 20849, 21243, 21640,
+	22041, 22447, 22856, 23269, 23684, 24103, 24524, 24947, 25372, 25798, 26225, 26652,
+	27079, 27504, 27929, 28352, 28773, 29191, 29606, 30018, 30427, 30831, 31231, 31627,
+	32018, 32404, 32786, 33163, 33535, 33902, 34264, 34621, 34973, 35320, 35663, 36000,
+	36333, 36662, 36985, 37304, 37619, 37929, 38234, 38535, 38831, 39122, 39409, 39692,
+	39970, 40244, 40513, 40778, 41039, 41295, 41548, 41796, 42041, 42282, 42520, 42754,
+	42985, 43213, 43438, 43660, 43880, 44097, 44312, 44525, 44736, 44945, 45153, 45359,
+	45565, 45769, 45972, 46175, 46377, 46578, 46780, 46981, 47182, 47383, 47585, 47787,
+	47989, 48192, 48395, 48599, 48804, 49009, 49215, 49422, 49630, 49839, 50049, 50259,
+	50470, 50682, 50894, 51107, 51320, 51533, 51747, 51961, 52175, 52388, 52601, 52813,
+	53025, 53236, 53446, 53655, 53863, 54069, 54274, 54477, 54679, 54879, 55078, 55274,
+	55469, 55662, 55853, 56042, 56230, 56415, 56598, 56779, 56959, 57136, 57311, 57484,
+	57654, 57823, 57989, 58152, 58314, 58473, 58629, 58783, 58935, 59084, 59230, 59373,
+	59514, 59652, 59787, 59919, 60048, 60174, 60297, 60417, 60533, 60647, 60757, 60865,
+	60969, 61070, 61167, 61262, 61353, 61442, 61527, 61609, 61689, 61765, 61839, 61910,
+	61979, 62045, 62109, 62170, 62230, 62287, 62343, 62396, 62448, 62498, 62547, 62594,
+	62640, 62685, 62728, 62770, 62811, 62852, 62891, 62929, 62967, 63004, 63040, 63075,
+	63110, 63145, 63178, 63212, 63244, 63277, 63308, 63340, 63371, 63402, 63432, 63462,
+	63491, 63521, 63550, 63578, 63607, 63635, 63663, 63690, 63718, 63744, 63771, 63798,
+	63824, 63850, 63875, 63900, 63925, 63950, 63975, 63999, 64023, 64046, 64069, 64092,
+	64115, 64138, 64160, 64182, 64204, 64225, 64247, 64268, 64289, 64310, 64330, 64351,
+	64371, 64391, 64411, 64431, 64450, 64470, 64489, 64508, 64527, 64545, 64564, 64582,
+	64600, 64617, 64635, 64652, 64669, 64686, 64702, 64719, 64735, 64750, 64766, 64782,
+	64797, 64812, 64827, 64842, 64857, 64872, 64886, 64901, 64915, 64930, 64944, 64959,
+	64974, 64988, 65003, 65018, 65033, 65048, 65063, 65078, 65094, 65109, 65125, 65141,
+	65157, 65172, 65188, 65204, 65220, 65236, 65252, 65268, 65283, 65299, 65314, 65330,
+	65345, 65360, 65375, 65390, 65405, 65419, 65434, 65449, 65463, 65477, 65492, 65506,
+	65521, 65535,
+}
+
+const pitchLagSWBCDFOffset = 128
+
+var pitchContourCDF = []uint16{
+	0, 372, 843, 1315, 1836, 2644, 3576, 4719, 6088, 7621, 9396, 11509,
+	14245, 17618, 20777, 24294, 27992, 33116, 40100, 44329, 47558, 50679, 53130, 55557,
+	57510, 59022, 60285, 61345, 62316, 63140, 63762, 64321, 64729, 65099, 65535,
+}
+
+const pitchContourCDFOffset = 17
+
+var pitchContourNBCDF = []uint16{
+	0, 14445, 18587, 25628, 30013, 34859, 40597, 48426, 54460, 59033, 62990, 65535,
+}
+
+const pitchContourNBCDFOffset = 5
+
+var cbLagsStage2 = [][]int{
+	{0, 2, -1, -1, -1, 0, 0, 1, 1, 0, 1},
+	{0, 1, 0, 0, 0, 0, 0, 1, 0, 0, 0},
+	{0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 0},
+	{0, -1, 2, 1, 0, 1, 1, 0, 0, -1, -1},
+}
+
+var cbLagsStage3 = [][]int{
+	{-9, -7, -6, -5, -5, -4, -4, -3, -3, -2, -2, -2, -1, -1, -1, 0, 0, 0, 1, 1, 0, 1, 2, 2, 2, 3, 3, 4, 4, 5, 6, 5, 6, 8},
+	{-3, -2, -2, -2, -1, -1, -1, -1, -1, 0, 0, -1, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 1, 1, 0, 1, 1, 2, 1, 2, 2, 2, 2, 3},
+	{3, 3, 2, 2, 2, 2, 1, 2, 1, 1, 0, 1, 1, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, -1, 0, 0, -1, -1, -1, -1, -1, -2, -2, -2},
+	{9, 8, 6, 5, 6, 5, 4, 4, 3, 3, 2, 2, 2, 1, 0, 1, 1, 0, 0, 0, -1, -1, -1, -2, -2, -2, -3, -3, -4, -4, -5, -5, -6, -7},
+}
+
+var ltpPerIndexCDF = []uint16{
+	0, 20992, 40788, 65535,
+}
+
+const ltpPerIndexCDFOffset = 1
+
+var ltpGainCDF0 = []uint16{
+	0, 49380, 54463, 56494, 58437, 60101, 61683, 62985, 64066, 64823, 65535,
+}
+
+var ltpGainCDF1 = []uint16{
+	0, 25290, 30654, 35710, 40386, 42937, 45250, 47459, 49411, 51348, 52974, 54517,
+	55976, 57423, 58865, 60285, 61667, 62895, 63827, 64724, 65535,
+}
+
+var ltpGainCDF2 = []uint16{
+	0, 4958, 9439, 13581, 17638, 21651, 25015, 28025, 30287, 32406, 34330, 36240,
+	38130, 39790, 41281, 42764, 44229, 45676, 47081, 48431, 49675, 50849, 51932, 52966,
+	53957, 54936, 55869, 56789, 57708, 58504, 59285, 60043, 60796, 61542, 62218, 62871,
+	63483, 64076, 64583, 65062, 65535,
+}
+
+var ltpGainCDFOffsets = []int{
+	1, 3, 10,
+}
+
+var ltpGainVQ0Q14 = []int16{
+	594, 984, 2840, 1021, 669, 10, 35, 304, -1, 23, -694, 1923,
+	4603, 2975, 2335, 2437, 3176, 3778, 1940, 481, 214, -46, 7870, 4406,
+	-521, -896, 4818, 8501, 1623, -887, -696, 3178, 6480, -302, 1081, 517,
+	599, 1002, 567, 560, -2075, -834, 4712, -340, 896, 1435, -644, 3993,
+	-612, -2063,
+}
+
+var ltpGainVQ1Q14 = []int16{
+	1655, 2918, 5001, 3010, 1775, 113, 198, 856, 176, 178, -843, 2479,
+	7858, 5371, 574, 59, 5356, 7648, 2850, -315, 3840, 4851, 6527, 1583,
+	-1233, 1620, 1760, 2330, 1876, 2045, -545, 1854, 11792, 1547, -307, -604,
+	689, 5369, 5074, 4265, 521, -1331, 9829, 6209, -1211, -1315, 6747, 9929,
+	-1410, 546, 117, -144, 2810, 1649, 5240, 5392, 3476, 2425, -38, 633,
+	14, -449, 5274, 3547, -171, -98, 395, 9114, 1676, 844, -908, 3843,
+	8861, -957, 1474, 396, 6747, 5379, -329, 1269, -335, 2830, 4281, 270,
+	-54, 1502, 5609, 8958, 6045, 2059, -370, 479, 5267, 5726, 1174, 5237,
+	-1144, 6510, 455, 512,
+}
+
+var ltpGainVQ2Q14 = []int16{
+	-278, 415, 9345, 7106, -431, -1006, 3863, 9524, 4724, -871, -954, 4624,
+	11722, 973, -300, -117, 7066, 8331, 1959, -901, 593, 3412, 6070, 4914,
+	1567, 54, -51, 12618, 4228, -844, 3157, 4822, 5229, 2313, 717, -244,
+	1161, 14198, 779, 69, -1218, 5603, 12894, -2301, 1001, -132, 3960, 9526,
+	577, 1806, -1633, 8815, 10484, -2452, 895, 235, 450, 1243, 667, 437,
+	959, -2630, 10897, 8772, -1852, 2420, 2046, 8893, 4427, -1569, 23, 7091,
+	8356, -1285, 1508, -1133, 835, 7662, 6043, 2800, 439, 391, 11016, 2253,
+	1362, -1020, 2876, 13436, 4015, -3020, 1060, -2690, 13512, 5565, -1394, -1420,
+	8007, 11421, -152, -1672, -893, 2895, 15434, -1490, 159, -1054, 428, 12208,
+	8538, -3344, 1772, -1304, 7593, 6185, 561, 525, -1207, 6659, 11151, -1170,
+	439, 2667, 4743, 2359, 5515, 2951, 7432, 7909, -230, -1564, -72, 2140,
+	5477, 1391, 1580, 476, -1312, 15912, 2174, -1027, 5737, 441, 2493, 2043,
+	2757, 228, -43, 1803, 6663, 7064, 4596, 9182, 1917, -200, 203, -704,
+	12039, 5451, -1188, 542, 1782, -1040, 10078, 7513, -2767, -2626, 7747, 9019,
+	62, 1710, 235, -233, 2954, 10921, 1947, 10854, 2814, 1232, -111, 222,
+	2267, 2778, 12325, 156, -1658, -2950, 8095, 16330, 268, -3626, 67, 2083,
+	7950, -80, -2432, 518, -66, 1718, 415, 11435,
+}
+
+var ltpScaleCDF = []uint16{
+	0, 32000, 48000, 65535,
+}
+
+const ltpScaleOffset = 2
+
+var ltpScalesQ14 = []int16{
+	15565, 11469, 8192,
+}
+
+var seedCDF = []uint16{
+	0, 16384, 32768, 49152, 65535,
+}
+
+const seedOffset = 2
+
+var rateLevelsCDF = [][]uint16{
+	{0, 2005, 12717, 20281, 31328, 36234, 45816, 57753, 63104, 65535},
+	{0, 8553, 23489, 36031, 46295, 53519, 56519, 59151, 64185, 65535},
+}
+
+const rateLevelsCDFOffset = 4
+
+var pulsesPerBlockCDF = [][]uint16{
+	{0, 47113, 61501, 64590, 65125, 65277, 65352, 65407, 65450, 65474, 65488, 65501, 65508, 65514, 65516, 65520, 65521, 65523, 65524, 65526, 65535},
+	{0, 26368, 47760, 58803, 63085, 64567, 65113, 65333, 65424, 65474, 65498, 65511, 65517, 65520, 65523, 65525, 65526, 65528, 65529, 65530, 65535},
+	{0, 9601, 28014, 45877, 57210, 62560, 64611, 65260, 65447, 65500, 65511, 65519, 65521, 65525, 65526, 65529, 65530, 65531, 65532, 65534, 65535},
+	{0, 3351, 12462, 25972, 39782, 50686, 57644, 61525, 63521, 64506, 65009, 65255, 65375, 65441, 65471, 65488, 65497, 65505, 65509, 65512, 65535},
+	{0, 488, 2944, 9295, 19712, 32160, 43976, 53121, 59144, 62518, 64213, 65016, 65346, 65470, 65511, 65515, 65525, 65529, 65531, 65534, 65535},
+	{0, 17013, 30405, 40812, 48142, 53466, 57166, 59845, 61650, 62873, 63684, 64223, 64575, 64811, 64959, 65051, 65111, 65143, 65165, 65183, 65535},
+	{0, 2994, 8323, 15845, 24196, 32300, 39340, 45140, 49813, 53474, 56349, 58518, 60167, 61397, 62313, 62969, 63410, 63715, 63906, 64056, 65535},
+	{0, 88, 721, 2795, 7542, 14888, 24420, 34593, 43912, 51484, 56962, 60558, 62760, 64037, 64716, 65069, 65262, 65358, 65398, 65420, 65535},
+	{0, 287, 789, 2064, 4398, 8174, 13534, 20151, 27347, 34533, 41295, 47242, 52070, 55772, 58458, 60381, 61679, 62533, 63109, 63519, 65535},
+	{0, 1, 3, 91, 4521, 14708, 28329, 41955, 52116, 58375, 61729, 63534, 64459, 64924, 65092, 65164, 65182, 65198, 65203, 65211, 65535},
+}
+
+const pulsesPerBlockCDFOffset = 6
+
+var shellCodeTable0 = []uint16{
+	0, 32748, 65535, 0, 9505, 56230, 65535, 0, 4093, 32204, 61720, 65535,
+	0, 2285, 16207, 48750, 63424, 65535, 0, 1709, 9446, 32026, 55752, 63876,
+	65535, 0, 1623, 6986, 21845, 45381, 59147, 64186, 65535,
+}
+
+var shellCodeTable1 = []uint16{
+	0, 32691, 65535, 0, 12782, 52752, 65535, 0, 4847, 32665, 60899, 65535,
+	0, 2500, 17305, 47989, 63369, 65535, 0, 1843, 10329, 32419, 55433, 64277,
+	65535, 0, 1485, 7062, 21465, 43414, 59079, 64623, 65535, 0, 0, 4841,
+	14797, 31799, 49667, 61309, 65535, 65535, 0, 0, 0, 8032, 21695, 41078,
+	56317, 65535, 65535, 65535,
+}
+
+var shellCodeTable2 = []uint16{
+	0, 32615, 65535, 0, 14447, 50912, 65535, 0, 6301, 32587, 59361, 65535,
+	0, 3038, 18640, 46809, 62852, 65535, 0, 1746, 10524, 32509, 55273, 64278,
+	65535, 0, 1234, 6360, 21259, 43712, 59651, 64805, 65535, 0, 1020, 4461,
+	14030, 32286, 51249, 61904, 65100, 65535, 0, 851, 3435, 10006, 23241, 40797,
+	55444, 63009, 65252, 65535, 0, 0, 2075, 7137, 17119, 31499, 46982, 58723,
+	63976, 65535, 65535, 0, 0, 0, 3820, 11572, 23038, 37789, 51969, 61243,
+	65535, 65535, 65535, 0, 0, 0, 0, 6882, 16828, 30444, 44844, 57365,
+	65535, 65535, 65535, 65535, 0, 0, 0, 0, 0, 10093, 22963, 38779,
+	54426, 65535, 65535, 65535, 65535, 65535,
+}
+
+var shellCodeTable3 = []uint16{
+	0, 32324, 65535, 0, 15328, 49505, 65535, 0, 7474, 32344, 57955, 65535,
+	0, 3944, 19450, 45364, 61873, 65535, 0, 2338, 11698, 32435, 53915, 63734,
+	65535, 0, 1506, 7074, 21778, 42972, 58861, 64590, 65535, 0, 1027, 4490,
+	14383, 32264, 50980, 61712, 65043, 65535, 0, 760, 3022, 9696, 23264, 41465,
+	56181, 63253, 65251, 65535, 0, 579, 2256, 6873, 16661, 31951, 48250, 59403,
+	64198, 65360, 65535, 0, 464, 1783, 5181, 12269, 24247, 39877, 53490, 61502,
+	64591, 65410, 65535, 0, 366, 1332, 3880, 9273, 18585, 32014, 45928, 56659,
+	62616, 64899, 65483, 65535, 0, 286, 1065, 3089, 6969, 14148, 24859, 38274,
+	50715, 59078, 63448, 65091, 65481, 65535, 0, 0, 482, 2010, 5302, 10408,
+	18988, 30698, 43634, 54233, 60828, 64119, 65288, 65535, 65535, 0, 0, 0,
+	1006, 3531, 7857, 14832, 24543, 36272, 47547, 56883, 62327, 64746, 65535, 65535,
+	65535, 0, 0, 0, 0, 1863, 4950, 10730, 19284, 29397, 41382, 52335,
+	59755, 63834, 65535, 65535, 65535, 65535, 0, 0, 0, 0, 0, 2513,
+	7290, 14487, 24275, 35312, 46240, 55841, 62007, 65535, 65535, 65535, 65535, 65535,
+	0, 0, 0, 0, 0, 0, 3606, 9573, 18764, 28667, 40220, 51290,
+	59924, 65535, 65535, 65535, 65535, 65535, 65535, 0, 0, 0, 0, 0,
+	0, 0, 4879, 13091, 23376, 36061, 49395, 59315, 65535, 65535, 65535, 65535,
+	65535, 65535, 65535,
+}
+
+var shellCodeTableOffsets = []int{
+	0, 0, 3, 7, 12, 18, 25, 33, 42, 52, 63, 75,
+	88, 102, 117, 133, 150, 168, 187,
+}
+
+var lsbCDF = []uint16{
+	0, 40000, 65535,
+}
+
+var signCDF = []uint16{
+	37840, 36944, 36251, 35304, 34715, 35503, 34529, 34296, 34016, 47659, 44945, 42503,
+	40235, 38569, 40254, 37851, 37243, 36595, 43410, 44121, 43127, 40978, 38845, 40433,
+	38252, 37795, 36637, 59159, 55630, 51806, 48073, 45036, 48416, 43857, 42678, 41146,
+}
+
+var vadFlagCDF = []uint16{
+	0, 22000, 65535,
+}
+
+const vadFlagOffset = 1
+
+var frameTerminationCDF = []uint16{
+	0, 20000, 45000, 56000, 65535,
+}
+
+const frameTerminationOffset = 2
+
+var quantizationOffsetsQ10 = [][]int32{
+	{32, 100},
+	{100, 256},
+}
+
+var decAHP24 = []int16{
+	-16220, 8030,
+}
+
+var decBHP24 = []int16{
+	8000, -16000, 8000,
+}
+
+var decAHP16 = []int16{
+	-16127, 7940,
+}
+
+var decBHP16 = []int16{
+	8000, -16000, 8000,
+}
+
+var decAHP12 = []int16{
+	-16043, 7859,
+}
+
+var decBHP12 = []int16{
+	8000, -16000, 8000,
+}
+
+var decAHP8 = []int16{
+	-15885, 7710,
+}
+
+var decBHP8 = []int16{
+	8000, -16000, 8000,
+}
+
+var lsfCosTabQ12 = []int32{
+	8192, 8190, 8182, 8170, 8152, 8130, 8104, 8072, 8034, 7994, 7946, 7896,
+	7840, 7778, 7714, 7644, 7568, 7490, 7406, 7318, 7226, 7128, 7026, 6922,
+	6812, 6698, 6580, 6458, 6332, 6204, 6070, 5934, 5792, 5648, 5502, 5352,
+	5198, 5040, 4880, 4718, 4552, 4382, 4212, 4038, 3862, 3684, 3502, 3320,
+	3136, 2948, 2760, 2570, 2378, 2186, 1990, 1794, 1598, 1400, 1202, 1002,
+	802, 602, 402, 202, 0, -202, -402, -602, -802, -1002, -1202, -1400,
+	-1598, -1794, -1990, -2186, -2378, -2570, -2760, -2948, -3136, -3320, -3502, -3684,
+	-3862, -4038, -4212, -4382, -4552, -4718, -4880, -5040, -5198, -5352, -5502, -5648,
+	-5792, -5934, -6070, -6204, -6332, -6458, -6580, -6698, -6812, -6922, -7026, -7128,
+	-7226, -7318, -7406, -7490, -7568, -7644, -7714, -7778, -7840, -7896, -7946, -7994,
+	-8034, -8072, -8104, -8130, -8152, -8170, -8182, -8190, -8192,
+}
+
+var resamplerUp2HQ0 = []int16{
+	4280, -31809,
+}
+
+var resamplerUp2HQ1 = []int16{
+	16295, -11521,
+}
+
+var resamplerUp2HQNotch = []int16{
+	7864, -3604, 13107, 28508,
+}
+
+var resamplerFracFIR144 = [][]int16{
+	{-647, 1884, 30078},
+	{-625, 1736, 30044},
+	{-603, 1591, 30005},
+	{-581, 1448, 29963},
+	{-559, 1308, 29917},
+	{-537, 1169, 29867},
+	{-515, 1032, 29813},
+	{-494, 898, 29755},
+	{-473, 766, 29693},
+	{-452, 636, 29627},
+	{-431, 508, 29558},
+	{-410, 383, 29484},
+	{-390, 260, 29407},
+	{-369, 139, 29327},
+	{-349, 20, 29242},
+	{-330, -97, 29154},
+	{-310, -211, 29062},
+	{-291, -324, 28967},
+	{-271, -434, 28868},
+	{-253, -542, 28765},
+	{-234, -647, 28659},
+	{-215, -751, 28550},
+	{-197, -852, 28436},
+	{-179, -951, 28320},
+	{-162, -1048, 28200},
+	{-144, -1143, 28077},
+	{-127, -1235, 27950},
+	{-110, -1326, 27820},
+	{-94, -1414, 27687},
+	{-77, -1500, 27550},
+	{-61, -1584, 27410},
+	{-45, -1665, 27268},
+	{-30, -1745, 27122},
+	{-15, -1822, 26972},
+	{0, -1897, 26820},
+	{15, -1970, 26665},
+	{29, -2041, 26507},
+	{44, -2110, 26346},
+	{57, -2177, 26182},
+	{71, -2242, 26015},
+	{84, -2305, 25845},
+	{97, -2365, 25673},
+	{110, -2424, 25498},
+	{122, -2480, 25320},
+	{134, -2534, 25140},
+	{146, -2587, 24956},
+	{157, -2637, 24771},
+	{168, -2685, 24583},
+	{179, -2732, 24392},
+	{190, -2776, 24199},
+	{200, -2819, 24003},
+	{210, -2859, 23805},
+	{220, -2898, 23605},
+	{229, -2934, 23403},
+	{238, -2969, 23198},
+	{247, -3002, 22992},
+	{255, -3033, 22783},
+	{263, -3062, 22572},
+	{271, -3089, 22359},
+	{279, -3114, 22144},
+	{286, -3138, 21927},
+	{293, -3160, 21709},
+	{300, -3180, 21488},
+	{306, -3198, 21266},
+	{312, -3215, 21042},
+	{318, -3229, 20816},
+	{323, -3242, 20589},
+	{328, -3254, 20360},
+	{333, -3263, 20130},
+	{338, -3272, 19898},
+	{342, -3278, 19665},
+	{346, -3283, 19430},
+	{350, -3286, 19194},
+	{353, -3288, 18957},
+	{356, -3288, 18718},
+	{359, -3286, 18478},
+	{362, -3283, 18238},
+	{364, -3279, 17996},
+	{366, -3273, 17753},
+	{368, -3266, 17509},
+	{369, -3257, 17264},
+	{371, -3247, 17018},
+	{372, -3235, 16772},
+	{372, -3222, 16525},
+	{373, -3208, 16277},
+	{373, -3192, 16028},
+	{373, -3175, 15779},
+	{373, -3157, 15529},
+	{372, -3138, 15279},
+	{371, -3117, 15028},
+	{370, -3095, 14777},
+	{369, -3072, 14526},
+	{368, -3048, 14274},
+	{366, -3022, 14022},
+	{364, -2996, 13770},
+	{362, -2968, 13517},
+	{359, -2940, 13265},
+	{357, -2910, 13012},
+	{354, -2880, 12760},
+	{351, -2848, 12508},
+	{348, -2815, 12255},
+	{344, -2782, 12003},
+	{341, -2747, 11751},
+	{337, -2712, 11500},
+	{333, -2676, 11248},
+	{328, -2639, 10997},
+	{324, -2601, 10747},
+	{320, -2562, 10497},
+	{315, -2523, 10247},
+	{310, -2482, 9998},
+	{305, -2442, 9750},
+	{300, -2400, 9502},
+	{294, -2358, 9255},
+	{289, -2315, 9009},
+	{283, -2271, 8763},
+	{277, -2227, 8519},
+	{271, -2182, 8275},
+	{265, -2137, 8032},
+	{259, -2091, 7791},
+	{252, -2045, 7550},
+	{246, -1998, 7311},
+	{239, -1951, 7072},
+	{232, -1904, 6835},
+	{226, -1856, 6599},
+	{219, -1807, 6364},
+	{212, -1758, 6131},
+	{204, -1709, 5899},
+	{197, -1660, 5668},
+	{190, -1611, 5439},
+	{183, -1561, 5212},
+	{175, -1511, 4986},
+	{168, -1460, 4761},
+	{160, -1410, 4538},
+	{152, -1359, 4317},
+	{145, -1309, 4098},
+	{137, -1258, 3880},
+	{129, -1207, 3664},
+	{121, -1156, 3450},
+	{113, -1105, 3238},
+	{105, -1054, 3028},
+	{97, -1003, 2820},
+	{89, -952, 2614},
+	{81, -901, 2409},
+	{73, -851, 2207},
+}
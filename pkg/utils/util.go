@@ -3,6 +3,8 @@ package utils
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
 	"golang.org/x/sys/windows"
 )
@@ -37,3 +39,25 @@ func Unique(elements []string) []string {
 	}
 	return result
 }
+
+// CompareVersion 比较两个点分版本号（如 3.9.12.55），a<b 返回 -1，相等返回 0，a>b 返回 1
+func CompareVersion(a, b string) int {
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x < y {
+			return -1
+		}
+		if x > y {
+			return 1
+		}
+	}
+	return 0
+}
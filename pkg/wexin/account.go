@@ -32,6 +32,9 @@ type Account struct {
 	WxAccount   string
 	Nickname    string
 	Phone       string
+	Remark      string
+	AvatarURL   string
+	InfoSource  map[string]string // 记录 Nickname、WxAccount 等字段来自内存还是数据库
 	Version     int
 	FullVersion string
 	DataDir     string
@@ -43,6 +46,8 @@ type Account struct {
 	ExePath     string
 	Status      string
 	ZipPath     string
+
+	recordResolvers map[string]*recordMediaResolver // v4 talker（v3 为空串）=> 合并转发媒体索引，见 recordResolver
 }
 
 // 微信4的目录名不是wxid，而是 wxid_xxxxx_786d == > wxid_xxxxx，多个下划线也只保留一个
@@ -110,6 +115,29 @@ func (a *Account) initializeProcessInfo(proc *utils.MyProcess) error {
 	return nil
 }
 
+// DecryptDB 按版本解密数据库到 decryptedDir/wxid，再用账号自己的联系人记录补全内存中没拿到的用户信息
+func (a *Account) DecryptDB(decryptedDir string) error {
+	if a.Version == 4 {
+		if a.KeyV4 == nil {
+			return fmt.Errorf("KeyV4 is empty, call GetKeyV4() first")
+		}
+		if err := a.DecryptDBV4(decryptedDir); err != nil {
+			return fmt.Errorf("failed to decrypt v4 db: %v", err)
+		}
+	} else {
+		if a.Key == "" {
+			return fmt.Errorf("Key is empty, call GetUserInfoV3() first")
+		}
+		if err := a.DecryptDBV3(decryptedDir); err != nil {
+			return fmt.Errorf("failed to decrypt v3 db: %v", err)
+		}
+	}
+	if err := a.LoadUserInfoFromDB(decryptedDir); err != nil {
+		logrus.Infof("LoadUserInfoFromDB error:%+v", err)
+	}
+	return nil
+}
+
 // ZipWeChatUserData 压缩微信用户数据
 func (a *Account) ZipWeChatUserData(savePath string) error {
 	// 如果不确定，检查数据库修改时间
@@ -149,17 +177,14 @@ func (a *Account) ZipWeChatUserData(savePath string) error {
 	if a.Version != 4 {
 		return nil
 	}
-	if a.KeyV4 == nil {
-		return fmt.Errorf("KeyV4 is empty, call GetKeyV4() first")
-	}
 	targetDir, err := os.MkdirTemp("", "wx_v4_decrypt_*")
 	if err != nil {
 		return fmt.Errorf("failed to create temp dir: %v", err)
 	}
 	defer os.RemoveAll(targetDir)
 
-	if err := a.DecryptDBV4(targetDir); err != nil {
-		return fmt.Errorf("failed to decrypt v4 db: %v", err)
+	if err := a.DecryptDB(targetDir); err != nil {
+		return err
 	}
 
 	// 创建zip文件
@@ -0,0 +1,125 @@
+package wexin
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// newPlainDBV3 创建页尾保留 v3ReserveSz 字节的明文库：空库时改文件头的保留字节数和第 1 页的单元格起始位置，
+// 之后写入的页都会留出保留区
+func newPlainDBV3(t *testing.T, path string, stmts ...string) {
+	t.Helper()
+	exec := func(stmts ...string) {
+		db, err := sql.Open("sqlite", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		for _, s := range stmts {
+			if _, err := db.Exec(s); err != nil {
+				t.Fatalf("%s: %v", s, err)
+			}
+		}
+	}
+	exec("PRAGMA page_size = 4096", "PRAGMA user_version = 1")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[20] = v3ReserveSz
+	binary.BigEndian.PutUint16(data[100+5:], pageSz-v3ReserveSz)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	exec(stmts...)
+}
+
+// encryptDBV3 按 SQLCipher3 的格式加密：每页 AES-256-CBC，页尾为 IV + HMAC-SHA1，第 1 页开头为 salt
+func encryptDBV3(t *testing.T, plain, key []byte) []byte {
+	t.Helper()
+	salt := []byte("0123456789abcdef")
+	encKey := pbkdf2.Key(key, salt, v3KeyIter, keySz, sha1.New)
+	macSalt := make([]byte, saltSz)
+	for i, b := range salt {
+		macSalt[i] = b ^ 0x3A
+	}
+	macKey := pbkdf2.Key(encKey, macSalt, 2, keySz, sha1.New)
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]byte, 0, len(plain))
+	for pgno := 1; pgno*pageSz <= len(plain); pgno++ {
+		page := plain[(pgno-1)*pageSz : pgno*pageSz]
+		enc := make([]byte, pageSz)
+		start := 0
+		if pgno == 1 {
+			copy(enc, salt)
+			start = saltSz
+		}
+		iv := enc[pageSz-v3ReserveSz : pageSz-v3ReserveSz+ivSz]
+		for i := range iv {
+			iv[i] = byte(pgno + i)
+		}
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(enc[start:pageSz-v3ReserveSz], page[start:pageSz-v3ReserveSz])
+		hm := hmac.New(sha1.New, macKey)
+		hm.Write(enc[start : pageSz-v3ReserveSz+ivSz])
+		_ = binary.Write(hm, binary.LittleEndian, uint32(pgno))
+		copy(enc[pageSz-v3ReserveSz+ivSz:], hm.Sum(nil))
+		out = append(out, enc...)
+	}
+	return out
+}
+
+func TestDecryptDBLoadsUserInfoV3(t *testing.T) {
+	plainPath := filepath.Join(t.TempDir(), "MicroMsg.db")
+	newPlainDBV3(t, plainPath,
+		`CREATE TABLE Contact(UserName TEXT, Alias TEXT, Type INT, VerifyFlag INT, Remark TEXT, NickName TEXT, LabelIDList TEXT,
+			QuanPin TEXT, RemarkQuanPin TEXT, BigHeadImgUrl TEXT, SmallHeadImgUrl TEXT, ExtraBuf BLOB)`,
+		`CREATE TABLE ContactHeadImgUrl(usrName TEXT, smallHeadImgUrl TEXT, bigHeadImgUrl TEXT)`,
+		`CREATE TABLE ContactLabel(LabelId INT, LabelName TEXT)`,
+		`INSERT INTO Contact(UserName, Alias, Type, VerifyFlag, Remark, NickName) VALUES
+			('wxid_me', 'me_alias', 1, 0, '', 'Me'), ('wxid_a', 'a', 3, 0, '', 'Alice')`,
+		`INSERT INTO ContactHeadImgUrl VALUES ('wxid_me', 'http://small', 'http://big')`,
+	)
+	plain, err := os.ReadFile(plainPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := []byte(strings.Repeat("k", keySz))
+	dataDir := filepath.Join(t.TempDir(), "wxid_me")
+	if err := os.MkdirAll(filepath.Join(dataDir, "Msg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "Msg", "MicroMsg.db"), encryptDBV3(t, plain, key), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 内存中只拿到了昵称
+	a := &Account{Version: 3, Wxid: "wxid_me", DataDir: dataDir, Key: strings.ToUpper(hex.EncodeToString(key))}
+	a.setInfo(infoFieldNickname, &a.Nickname, "MemNick", InfoSourceMemory)
+	if err := a.DecryptDB(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if a.Nickname != "MemNick" || a.InfoSource[infoFieldNickname] != InfoSourceMemory {
+		t.Errorf("nickname = %q from %s, want memory value kept", a.Nickname, a.InfoSource[infoFieldNickname])
+	}
+	if a.WxAccount != "me_alias" || a.InfoSource[infoFieldWxAccount] != InfoSourceMicroMsgDB {
+		t.Errorf("wx account = %q from %s", a.WxAccount, a.InfoSource[infoFieldWxAccount])
+	}
+	if a.AvatarURL != "http://big" {
+		t.Errorf("avatar = %q", a.AvatarURL)
+	}
+}
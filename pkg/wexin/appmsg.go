@@ -0,0 +1,304 @@
+package wexin
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// appmsg 的 type（Type 49 消息）
+const (
+	AppMsgText          = 1
+	AppMsgMusic         = 3
+	AppMsgVideo         = 4
+	AppMsgLink          = 5
+	AppMsgFile          = 6
+	AppMsgEmoji         = 8
+	AppMsgLocationShare = 17
+	AppMsgRecord        = 19 // 合并转发的聊天记录
+	AppMsgMiniProgram   = 33
+	AppMsgMiniProgram2  = 36
+	AppMsgChannels      = 51 // 视频号
+	AppMsgQuote         = 57 // 引用回复
+	AppMsgPat           = 62 // 拍一拍
+	AppMsgMusic2        = 76
+	AppMsgTransfer      = 2000
+	AppMsgRedPacket     = 2001
+)
+
+// appMsgXML <msg><appmsg> 的原始结构，只列出用到的字段
+type appMsgXML struct {
+	XMLName xml.Name `xml:"msg"`
+	AppMsg  struct {
+		AppID             string `xml:"appid,attr"`
+		Title             string `xml:"title"`
+		Des               string `xml:"des"`
+		Type              int    `xml:"type"`
+		URL               string `xml:"url"`
+		DataURL           string `xml:"dataurl"`
+		ThumbURL          string `xml:"thumburl"`
+		MD5               string `xml:"md5"`
+		SourceDisplayName string `xml:"sourcedisplayname"`
+		RecordItem        string `xml:"recorditem"`
+		AppAttach         struct {
+			TotalLen int64  `xml:"totallen"`
+			AttachID string `xml:"attachid"`
+			FileExt  string `xml:"fileext"`
+		} `xml:"appattach"`
+		ReferMsg  *referMsgXML `xml:"refermsg"`
+		WCPayInfo struct {
+			PaySubType       int    `xml:"paysubtype"`
+			FeeDesc          string `xml:"feedesc"`
+			TransferID       string `xml:"transferid"`
+			PayMemo          string `xml:"pay_memo"`
+			ReceiverUsername string `xml:"receiver_username"`
+			PayerUsername    string `xml:"payer_username"`
+			SenderTitle      string `xml:"sendertitle"`
+			SenderDes        string `xml:"senderdes"`
+			SceneText        string `xml:"scenetext"`
+		} `xml:"wcpayinfo"`
+		WeAppInfo struct {
+			Username string `xml:"username"`
+			AppID    string `xml:"appid"`
+			PagePath string `xml:"pagepath"`
+		} `xml:"weappinfo"`
+		FinderFeed struct {
+			Nickname string `xml:"nickname"`
+			Desc     string `xml:"desc"`
+		} `xml:"finderFeed"`
+		PatInfo struct {
+			Template string `xml:"template"`
+			Fromuser string `xml:"fromusername"`
+		} `xml:"patinfo"`
+	} `xml:"appmsg"`
+	FromUsername string `xml:"fromusername"`
+	AppInfo      struct {
+		AppName string `xml:"appname"`
+	} `xml:"appinfo"`
+}
+
+// referMsgXML <refermsg>，svrid 是无符号 64 位整数，按字符串读取，见 parseSvrID
+type referMsgXML struct {
+	Type        int    `xml:"type"`
+	SvrID       string `xml:"svrid"`
+	FromUser    string `xml:"fromusr"`
+	ChatUser    string `xml:"chatusr"`
+	DisplayName string `xml:"displayname"`
+	Content     string `xml:"content"`
+	CreateTime  int64  `xml:"createtime"`
+}
+
+// parseSvrID XML 中的 svrid 是无符号数，超过 int64 范围时按补码转成有符号数，和数据库中的 MsgSvrID / server_id 一致
+func parseSvrID(s string) int64 {
+	s = strings.TrimSpace(s)
+	if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return int64(u)
+	}
+	i, _ := strconv.ParseInt(s, 10, 64)
+	return i
+}
+
+// AppMessage appmsg 解析后的结构，按 AppType 区分具体类型
+type AppMessage interface {
+	AppType() int
+}
+
+// LinkMsg 链接、公众号文章
+type LinkMsg struct {
+	Type     int    `json:"type"`
+	Title    string `json:"title"`
+	Des      string `json:"des"`
+	URL      string `json:"url"`
+	ThumbURL string `json:"thumb_url"`
+	Source   string `json:"source"` // 来源公众号或应用
+}
+
+// TextAppMsg 纯文本的 appmsg（type 1），例如第三方应用分享的文字
+type TextAppMsg struct {
+	Title  string `json:"title"`
+	Des    string `json:"des"`
+	Source string `json:"source"`
+}
+
+// FileMsg 文件
+type FileMsg struct {
+	Title    string `json:"title"`
+	Ext      string `json:"ext"`
+	Size     int64  `json:"size"`
+	MD5      string `json:"md5"`
+	AttachID string `json:"attach_id"`
+}
+
+// MusicMsg 音乐分享
+type MusicMsg struct {
+	Type    int    `json:"type"`
+	Title   string `json:"title"`
+	Des     string `json:"des"`
+	URL     string `json:"url"`
+	DataURL string `json:"data_url"` // 音频地址
+	Source  string `json:"source"`
+}
+
+// MiniProgramMsg 小程序
+type MiniProgramMsg struct {
+	Type     int    `json:"type"`
+	Title    string `json:"title"`
+	AppID    string `json:"app_id"`
+	Username string `json:"username"`
+	PagePath string `json:"page_path"`
+	Source   string `json:"source"`
+}
+
+// QuoteMsg 引用回复，Title 为回复内容
+type QuoteMsg struct {
+	Title string   `json:"title"`
+	Refer ReferMsg `json:"refer"`
+}
+
+// ReferMsg 被引用的消息
+type ReferMsg struct {
+	Type        int    `json:"type"`
+	SvrID       int64  `json:"svr_id,string"`
+	FromUser    string `json:"from_user"`
+	ChatUser    string `json:"chat_user"`
+	DisplayName string `json:"display_name"`
+	Content     string `json:"content"`
+	CreateTime  int64  `json:"create_time"`
+}
+
+// RecordMsg 合并转发的聊天记录，RecordItem 为 <recordinfo> XML
+type RecordMsg struct {
+	Title      string `json:"title"`
+	Des        string `json:"des"`
+	RecordItem string `json:"record_item"`
+}
+
+// TransferMsg 转账，PaySubType：1 发起、3 收款、4 退还
+type TransferMsg struct {
+	PaySubType int    `json:"pay_sub_type"`
+	FeeDesc    string `json:"fee_desc"`
+	TransferID string `json:"transfer_id"`
+	Memo       string `json:"memo"`
+	Payer      string `json:"payer"`
+	Receiver   string `json:"receiver"`
+}
+
+// RedPacketMsg 红包
+type RedPacketMsg struct {
+	Title     string `json:"title"`
+	Des       string `json:"des"`
+	SceneText string `json:"scene_text"`
+}
+
+// LocationShareMsg 共享实时位置
+type LocationShareMsg struct {
+	Title string `json:"title"`
+	Des   string `json:"des"`
+}
+
+// ChannelsMsg 视频号
+type ChannelsMsg struct {
+	Title    string `json:"title"`
+	Nickname string `json:"nickname"`
+	Desc     string `json:"desc"`
+	URL      string `json:"url"`
+}
+
+// PatMsg 拍一拍
+type PatMsg struct {
+	Title    string `json:"title"`
+	Template string `json:"template"`
+	FromUser string `json:"from_user"`
+}
+
+// UnknownAppMsg 暂不支持的类型，保留原始 XML
+type UnknownAppMsg struct {
+	Type  int    `json:"type"`
+	Title string `json:"title"`
+	Des   string `json:"des"`
+	URL   string `json:"url"`
+	Raw   string `json:"raw"`
+}
+
+func (m *LinkMsg) AppType() int          { return m.Type }
+func (m *TextAppMsg) AppType() int       { return AppMsgText }
+func (m *FileMsg) AppType() int          { return AppMsgFile }
+func (m *MusicMsg) AppType() int         { return m.Type }
+func (m *MiniProgramMsg) AppType() int   { return m.Type }
+func (m *QuoteMsg) AppType() int         { return AppMsgQuote }
+func (m *RecordMsg) AppType() int        { return AppMsgRecord }
+func (m *TransferMsg) AppType() int      { return AppMsgTransfer }
+func (m *RedPacketMsg) AppType() int     { return AppMsgRedPacket }
+func (m *LocationShareMsg) AppType() int { return AppMsgLocationShare }
+func (m *ChannelsMsg) AppType() int      { return AppMsgChannels }
+func (m *PatMsg) AppType() int           { return AppMsgPat }
+func (m *UnknownAppMsg) AppType() int    { return m.Type }
+
+// ParseAppMsg 解析 Type 49 消息的 XML
+func ParseAppMsg(raw string) (AppMessage, error) {
+	raw = trimToXML(raw)
+	var x appMsgXML
+	dec := xml.NewDecoder(strings.NewReader(raw))
+	dec.Strict = false
+	if err := dec.Decode(&x); err != nil {
+		return nil, fmt.Errorf("parse appmsg failed: %v", err)
+	}
+	a := &x.AppMsg
+	source := a.SourceDisplayName
+	if source == "" {
+		source = x.AppInfo.AppName
+	}
+	switch a.Type {
+	case AppMsgText:
+		return &TextAppMsg{Title: a.Title, Des: a.Des, Source: source}, nil
+	case AppMsgLink, AppMsgVideo:
+		return &LinkMsg{Type: a.Type, Title: a.Title, Des: a.Des, URL: a.URL, ThumbURL: a.ThumbURL, Source: source}, nil
+	case AppMsgFile:
+		return &FileMsg{Title: a.Title, Ext: a.AppAttach.FileExt, Size: a.AppAttach.TotalLen, MD5: a.MD5, AttachID: a.AppAttach.AttachID}, nil
+	case AppMsgMusic, AppMsgMusic2:
+		return &MusicMsg{Type: a.Type, Title: a.Title, Des: a.Des, URL: a.URL, DataURL: a.DataURL, Source: source}, nil
+	case AppMsgMiniProgram, AppMsgMiniProgram2:
+		return &MiniProgramMsg{Type: a.Type, Title: a.Title, AppID: a.WeAppInfo.AppID, Username: a.WeAppInfo.Username,
+			PagePath: a.WeAppInfo.PagePath, Source: source}, nil
+	case AppMsgQuote:
+		q := &QuoteMsg{Title: a.Title}
+		if r := a.ReferMsg; r != nil {
+			q.Refer = ReferMsg{Type: r.Type, SvrID: parseSvrID(r.SvrID), FromUser: r.FromUser, ChatUser: r.ChatUser,
+				DisplayName: r.DisplayName, Content: r.Content, CreateTime: r.CreateTime}
+		}
+		return q, nil
+	case AppMsgRecord:
+		return &RecordMsg{Title: a.Title, Des: a.Des, RecordItem: a.RecordItem}, nil
+	case AppMsgTransfer:
+		p := &a.WCPayInfo
+		return &TransferMsg{PaySubType: p.PaySubType, FeeDesc: p.FeeDesc, TransferID: p.TransferID, Memo: p.PayMemo,
+			Payer: p.PayerUsername, Receiver: p.ReceiverUsername}, nil
+	case AppMsgRedPacket:
+		p := &a.WCPayInfo
+		return &RedPacketMsg{Title: p.SenderTitle, Des: p.SenderDes, SceneText: p.SceneText}, nil
+	case AppMsgLocationShare:
+		return &LocationShareMsg{Title: a.Title, Des: a.Des}, nil
+	case AppMsgChannels:
+		return &ChannelsMsg{Title: a.Title, Nickname: a.FinderFeed.Nickname, Desc: a.FinderFeed.Desc, URL: a.URL}, nil
+	case AppMsgPat:
+		return &PatMsg{Title: a.Title, Template: a.PatInfo.Template, FromUser: a.PatInfo.Fromuser}, nil
+	}
+	return &UnknownAppMsg{Type: a.Type, Title: a.Title, Des: a.Des, URL: a.URL, Raw: raw}, nil
+}
+
+// trimToXML 去掉群聊 "wxid_xxx:\n" 前缀等 XML 之前的内容
+func trimToXML(s string) string {
+	if i := strings.Index(s, "<"); i > 0 {
+		return s[i:]
+	}
+	return s
+}
+
+// AppMsg 解析 Type 49 消息，XML 取自 CompressContent 或 Content
+func (m *Message) AppMsg() (AppMessage, error) {
+	if m.Type != MsgTypeApp {
+		return nil, fmt.Errorf("message type %d is not appmsg", m.Type)
+	}
+	return ParseAppMsg(m.XML())
+}
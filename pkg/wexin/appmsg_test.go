@@ -0,0 +1,75 @@
+package wexin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAppMsg(t *testing.T) {
+	for _, c := range []struct {
+		xml  string
+		want AppMessage
+	}{
+		{
+			"wxid_a:\n<?xml version=\"1.0\"?><msg><appmsg appid=\"\" sdkver=\"0\"><title>回复</title><type>57</type><refermsg><type>1</type>" +
+				"<svrid>123456789012</svrid><fromusr>wxid_b</fromusr><displayname>B</displayname><content>原文 &amp; more</content>" +
+				"<createtime>1700000000</createtime></refermsg></appmsg></msg>",
+			&QuoteMsg{Title: "回复", Refer: ReferMsg{Type: 1, SvrID: 123456789012, FromUser: "wxid_b", DisplayName: "B",
+				Content: "原文 & more", CreateTime: 1700000000}},
+		},
+		{
+			// 超过 int64 的 svrid 按补码转成有符号数
+			`<msg><appmsg><title>回复</title><type>57</type><refermsg><svrid>18446744073709551615</svrid></refermsg></appmsg></msg>`,
+			&QuoteMsg{Title: "回复", Refer: ReferMsg{SvrID: -1}},
+		},
+		{
+			`<msg><appmsg><title>hello</title><type>1</type></appmsg><appinfo><appname>App</appname></appinfo></msg>`,
+			&TextAppMsg{Title: "hello", Source: "App"},
+		},
+		{
+			`<msg><appmsg><title>a.pdf</title><type>6</type><appattach><totallen>1024</totallen><fileext>pdf</fileext>` +
+				`<attachid>@cdn_x</attachid></appattach><md5>abc</md5></appmsg></msg>`,
+			&FileMsg{Title: "a.pdf", Ext: "pdf", Size: 1024, MD5: "abc", AttachID: "@cdn_x"},
+		},
+		{
+			`<msg><appmsg><title>聊天记录</title><type>19</type><recorditem><![CDATA[<recordinfo/>]]></recorditem></appmsg></msg>`,
+			&RecordMsg{Title: "聊天记录", RecordItem: "<recordinfo/>"},
+		},
+		{
+			`<msg><appmsg><type>2000</type><wcpayinfo><paysubtype>1</paysubtype><feedesc>￥1.00</feedesc>` +
+				`<pay_memo>饭钱</pay_memo></wcpayinfo></appmsg></msg>`,
+			&TransferMsg{PaySubType: 1, FeeDesc: "￥1.00", Memo: "饭钱"},
+		},
+		{
+			`<msg><appmsg><type>5</type><title>t</title><url>http://a</url><sourcedisplayname>公众号</sourcedisplayname></appmsg></msg>`,
+			&LinkMsg{Type: AppMsgLink, Title: "t", URL: "http://a", Source: "公众号"},
+		},
+		{
+			`<msg><appmsg><type>999</type><title>x</title></appmsg></msg>`,
+			&UnknownAppMsg{Type: 999, Title: "x", Raw: `<msg><appmsg><type>999</type><title>x</title></appmsg></msg>`},
+		},
+	} {
+		got, err := ParseAppMsg(c.xml)
+		if err != nil {
+			t.Errorf("%s: %v", c.xml, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s:\ngot  %#v\nwant %#v", c.xml, got, c.want)
+		}
+	}
+	if _, err := ParseAppMsg("not xml"); err == nil {
+		t.Error("not xml: want error")
+	}
+}
+
+func TestMessageAppMsg(t *testing.T) {
+	// 解压后的 CompressContent 优先于 Content
+	m := &Message{Type: MsgTypeApp, Content: "[链接]", CompressContent: []byte(`<msg><appmsg><type>5</type><url>http://a</url></appmsg></msg>`)}
+	if app, err := m.AppMsg(); err != nil || app.(*LinkMsg).URL != "http://a" {
+		t.Errorf("AppMsg = %#v, %v", app, err)
+	}
+	if _, err := (&Message{Type: MsgTypeText, Content: "<msg/>"}).AppMsg(); err == nil {
+		t.Error("text message: want error")
+	}
+}
@@ -0,0 +1,367 @@
+package wexin
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/saucer-man/wxdump/pkg/pbwire"
+	"github.com/sirupsen/logrus"
+)
+
+// v3 ChatRoom.RoomData、v4 chat_room.ext_buffer：字段 1 为重复的 {1: wxid, 2: 群昵称, 3: 状态}
+const (
+	roomDataMemberField      = 1
+	roomDataUsernameField    = 1
+	roomDataDisplayNameField = 2
+	roomDataStateField       = 3
+)
+
+// v3 ChatRoom.UserNameList 的分隔符
+const v3RoomMemberSep = "^G"
+
+// ChatRoomMember 群成员，DisplayName 为群昵称，没有设置时为空
+type ChatRoomMember struct {
+	Username    string
+	DisplayName string
+	State       int64
+}
+
+// ChatRoom 群聊。
+// 数据库不记录创建时间和创建者：v3 ChatRoom / ChatRoomInfo、v4 chat_room / chat_room_info_detail 都没有这两项，
+// RoomData、ext_buffer 里只有成员列表，群主可以转让，Owner 不一定是创建者。
+// InferredCreatedAt、InferredCreator 由 InferCreation 从聊天记录开头的建群系统消息推断，本地记录不全时为空
+type ChatRoom struct {
+	Username           string // xxx@chatroom
+	Owner              string // 当前群主
+	SelfDisplayName    string // 自己的群昵称（v3）
+	Members            []ChatRoomMember
+	Announcement       string
+	AnnouncementEditor string
+	AnnouncementTime   time.Time
+	InferredCreatedAt  time.Time // 建群系统消息的时间
+	InferredCreator    string    // 建群系统消息中的显示名，自己建的群为 "你"
+
+	memberIndex map[string]int
+}
+
+// Member 按 username 查找群成员
+func (r *ChatRoom) Member(username string) (*ChatRoomMember, bool) {
+	if r.memberIndex == nil {
+		r.memberIndex = make(map[string]int, len(r.Members))
+		for i, m := range r.Members {
+			r.memberIndex[m.Username] = i
+		}
+	}
+	i, ok := r.memberIndex[username]
+	if !ok {
+		return nil, false
+	}
+	return &r.Members[i], true
+}
+
+// addMember 添加成员，已存在时只补全群昵称
+func (r *ChatRoom) addMember(m ChatRoomMember) {
+	if m.Username == "" {
+		return
+	}
+	if old, ok := r.Member(m.Username); ok {
+		if old.DisplayName == "" {
+			old.DisplayName = m.DisplayName
+		}
+		return
+	}
+	r.memberIndex[m.Username] = len(r.Members)
+	r.Members = append(r.Members, m)
+}
+
+// parseRoomData 解析 v3 RoomData / v4 ext_buffer 中的成员和群昵称
+func parseRoomData(data []byte) ([]ChatRoomMember, error) {
+	msg, err := pbwire.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	var members []ChatRoomMember
+	for _, f := range msg.Get(roomDataMemberField) {
+		var m ChatRoomMember
+		if v, ok := f.Message.First(roomDataUsernameField); ok && v.IsString() {
+			m.Username = v.String()
+		}
+		if v, ok := f.Message.First(roomDataDisplayNameField); ok && v.IsString() {
+			m.DisplayName = v.String()
+		}
+		if v, ok := f.Message.First(roomDataStateField); ok {
+			m.State = int64(v.Varint)
+		}
+		if m.Username != "" {
+			members = append(members, m)
+		}
+	}
+	return members, nil
+}
+
+// 建群时的系统消息：
+//
+//	"张三"邀请你和"李四"加入了群聊
+//	你邀请"张三、李四"加入了群聊
+//	张三创建了群聊 / 你发起了群聊
+var (
+	roomInvitedRe = regexp.MustCompile(`^["“]?(.+?)["”]?邀请你(?:和.+)?加入了群聊`)
+	roomInviteRe  = regexp.MustCompile(`^你邀请.+加入了群聊`)
+	roomCreateRe  = regexp.MustCompile(`^["“]?(.+?)["”]?(?:创建|发起)了群聊`)
+)
+
+// roomCreationScanLimit 只在会话最早的若干条消息中查找建群消息，后面的邀请不是建群
+const roomCreationScanLimit = 20
+
+// roomCreator 判断是否是建群系统消息，返回创建者的显示名
+func roomCreator(m *Message) (string, bool) {
+	if m.Type != MsgTypeSystem {
+		return "", false
+	}
+	text := strings.TrimSpace(m.Content)
+	if roomInviteRe.MatchString(text) {
+		return "你", true
+	}
+	for _, re := range []*regexp.Regexp{roomInvitedRe, roomCreateRe} {
+		if sub := re.FindStringSubmatch(text); sub != nil {
+			return sub[1], true
+		}
+	}
+	return "", false
+}
+
+// InferCreation 从按时间排序的消息中推断创建时间和创建者，找到时返回 true。
+// 只看最早的 roomCreationScanLimit 条消息，本地记录不是从建群开始时推断不出；
+// 被拉进已有的群时也是 "邀请你加入了群聊"，这时得到的是入群时间和邀请人
+func (r *ChatRoom) InferCreation(msgs []*Message) bool {
+	for i, m := range msgs {
+		if i >= roomCreationScanLimit {
+			break
+		}
+		if creator, ok := roomCreator(m); ok {
+			r.InferredCreatedAt, r.InferredCreator = m.CreateTime, creator
+			return true
+		}
+	}
+	return false
+}
+
+// InferCreation 对所有群聊调用 ChatRoom.InferCreation，每个群只读取最早的几条消息
+func (rs *ChatRooms) InferCreation(store MessageStore) {
+	found := 0
+	for _, r := range rs.byUsername {
+		it, err := store.Messages(r.Username)
+		if err != nil {
+			logrus.Debugf("read messages of %s failed: %v", r.Username, err)
+			continue
+		}
+		var head []*Message
+		for len(head) < roomCreationScanLimit && it.Next() {
+			head = append(head, it.Message())
+		}
+		it.Close()
+		if r.InferCreation(head) {
+			found++
+		}
+	}
+	logrus.Infof("infer creation of %d/%d chatrooms from messages", found, len(rs.byUsername))
+}
+
+// ChatRooms 按 username 索引的群聊
+type ChatRooms struct {
+	byUsername map[string]*ChatRoom
+}
+
+// Get 按 username 查找
+func (rs *ChatRooms) Get(username string) (*ChatRoom, bool) {
+	r, ok := rs.byUsername[username]
+	return r, ok
+}
+
+// All 所有群聊，按 username 排序
+func (rs *ChatRooms) All() []*ChatRoom {
+	list := make([]*ChatRoom, 0, len(rs.byUsername))
+	for _, r := range rs.byUsername {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Username < list[j].Username })
+	return list
+}
+
+func (rs *ChatRooms) room(username string) *ChatRoom {
+	r, ok := rs.byUsername[username]
+	if !ok {
+		r = &ChatRoom{Username: username, memberIndex: make(map[string]int)}
+		rs.byUsername[username] = r
+	}
+	return r
+}
+
+// LoadChatRooms 读取群聊信息：v4 contact.db 的 chat_room、chatroom_member，v3 MicroMsg.db 的 ChatRoom、ChatRoomInfo
+func (a *Account) LoadChatRooms(decryptedDir string) (*ChatRooms, error) {
+	dbPath, err := a.contactDBPath(decryptedDir)
+	if err != nil {
+		return nil, err
+	}
+	rs := &ChatRooms{byUsername: make(map[string]*ChatRoom)}
+	if a.Version == 4 {
+		err = loadChatRoomsV4(dbPath, rs)
+	} else {
+		err = loadChatRoomsV3(dbPath, rs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("load %d chatrooms from %s", len(rs.byUsername), dbPath)
+	return rs, nil
+}
+
+func loadChatRoomsV3(dbPath string, rs *ChatRooms) error {
+	err := querySQLite(dbPath, `SELECT ChatRoomName, UserNameList, Owner, SelfDisplayName, RoomData FROM ChatRoom`, func(rows *sql.Rows) error {
+		var name, memberList, owner, selfName sql.NullString
+		var roomData []byte
+		if err := rows.Scan(&name, &memberList, &owner, &selfName, &roomData); err != nil {
+			return err
+		}
+		r := rs.room(name.String)
+		r.Owner = owner.String
+		r.SelfDisplayName = selfName.String
+		addRoomDataMembers(r, roomData)
+		for _, username := range strings.Split(memberList.String, v3RoomMemberSep) {
+			r.addMember(ChatRoomMember{Username: username})
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("query ChatRoom failed: %v", err)
+	}
+
+	err = querySQLite(dbPath, `SELECT ChatRoomName, Announcement, AnnouncementEditor, AnnouncementPublishTime FROM ChatRoomInfo`, func(rows *sql.Rows) error {
+		var name, announcement, editor sql.NullString
+		var publishTime sql.NullInt64
+		if err := rows.Scan(&name, &announcement, &editor, &publishTime); err != nil {
+			return err
+		}
+		setAnnouncement(rs, name.String, announcement.String, editor.String, publishTime.Int64)
+		return nil
+	})
+	if err != nil {
+		logrus.Debugf("query ChatRoomInfo failed: %v", err)
+	}
+	return nil
+}
+
+func loadChatRoomsV4(dbPath string, rs *ChatRooms) error {
+	err := querySQLite(dbPath, `SELECT username, owner, ext_buffer FROM chat_room`, func(rows *sql.Rows) error {
+		var name, owner sql.NullString
+		var extBuffer []byte
+		if err := rows.Scan(&name, &owner, &extBuffer); err != nil {
+			return err
+		}
+		r := rs.room(name.String)
+		r.Owner = owner.String
+		addRoomDataMembers(r, extBuffer)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("query chat_room failed: %v", err)
+	}
+
+	// chatroom_member 中的 room_id、member_id 分别对应 chat_room.id、contact.id
+	err = querySQLite(dbPath, `SELECT r.username, c.username FROM chatroom_member m
+		JOIN chat_room r ON r.id = m.room_id JOIN contact c ON c.id = m.member_id`, func(rows *sql.Rows) error {
+		var room, member sql.NullString
+		if err := rows.Scan(&room, &member); err != nil {
+			return err
+		}
+		rs.room(room.String).addMember(ChatRoomMember{Username: member.String})
+		return nil
+	})
+	if err != nil {
+		logrus.Debugf("query chatroom_member failed: %v", err)
+	}
+
+	err = querySQLite(dbPath, `SELECT r.username, d.announcement_, d.announcement_editor_, d.announcement_publish_time_
+		FROM chat_room_info_detail d JOIN chat_room r ON r.id = d.room_id_`, func(rows *sql.Rows) error {
+		var name, announcement, editor sql.NullString
+		var publishTime sql.NullInt64
+		if err := rows.Scan(&name, &announcement, &editor, &publishTime); err != nil {
+			return err
+		}
+		setAnnouncement(rs, name.String, announcement.String, editor.String, publishTime.Int64)
+		return nil
+	})
+	if err != nil {
+		logrus.Debugf("query chat_room_info_detail failed: %v", err)
+	}
+	return nil
+}
+
+func addRoomDataMembers(r *ChatRoom, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	members, err := parseRoomData(data)
+	if err != nil {
+		logrus.Debugf("parse room data of %s failed: %v", r.Username, err)
+		return
+	}
+	for _, m := range members {
+		r.addMember(m)
+	}
+}
+
+func setAnnouncement(rs *ChatRooms, name, announcement, editor string, publishTime int64) {
+	r, ok := rs.byUsername[name]
+	if !ok {
+		return
+	}
+	r.Announcement = announcement
+	r.AnnouncementEditor = editor
+	if publishTime > 0 {
+		r.AnnouncementTime = time.Unix(publishTime, 0)
+	}
+}
+
+// NameResolver 解析消息发送者的显示名，群聊中按 备注 > 群昵称 > 昵称 > 微信号 的顺序，
+// 与微信客户端中看到的一致
+type NameResolver struct {
+	Contacts  *Contacts
+	ChatRooms *ChatRooms
+}
+
+// SenderName talker 会话中 sender 的显示名
+func (nr *NameResolver) SenderName(talker, sender string) string {
+	if sender == "" {
+		return ""
+	}
+	var contact *Contact
+	if nr.Contacts != nil {
+		contact, _ = nr.Contacts.Get(sender)
+	}
+	if contact != nil && contact.Remark != "" {
+		return contact.Remark
+	}
+	if nr.ChatRooms != nil {
+		if r, ok := nr.ChatRooms.Get(talker); ok {
+			if m, ok := r.Member(sender); ok && m.DisplayName != "" {
+				return m.DisplayName
+			}
+		}
+	}
+	if contact != nil {
+		return contact.DisplayName()
+	}
+	return sender
+}
+
+// Annotate 填充消息的 SenderName，合并转发的记录自带显示名，不会被覆盖
+func (nr *NameResolver) Annotate(m *Message) {
+	if m.SenderName == "" {
+		m.SenderName = nr.SenderName(m.Talker, m.Sender)
+	}
+}
@@ -0,0 +1,132 @@
+package wexin
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// roomDataMembers 构造 RoomData / ext_buffer，members 为 [wxid, 群昵称]
+func roomDataMembers(members ...[2]string) []byte {
+	var out []byte
+	for _, m := range members {
+		item := pbBytes(roomDataUsernameField, []byte(m[0]))
+		if m[1] != "" {
+			item = append(item, pbBytes(roomDataDisplayNameField, []byte(m[1]))...)
+		}
+		item = append(item, pbVarint(roomDataStateField, 0)...)
+		out = append(out, pbBytes(roomDataMemberField, item)...)
+	}
+	return out
+}
+
+func TestLoadChatRoomsV3(t *testing.T) {
+	dir := t.TempDir()
+	db := createDB(t, filepath.Join(dir, "wxid_me", "Msg", "MicroMsg.db"),
+		`CREATE TABLE Contact(UserName TEXT, Alias TEXT, Type INT, VerifyFlag INT, Remark TEXT, NickName TEXT, LabelIDList TEXT,
+			QuanPin TEXT, RemarkQuanPin TEXT, BigHeadImgUrl TEXT, SmallHeadImgUrl TEXT, ExtraBuf BLOB)`,
+		`CREATE TABLE ContactHeadImgUrl(usrName TEXT, smallHeadImgUrl TEXT, bigHeadImgUrl TEXT)`,
+		`INSERT INTO Contact(UserName, Alias, Type, VerifyFlag, Remark, NickName) VALUES
+			('wxid_a', '', 1, 0, '', 'Alice'), ('wxid_b', '', 1, 0, '老B', 'Bob'), ('wxid_c', 'cc', 1, 0, '', '')`,
+		`CREATE TABLE ChatRoom(ChatRoomName TEXT, UserNameList TEXT, Owner TEXT, SelfDisplayName TEXT, RoomData BLOB)`,
+		`CREATE TABLE ChatRoomInfo(ChatRoomName TEXT, Announcement TEXT, AnnouncementEditor TEXT, AnnouncementPublishTime INT)`,
+		`INSERT INTO ChatRoomInfo VALUES ('g@chatroom', '公告', 'wxid_a', 1700000000)`)
+	_, err := db.Exec(`INSERT INTO ChatRoom VALUES ('g@chatroom', 'wxid_a^Gwxid_b^Gwxid_c', 'wxid_a', '我', ?)`,
+		roomDataMembers([2]string{"wxid_a", "阿A"}, [2]string{"wxid_b", ""}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &Account{Version: 3, Wxid: "wxid_me"}
+	rs, err := a.LoadChatRooms(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, ok := rs.Get("g@chatroom")
+	if !ok {
+		t.Fatal("chatroom not loaded")
+	}
+	if len(r.Members) != 3 || r.Owner != "wxid_a" || r.SelfDisplayName != "我" || r.Announcement != "公告" ||
+		!r.AnnouncementTime.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("chatroom = %+v", r)
+	}
+	contacts, err := a.LoadContacts(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nr := &NameResolver{Contacts: contacts, ChatRooms: rs}
+	for sender, want := range map[string]string{"wxid_a": "阿A", "wxid_b": "老B", "wxid_c": "cc", "wxid_x": "wxid_x"} {
+		if got := nr.SenderName("g@chatroom", sender); got != want {
+			t.Errorf("SenderName(%s) = %q, want %q", sender, got, want)
+		}
+	}
+}
+
+func TestLoadChatRoomsV4(t *testing.T) {
+	dir := t.TempDir()
+	db := createDB(t, filepath.Join(dir, "wxid_me", "contact", "contact.db"),
+		`CREATE TABLE contact(id INTEGER PRIMARY KEY, username TEXT, local_type INTEGER, alias TEXT, remark TEXT, remark_quan_pin TEXT,
+			nick_name TEXT, quan_pin TEXT, big_head_url TEXT, small_head_url TEXT, description TEXT, extra_buffer BLOB, flag INTEGER,
+			verify_flag INTEGER)`,
+		`INSERT INTO contact(id, username, nick_name) VALUES (1, 'wxid_a', 'Alice'), (2, 'wxid_b', 'Bob'), (3, 'g@chatroom', 'G')`,
+		`CREATE TABLE chat_room(id INTEGER PRIMARY KEY, username TEXT, owner TEXT, ext_buffer BLOB)`,
+		`CREATE TABLE chatroom_member(room_id INTEGER, member_id INTEGER)`,
+		`INSERT INTO chatroom_member VALUES (10, 1), (10, 2)`)
+	if _, err := db.Exec(`INSERT INTO chat_room VALUES (10, 'g@chatroom', 'wxid_b', ?)`, roomDataMembers([2]string{"wxid_a", "阿A"})); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &Account{Version: 4, Wxid: "wxid_me"}
+	rs, err := a.LoadChatRooms(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _ := rs.Get("g@chatroom")
+	if r == nil || len(r.Members) != 2 || r.Owner != "wxid_b" {
+		t.Fatalf("chatroom = %+v", r)
+	}
+	contacts, err := a.LoadContacts(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nr := &NameResolver{Contacts: contacts, ChatRooms: rs}
+	m := &Message{Talker: "g@chatroom", Sender: "wxid_a"}
+	nr.Annotate(m)
+	if m.SenderName != "阿A" || nr.SenderName("g@chatroom", "wxid_b") != "Bob" {
+		t.Errorf("sender names = %q, %q", m.SenderName, nr.SenderName("g@chatroom", "wxid_b"))
+	}
+}
+
+func TestChatRoomInferCreation(t *testing.T) {
+	sys := func(sec int64, text string) *Message {
+		return &Message{Type: MsgTypeSystem, Content: text, CreateTime: time.Unix(sec, 0)}
+	}
+	text := &Message{Type: MsgTypeText, Content: "张三创建了群聊", CreateTime: time.Unix(1, 0)}
+	for _, c := range []struct {
+		msgs    []*Message
+		creator string
+		at      int64
+	}{
+		{[]*Message{text, sys(2, `"张三"邀请你和"李四"加入了群聊`)}, "张三", 2},
+		{[]*Message{sys(3, `你邀请"张三、李四"加入了群聊`)}, "你", 3},
+		{[]*Message{sys(4, "你发起了群聊"), sys(5, `"王五"邀请你加入了群聊`)}, "你", 4},
+		{[]*Message{sys(6, "“张三”创建了群聊")}, "张三", 6},
+		{[]*Message{sys(7, "你撤回了一条消息")}, "", 0},
+	} {
+		r := &ChatRoom{}
+		found := r.InferCreation(c.msgs)
+		if found != (c.creator != "") || r.InferredCreator != c.creator || (found && r.InferredCreatedAt.Unix() != c.at) {
+			t.Errorf("%q: creator = %q at %v, want %q at %d", c.msgs[len(c.msgs)-1].Content, r.InferredCreator, r.InferredCreatedAt, c.creator, c.at)
+		}
+	}
+
+	// 建群消息不在最早的几条消息里时不推断
+	var late []*Message
+	for i := 0; i < roomCreationScanLimit; i++ {
+		late = append(late, &Message{Type: MsgTypeText})
+	}
+	late = append(late, sys(1, "张三创建了群聊"))
+	if (&ChatRoom{}).InferCreation(late) {
+		t.Error("creation message after scan limit should be ignored")
+	}
+}
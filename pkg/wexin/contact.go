@@ -0,0 +1,251 @@
+package wexin
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// v3 Contact.Type、v4 contact.flag 的标志位
+const (
+	contactFlagFriend  = 1 << 0
+	contactFlagBlocked = 1 << 3
+	contactFlagStar    = 1 << 6
+	contactFlagMute    = 1 << 9  // 只有 v3
+	contactFlagSticky  = 1 << 11 // 置顶，只有 v3
+)
+
+// v4 contact.local_type
+const (
+	contactLocalTypeFriend   = 1
+	contactLocalTypeChatRoom = 2
+)
+
+// Contact 联系人，包括好友、群聊、公众号以及群里的陌生人
+type Contact struct {
+	Username     string // wxid_xxx、xxx@chatroom、gh_xxx
+	Alias        string // 微信号
+	Nickname     string
+	Remark       string
+	PinYin       string // 昵称全拼
+	RemarkPinYin string // 备注全拼
+	Description  string // 个性签名
+	SmallHeadURL string
+	BigHeadURL   string
+	Flag         int64 // v3 Type / v4 flag
+	VerifyFlag   int64 // 不为 0 时是公众号
+	LabelIDs     []string
+	Labels       []string
+
+	IsFriend   bool
+	IsChatRoom bool
+	IsOfficial bool
+	IsBlocked  bool
+	IsStar     bool
+	IsMuted    bool // 消息免打扰，只有 v3
+	IsSticky   bool // 置顶，只有 v3
+}
+
+// DisplayName 显示名：备注 > 昵称 > 微信号 > username
+func (c *Contact) DisplayName() string {
+	for _, name := range []string{c.Remark, c.Nickname, c.Alias} {
+		if name != "" {
+			return name
+		}
+	}
+	return c.Username
+}
+
+// AvatarURL 头像地址，优先使用大图
+func (c *Contact) AvatarURL() string {
+	if c.BigHeadURL != "" {
+		return c.BigHeadURL
+	}
+	return c.SmallHeadURL
+}
+
+func (c *Contact) setFlags(version int, localType int64) {
+	c.IsChatRoom = strings.HasSuffix(c.Username, "@chatroom")
+	c.IsOfficial = c.VerifyFlag != 0 || strings.HasPrefix(c.Username, "gh_")
+	c.IsBlocked = c.Flag&contactFlagBlocked != 0
+	c.IsStar = c.Flag&contactFlagStar != 0
+	// 免打扰、置顶来自 v3 Contact.Type；v4 contact.flag 中对应的位没有确认过，不设置
+	if version != 4 {
+		c.IsMuted = c.Flag&contactFlagMute != 0
+		c.IsSticky = c.Flag&contactFlagSticky != 0
+	}
+	if version == 4 {
+		c.IsChatRoom = c.IsChatRoom || localType == contactLocalTypeChatRoom
+		c.IsFriend = localType == contactLocalTypeFriend && !c.IsChatRoom && !c.IsOfficial
+	} else {
+		c.IsFriend = c.Flag&contactFlagFriend != 0 && !c.IsChatRoom && !c.IsOfficial
+	}
+}
+
+// Contacts 按 username 索引的联系人
+type Contacts struct {
+	list       []*Contact
+	byUsername map[string]*Contact
+}
+
+func newContacts(list []*Contact) *Contacts {
+	cs := &Contacts{list: list, byUsername: make(map[string]*Contact, len(list))}
+	for _, c := range list {
+		cs.byUsername[c.Username] = c
+	}
+	return cs
+}
+
+// Get 按 username 查找
+func (cs *Contacts) Get(username string) (*Contact, bool) {
+	c, ok := cs.byUsername[username]
+	return c, ok
+}
+
+// DisplayName username 对应的显示名，没有记录时返回 username
+func (cs *Contacts) DisplayName(username string) string {
+	if c, ok := cs.byUsername[username]; ok {
+		return c.DisplayName()
+	}
+	return username
+}
+
+// All 所有联系人，按 username 排序
+func (cs *Contacts) All() []*Contact {
+	return cs.list
+}
+
+// LoadContacts 读取解密后的联系人库：v4 contact.db，v3 MicroMsg.db
+func (a *Account) LoadContacts(decryptedDir string) (*Contacts, error) {
+	dbPath, err := a.contactDBPath(decryptedDir)
+	if err != nil {
+		return nil, err
+	}
+	list, err := queryContacts(dbPath, a.Version, "")
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Username < list[j].Username })
+	logrus.Infof("load %d contacts from %s", len(list), dbPath)
+	return newContacts(list), nil
+}
+
+func (a *Account) contactDBPath(decryptedDir string) (string, error) {
+	if a.Version == 4 {
+		return a.decryptedDBPath(decryptedDir, v4ContactDBRel)
+	}
+	return a.decryptedDBPath(decryptedDir, v3MicroMsgDBRel, v3MicroMsgDBRelLegacy)
+}
+
+const (
+	contactQueryV4 = `SELECT username, local_type, alias, nick_name, remark, remark_quan_pin, quan_pin, description,
+		small_head_url, big_head_url, flag, verify_flag, extra_buffer FROM contact`
+	contactQueryV3 = `SELECT c.UserName, c.Type, c.Alias, c.NickName, c.Remark, c.RemarkQuanPin, c.QuanPin, c.LabelIDList,
+		IFNULL(h.smallHeadImgUrl, c.SmallHeadImgUrl), IFNULL(h.bigHeadImgUrl, c.BigHeadImgUrl), c.VerifyFlag, c.ExtraBuf
+		FROM Contact c LEFT JOIN ContactHeadImgUrl h ON h.usrName = c.UserName`
+)
+
+// queryContacts 查询联系人，username 不为空时只查这一个
+func queryContacts(dbPath string, version int, username string) ([]*Contact, error) {
+	query := contactQueryV3
+	usernameCol, labelQuery := "c.UserName", `SELECT LabelId, LabelName FROM ContactLabel`
+	if version == 4 {
+		query = contactQueryV4
+		usernameCol, labelQuery = "username", `SELECT label_id_, label_name_ FROM contact_label`
+	}
+	var args []any
+	if username != "" {
+		query += " WHERE " + usernameCol + " = ?"
+		args = append(args, username)
+	}
+
+	var list []*Contact
+	err := querySQLite(dbPath, query, func(rows *sql.Rows) error {
+		c, err := scanContact(rows, version)
+		if err != nil {
+			return err
+		}
+		list = append(list, c)
+		return nil
+	}, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query contacts failed: %v", err)
+	}
+
+	// 标签表不存在（没有设置过标签）时忽略
+	labels := make(map[string]string)
+	err = querySQLite(dbPath, labelQuery, func(rows *sql.Rows) error {
+		var id, name sql.NullString
+		if err := rows.Scan(&id, &name); err != nil {
+			return err
+		}
+		labels[id.String] = name.String
+		return nil
+	})
+	if err != nil {
+		logrus.Debugf("query contact labels failed: %v", err)
+	}
+	for _, c := range list {
+		for _, id := range c.LabelIDs {
+			if name, ok := labels[id]; ok {
+				c.Labels = append(c.Labels, name)
+			}
+		}
+	}
+	return list, nil
+}
+
+func scanContact(rows *sql.Rows, version int) (*Contact, error) {
+	var (
+		username, alias, nickname, remark, remarkPinYin, pinYin, smallHead, bigHead sql.NullString
+		flag, verifyFlag, localType                                                 sql.NullInt64
+	)
+	c := &Contact{}
+	if version == 4 {
+		var description sql.NullString
+		var extra []byte
+		err := rows.Scan(&username, &localType, &alias, &nickname, &remark, &remarkPinYin, &pinYin, &description,
+			&smallHead, &bigHead, &flag, &verifyFlag, &extra)
+		if err != nil {
+			return nil, err
+		}
+		c.Description = description.String
+		if len(extra) > 0 {
+			if ce, err := ParseContactExtraV4(extra); err == nil {
+				c.LabelIDs = ce.LabelIDs
+				if remark.String == "" {
+					remark.String = ce.Remark
+				}
+			}
+		}
+	} else {
+		var labelIDs sql.NullString
+		var extra []byte
+		err := rows.Scan(&username, &flag, &alias, &nickname, &remark, &remarkPinYin, &pinYin, &labelIDs,
+			&smallHead, &bigHead, &verifyFlag, &extra)
+		if err != nil {
+			return nil, err
+		}
+		c.LabelIDs = splitLabelIDs(labelIDs.String)
+		if len(extra) > 0 {
+			if ce, err := ParseContactExtraV3(extra); err == nil {
+				c.Description = ce.Description
+			}
+		}
+	}
+	c.Username = username.String
+	c.Alias = alias.String
+	c.Nickname = nickname.String
+	c.Remark = remark.String
+	c.RemarkPinYin = remarkPinYin.String
+	c.PinYin = pinYin.String
+	c.SmallHeadURL = smallHead.String
+	c.BigHeadURL = bigHead.String
+	c.Flag = flag.Int64
+	c.VerifyFlag = verifyFlag.Int64
+	c.setFlags(version, localType.Int64)
+	return c, nil
+}
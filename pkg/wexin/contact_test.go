@@ -0,0 +1,77 @@
+package wexin
+
+import (
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+)
+
+// contactExtraV3 构造带个性签名的 v3 Contact.ExtraBuf，前面加一个整数字段
+func contactExtraV3(signature string) []byte {
+	out := []byte{0x74, 0x75, 0x2C, 0x06, 0x04, 1, 0, 0, 0}
+	var value []byte
+	for _, u := range utf16.Encode([]rune(signature)) {
+		value = binary.LittleEndian.AppendUint16(value, u)
+	}
+	out = append(out, contactExtraV3SignatureKey...)
+	out = append(out, contactExtraV3UTF16)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(value)))
+	return append(out, value...)
+}
+
+func TestLoadContactsV3(t *testing.T) {
+	dir := t.TempDir()
+	db := createDB(t, filepath.Join(dir, "wxid_me", "Msg", "MicroMsg.db"),
+		`CREATE TABLE Contact(UserName TEXT, Alias TEXT, Type INT, VerifyFlag INT, Remark TEXT, NickName TEXT, LabelIDList TEXT,
+			QuanPin TEXT, RemarkQuanPin TEXT, BigHeadImgUrl TEXT, SmallHeadImgUrl TEXT, ExtraBuf BLOB)`,
+		`CREATE TABLE ContactHeadImgUrl(usrName TEXT, smallHeadImgUrl TEXT, bigHeadImgUrl TEXT)`,
+		`CREATE TABLE ContactLabel(LabelId INT, LabelName TEXT)`,
+		`INSERT INTO ContactLabel VALUES (1, '同事'), (2, '家人')`,
+		`INSERT INTO Contact(UserName, Alias, Type, VerifyFlag, Remark, NickName, LabelIDList, QuanPin) VALUES
+			('wxid_b', 'bb', 9, 0, '', '', '', ''), ('gh_x', '', 3, 8, '', 'Pub', '', ''), ('1@chatroom', '', 2, 0, '', 'G', '', '')`)
+	_, err := db.Exec(`INSERT INTO Contact(UserName, Alias, Type, VerifyFlag, Remark, NickName, LabelIDList, QuanPin, ExtraBuf)
+		VALUES ('wxid_a', 'aa', 65, 0, '', 'Alice', '1,2,', 'alice', ?)`, contactExtraV3("今天也要开心"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs, err := (&Account{Version: 3, Wxid: "wxid_me"}).LoadContacts(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := cs.Get("wxid_a")
+	if c == nil || !c.IsFriend || !c.IsStar || len(c.Labels) != 2 || c.Labels[0] != "同事" || c.Description != "今天也要开心" {
+		t.Fatalf("wxid_a = %+v", c)
+	}
+	if b, _ := cs.Get("wxid_b"); b == nil || !b.IsBlocked || b.DisplayName() != "bb" || b.Description != "" {
+		t.Errorf("wxid_b = %+v", b)
+	}
+	if g, _ := cs.Get("gh_x"); g == nil || !g.IsOfficial || g.IsFriend {
+		t.Errorf("gh_x = %+v", g)
+	}
+	if r, _ := cs.Get("1@chatroom"); r == nil || !r.IsChatRoom || r.IsFriend {
+		t.Errorf("1@chatroom = %+v", r)
+	}
+}
+
+func TestParseContactExtraV3(t *testing.T) {
+	utf8Sig := append(append([]byte{}, contactExtraV3SignatureKey...), contactExtraV3UTF8, 3, 0, 0, 0, 'h', 'i', 0)
+	for _, c := range []struct {
+		data []byte
+		want string
+		err  bool
+	}{
+		{contactExtraV3("签名"), "签名", false},
+		{utf8Sig, "hi", false},
+		{[]byte{0x74, 0x75, 0x2C, 0x06, 0x04, 1, 0, 0, 0}, "", false},
+		{append(append([]byte{}, contactExtraV3SignatureKey...), contactExtraV3UTF8, 0xff, 0xff, 0, 0, 'x'), "", true},
+		{append(append([]byte{}, contactExtraV3SignatureKey...), 0x04, 1, 0, 0, 0), "", true},
+		{contactExtraV3SignatureKey, "", true},
+	} {
+		ce, err := ParseContactExtraV3(c.data)
+		if (err != nil) != c.err || (err == nil && ce.Description != c.want) {
+			t.Errorf("ParseContactExtraV3(%x) = %+v, %v", c.data, ce, err)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package wexin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/saucer-man/wxdump/pkg/pbwire"
+)
+
+// v3 BytesExtra：字段 3 为重复的 {1: 类型, 2: 值}
+const (
+	bytesExtraItemField  = 3
+	bytesExtraTypeField  = 1
+	bytesExtraValueField = 2
+
+	bytesExtraSender    = 1 // 群聊消息的发送者 wxid
+	bytesExtraThumbPath = 3 // 图片、视频缩略图路径
+	bytesExtraFilePath  = 4 // 图片、视频、文件的路径
+)
+
+// v4 packed_info_data：{1: 类型, 2: 版本, 3: 图片 {4: md5}, 4: 视频 {8: md5}}
+const (
+	packedInfoImageField    = 3
+	packedInfoImageMD5Field = 4
+	packedInfoVideoField    = 4
+	packedInfoVideoMD5Field = 8
+)
+
+// v4 contact.extra_buffer 中的备注和标签 ID 列表（"1,3," 形式）
+const (
+	contactExtraRemarkField = 10
+	contactExtraLabelField  = 30
+)
+
+// v3 Contact.ExtraBuf 不是 protobuf，而是 4 字节键 + 1 字节类型 + 值 的序列：
+// 0x04 为 4 字节整数，0x05 为 8 字节，0x17 为 4 字节长度 + UTF-8，0x18 为 4 字节长度 + UTF-16LE
+var contactExtraV3SignatureKey = []byte{0x46, 0xCF, 0x10, 0xC4} // 个性签名
+
+const (
+	contactExtraV3UTF8   = 0x17
+	contactExtraV3UTF16  = 0x18
+	contactExtraV3MaxLen = 1 << 16
+)
+
+var md5HexRe = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+
+// MsgExtra 从 BytesExtra / packed_info_data 中提取的已知字段。
+// v4 的发送者在 real_sender_id 列，媒体文件按 md5 命名，packed_info_data 中只提取 md5
+type MsgExtra struct {
+	Sender    string // 只有 v3
+	ThumbPath string // 只有 v3
+	FilePath  string // 只有 v3
+	ImageMD5  string // 只有 v4，图片或视频文件的 md5
+}
+
+// ParseExtra 按消息版本解析 Extra
+func (m *Message) ParseExtra() (*MsgExtra, error) {
+	if m.Version == 3 {
+		return ParseBytesExtraV3(m.Extra)
+	}
+	return ParsePackedInfoV4(m.Extra)
+}
+
+// ParseBytesExtraV3 解析 v3 MSG.BytesExtra
+func ParseBytesExtraV3(data []byte) (*MsgExtra, error) {
+	msg, err := pbwire.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	extra := &MsgExtra{}
+	for _, item := range msg.Get(bytesExtraItemField) {
+		typ, ok := item.Message.First(bytesExtraTypeField)
+		if !ok {
+			continue
+		}
+		val, ok := item.Message.First(bytesExtraValueField)
+		if !ok || !val.IsString() {
+			continue
+		}
+		switch typ.Varint {
+		case bytesExtraSender:
+			extra.Sender = val.String()
+		case bytesExtraThumbPath:
+			extra.ThumbPath = val.String()
+		case bytesExtraFilePath:
+			extra.FilePath = val.String()
+		}
+	}
+	return extra, nil
+}
+
+// ParsePackedInfoV4 解析 v4 packed_info_data，只提取图片、视频消息中的文件 md5，其他字段含义未知，
+// 需要时用 pbwire.Dump 查看
+func ParsePackedInfoV4(data []byte) (*MsgExtra, error) {
+	msg, err := pbwire.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	extra := &MsgExtra{}
+	for _, path := range [][]int{
+		{packedInfoImageField, packedInfoImageMD5Field},
+		{packedInfoVideoField, packedInfoVideoMD5Field},
+	} {
+		for _, f := range msg.Find(path...) {
+			if f.Type == pbwire.Bytes && md5HexRe.Match(f.Bytes) {
+				extra.ImageMD5 = strings.ToLower(string(f.Bytes))
+				return extra, nil
+			}
+		}
+	}
+	return extra, nil
+}
+
+// ContactExtra 从 v4 contact.extra_buffer / v3 Contact.ExtraBuf 中提取的已知字段
+type ContactExtra struct {
+	Remark      string // 一般和 contact.remark 列相同，列为空时使用，只有 v4
+	LabelIDs    []string
+	Description string // 个性签名，只有 v3，v4 在 contact.description 列
+}
+
+// ParseContactExtraV3 解析 v3 Contact.ExtraBuf，目前只提取个性签名
+func ParseContactExtraV3(data []byte) (*ContactExtra, error) {
+	extra := &ContactExtra{}
+	i := bytes.Index(data, contactExtraV3SignatureKey)
+	if i < 0 {
+		return extra, nil
+	}
+	value := data[i+len(contactExtraV3SignatureKey):]
+	if len(value) < 5 {
+		return nil, fmt.Errorf("truncated signature")
+	}
+	typ := value[0]
+	if typ != contactExtraV3UTF8 && typ != contactExtraV3UTF16 {
+		return nil, fmt.Errorf("unexpected signature type 0x%02x", typ)
+	}
+	n := binary.LittleEndian.Uint32(value[1:5])
+	value = value[5:]
+	if n > uint32(len(value)) || n > contactExtraV3MaxLen {
+		return nil, fmt.Errorf("bad signature length %d", n)
+	}
+	value = value[:n]
+	if typ == contactExtraV3UTF16 {
+		u := make([]uint16, len(value)/2)
+		for j := range u {
+			u[j] = binary.LittleEndian.Uint16(value[2*j:])
+		}
+		extra.Description = string(utf16.Decode(u))
+	} else {
+		extra.Description = strings.ToValidUTF8(string(value), "")
+	}
+	extra.Description = strings.TrimRight(extra.Description, "\x00")
+	return extra, nil
+}
+
+// ParseContactExtraV4 解析 v4 contact.extra_buffer
+func ParseContactExtraV4(data []byte) (*ContactExtra, error) {
+	msg, err := pbwire.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	extra := &ContactExtra{}
+	if f, ok := msg.First(contactExtraRemarkField); ok && f.Type == pbwire.Bytes {
+		extra.Remark = string(f.Bytes)
+	}
+	if f, ok := msg.First(contactExtraLabelField); ok && f.Type == pbwire.Bytes {
+		extra.LabelIDs = splitLabelIDs(string(f.Bytes))
+	}
+	return extra, nil
+}
+
+// splitLabelIDs 拆分 "1,3," 形式的标签 ID 列表
+func splitLabelIDs(s string) []string {
+	var ids []string
+	for _, id := range strings.Split(s, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,87 @@
+package wexin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// pbBytes、pbVarint 手工拼出 protobuf 字段，测试用
+func pbBytes(num int, b []byte) []byte {
+	out := binary.AppendUvarint(nil, uint64(num)<<3|2)
+	out = binary.AppendUvarint(out, uint64(len(b)))
+	return append(out, b...)
+}
+
+func pbVarint(num int, v uint64) []byte {
+	return binary.AppendUvarint(binary.AppendUvarint(nil, uint64(num)<<3), v)
+}
+
+// bytesExtraV3 构造 v3 BytesExtra，items 为 类型 => 值
+func bytesExtraV3(items ...any) []byte {
+	out := pbBytes(1, pbVarint(1, 0))
+	for i := 0; i+1 < len(items); i += 2 {
+		item := bytes.Join([][]byte{pbVarint(1, uint64(items[i].(int))), pbBytes(2, []byte(items[i+1].(string)))}, nil)
+		out = append(out, pbBytes(bytesExtraItemField, item)...)
+	}
+	return out
+}
+
+func TestParseBytesExtraV3(t *testing.T) {
+	data := bytesExtraV3(
+		bytesExtraSender, "wxid_sender",
+		bytesExtraThumbPath, `wxid_me\FileStorage\MsgAttach\x\Thumb\2024-01\a_t.dat`,
+		bytesExtraFilePath, `wxid_me\FileStorage\MsgAttach\x\Image\2024-01\a.dat`,
+		7, "<msgsource><md5>0123456789abcdef0123456789ABCDEF</md5></msgsource>",
+	)
+	e, err := ParseBytesExtraV3(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Sender != "wxid_sender" || e.ThumbPath != `wxid_me\FileStorage\MsgAttach\x\Thumb\2024-01\a_t.dat` ||
+		e.FilePath != `wxid_me\FileStorage\MsgAttach\x\Image\2024-01\a.dat` {
+		t.Errorf("extra = %+v", e)
+	}
+	if _, err := ParseBytesExtraV3([]byte{0x0a, 0x05, 0x01}); err == nil {
+		t.Error("truncated data, want error")
+	}
+}
+
+func TestParsePackedInfoV4(t *testing.T) {
+	const sum = "0123456789abcdef0123456789abcdef"
+	image := append(pbVarint(1, 106), pbBytes(packedInfoImageField, pbBytes(packedInfoImageMD5Field, []byte("0123456789ABCDEF0123456789abcdef")))...)
+	video := pbBytes(packedInfoVideoField, pbBytes(packedInfoVideoMD5Field, []byte(sum)))
+	notMD5 := pbBytes(packedInfoImageField, pbBytes(packedInfoImageMD5Field, []byte("thumb.jpg")))
+	for name, c := range map[string]struct {
+		data []byte
+		want string
+	}{
+		"image":   {image, sum},
+		"video":   {video, sum},
+		"not md5": {notMD5, ""},
+	} {
+		e, err := ParsePackedInfoV4(c.data)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if e.ImageMD5 != c.want {
+			t.Errorf("%s: md5 = %q, want %q", name, e.ImageMD5, c.want)
+		}
+	}
+}
+
+func TestParseContactExtraV4(t *testing.T) {
+	data := bytes.Join([][]byte{
+		pbBytes(4, []byte("12,34")),
+		pbBytes(contactExtraRemarkField, []byte("老王")),
+		pbBytes(contactExtraLabelField, []byte("1,5,")),
+	}, nil)
+	ce, err := ParseContactExtraV4(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ce.Remark != "老王" || len(ce.LabelIDs) != 2 || ce.LabelIDs[0] != "1" || ce.LabelIDs[1] != "5" {
+		t.Errorf("contact extra = %+v", ce)
+	}
+}
@@ -0,0 +1,275 @@
+package wexin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 图片格式的文件头，BMP 只有 2 个字节，容易误判，放在最后，推算异或 key 时票数（weight）也最低
+var imageFormats = []struct {
+	ext    string
+	magic  []byte
+	weight int
+}{
+	{"jpg", []byte{0xFF, 0xD8, 0xFF}, 4},
+	{"png", []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A}, 4},
+	{"gif", []byte("GIF8"), 4},
+	{"webp", []byte("RIFF"), 4},
+	{"tif", []byte{0x49, 0x49, 0x2A, 0x00}, 2},
+	{"tif", []byte{0x4D, 0x4D, 0x00, 0x2A}, 2},
+	{"bmp", []byte("BM"), 1},
+}
+
+// imageFormatWeight 返回扩展名对应的票数
+func imageFormatWeight(ext string) int {
+	for _, f := range imageFormats {
+		if f.ext == ext {
+			return f.weight
+		}
+	}
+	return 0
+}
+
+// bestXorKey 取票数最多的 key，票数相同时取较小的 key，保证结果稳定
+func bestXorKey(votes map[byte]int) byte {
+	var best byte
+	for key := 0; key <= 0xFF; key++ {
+		if votes[byte(key)] > votes[best] {
+			best = byte(key)
+		}
+	}
+	return best
+}
+
+const (
+	ImageIndexFile   = "index.json"
+	xorKeySampleSize = 20 // 推算异或 key 时抽样的文件数
+)
+
+// ImageIndexEntry 图片解码的映射记录，路径都是相对路径
+type ImageIndexEntry struct {
+	Src     string `json:"src"`
+	Dst     string `json:"dst,omitempty"`
+	Format  string `json:"format,omitempty"`
+	Variant string `json:"variant,omitempty"` // v4：thumb、hd、origin
+	Error   string `json:"error,omitempty"`
+}
+
+// DetectXorKey 用已知图片文件头推算单字节异或 key，返回 key 和图片扩展名
+func DetectXorKey(data []byte) (byte, string, bool) {
+	if len(data) < 2 {
+		return 0, "", false
+	}
+	for _, f := range imageFormats {
+		key := data[0] ^ f.magic[0]
+		if ext, ok := matchImageFormat(data, key); ok {
+			return key, ext, true
+		}
+	}
+	return 0, "", false
+}
+
+// matchImageFormat 判断 data 异或 key 之后是否是已知的图片格式
+func matchImageFormat(data []byte, key byte) (string, bool) {
+	for _, f := range imageFormats {
+		if len(data) < len(f.magic) || !xorHasPrefix(data, key, f.magic) {
+			continue
+		}
+		if f.ext == "webp" && (len(data) < 12 || !xorHasPrefix(data[8:], key, []byte("WEBP"))) {
+			continue
+		}
+		return f.ext, true
+	}
+	return "", false
+}
+
+func xorHasPrefix(data []byte, key byte, prefix []byte) bool {
+	for i, b := range prefix {
+		if data[i]^key != b {
+			return false
+		}
+	}
+	return true
+}
+
+func xorBytes(data []byte, key byte) []byte {
+	out := make([]byte, len(data))
+	for i, b := range data {
+		out[i] = b ^ key
+	}
+	return out
+}
+
+func formatXorKey(key byte) string {
+	return fmt.Sprintf("0x%02X", key)
+}
+
+func parseXorKey(s string) (byte, error) {
+	v, err := strconv.ParseUint(s, 0, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid xor key %q: %v", s, err)
+	}
+	return byte(v), nil
+}
+
+// DecodeDatV3 解码 v3 的 .dat 图片，返回图片数据和扩展名
+// key 与文件头对不上时（个别文件异或值不同）会重新推算
+func DecodeDatV3(data []byte, key byte) ([]byte, string, error) {
+	if ext, ok := matchImageFormat(data, key); ok {
+		return xorBytes(data, key), ext, nil
+	}
+	fileKey, ext, ok := DetectXorKey(data)
+	if !ok {
+		return nil, "", errors.New("unknown image format")
+	}
+	return xorBytes(data, fileKey), ext, nil
+}
+
+// v3 图片目录：FileStorage/Image/2024-01/xxx.dat
+func (a *Account) imageDirV3() string {
+	return filepath.Join(a.DataDir, "FileStorage", "Image")
+}
+
+// collectDatFiles 收集目录下所有 .dat 文件
+func collectDatFiles(root string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.EqualFold(filepath.Ext(path), ".dat") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// GetImageXorKeyV3 抽样若干 .dat 文件推算异或 key，按图片格式的权重投票，写入 ImageXorKey
+func (a *Account) GetImageXorKeyV3() (byte, error) {
+	if a.ImageXorKey != "" {
+		return parseXorKey(a.ImageXorKey)
+	}
+	files, err := collectDatFiles(a.imageDirV3())
+	if err != nil {
+		return 0, err
+	}
+	votes := make(map[byte]int)
+	sampled := 0
+	for _, path := range files {
+		if sampled >= xorKeySampleSize {
+			break
+		}
+		head, err := readHead(path, 16)
+		if err != nil {
+			continue
+		}
+		if key, ext, ok := DetectXorKey(head); ok {
+			votes[key] += imageFormatWeight(ext)
+			sampled++
+		}
+	}
+	if len(votes) == 0 {
+		return 0, errors.New("can't detect image xor key")
+	}
+	best := bestXorKey(votes)
+	a.ImageXorKey = formatXorKey(best)
+	logrus.Infof("get image xor key:%s (%d votes, %d samples)", a.ImageXorKey, votes[best], sampled)
+	return best, nil
+}
+
+func readHead(path string, n int) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	buf := make([]byte, n)
+	m, err := f.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:m], nil
+}
+
+// DecodeImagesV3 把 FileStorage/Image 下的 .dat 全部解码到 outDir，保留月份目录，
+// 并在 outDir 下写入 index.json 记录每个 .dat 对应的图片
+func (a *Account) DecodeImagesV3(outDir string) ([]ImageIndexEntry, error) {
+	key, err := a.GetImageXorKeyV3()
+	if err != nil {
+		return nil, err
+	}
+	root := a.imageDirV3()
+	files, err := collectDatFiles(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var index []ImageIndexEntry
+	ok, fail := 0, 0
+	for _, path := range files {
+		rel, _ := filepath.Rel(root, path)
+		entry := ImageIndexEntry{Src: filepath.ToSlash(rel)}
+		dst, ext, err := decodeDatFile(path, filepath.Join(outDir, rel), func(data []byte) ([]byte, string, error) {
+			return DecodeDatV3(data, key)
+		})
+		if err != nil {
+			fail++
+			entry.Error = err.Error()
+		} else {
+			ok++
+			dstRel, _ := filepath.Rel(outDir, dst)
+			entry.Dst = filepath.ToSlash(dstRel)
+			entry.Format = ext
+		}
+		index = append(index, entry)
+	}
+
+	if err := writeImageIndex(outDir, index); err != nil {
+		return index, err
+	}
+	logrus.Infof("[IMAGE] 完成: ok=%d fail=%d 输出目录=%s", ok, fail, outDir)
+	return index, nil
+}
+
+// decodeDatFile 读取 .dat 解码，写到 dstDat 去掉 .dat 再加上真实扩展名的位置
+func decodeDatFile(src, dstDat string, decode func([]byte) ([]byte, string, error)) (string, string, error) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return "", "", err
+	}
+	img, ext, err := decode(data)
+	if err != nil {
+		return "", "", err
+	}
+	dst := strings.TrimSuffix(dstDat, filepath.Ext(dstDat)) + "." + ext
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(dst, img, 0644); err != nil {
+		return "", "", err
+	}
+	return dst, ext, nil
+}
+
+func writeImageIndex(outDir string, index []ImageIndexEntry) error {
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(filepath.Join(outDir, ImageIndexFile))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(index)
+}
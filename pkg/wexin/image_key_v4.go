@@ -0,0 +1,174 @@
+package wexin
+
+import (
+	"bytes"
+	"crypto/aes"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 图片 AES key 在内存中是 32 个字符的字符串，取前 16 个字节作为 key，
+// 候选串太长的基本是其他数据，直接跳过
+const (
+	imageKeyMinRun  = aes.BlockSize
+	imageKeyMaxRun  = 64
+	datV4MaxAesPart = 1 << 20 // 正常的 V2 .dat 只加密前 1KB
+)
+
+// 校验 key 时只认文件头足够长的格式，BMP、TIFF 这类 2~4 字节的文件头随机命中的概率太高
+var imageAesKeyMagics = [][]byte{
+	{0xFF, 0xD8, 0xFF},
+	{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A},
+	[]byte("GIF87a"),
+	[]byte("GIF89a"),
+	[]byte("wxgf"),
+}
+
+// printableRuns 返回 b 中所有长度不小于 minLen 的可打印 ASCII 串的 [start, end)
+func printableRuns(b []byte, minLen int) [][2]int {
+	var runs [][2]int
+	start := -1
+	for i := 0; i <= len(b); i++ {
+		if i < len(b) && b[i] >= 0x20 && b[i] <= 0x7e {
+			if start == -1 {
+				start = i
+			}
+			continue
+		}
+		if start != -1 && i-start >= minLen {
+			runs = append(runs, [2]int{start, i})
+		}
+		start = -1
+	}
+	return runs
+}
+
+// ImageAesKeyCandidates 从一段内存（或内存 dump 文件）中提取候选 AES key：
+// 可打印 ASCII 串的前 16 个字节，seen 用于跨多段数据去重
+func ImageAesKeyCandidates(data []byte, seen map[string]struct{}) [][]byte {
+	var candidates [][]byte
+	for _, run := range printableRuns(data, imageKeyMinRun) {
+		if run[1]-run[0] > imageKeyMaxRun {
+			continue
+		}
+		key := string(data[run[0] : run[0]+aes.BlockSize])
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		candidates = append(candidates, []byte(key))
+	}
+	return candidates
+}
+
+// ImageAesKeyCandidatesFromFile 从文件（内存 dump、字符串列表等）中提取候选 key
+func ImageAesKeyCandidatesFromFile(path string) ([][]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ImageAesKeyCandidates(data, make(map[string]struct{})), nil
+}
+
+// VerifyImageAesKey 用 V2 格式 .dat 的 AES 部分校验 key：第一个块解密后应该是 JPEG、PNG、GIF 或 wxgf 文件头，
+// 最后一个块的 PKCS7 填充应该和文件头里的明文长度对得上。sample 需要包含完整的 AES 部分
+func VerifyImageAesKey(sample, key []byte) bool {
+	if len(key) != aes.BlockSize {
+		return false
+	}
+	h, err := ParseDatV4Header(sample)
+	if err != nil || h.Version != 2 {
+		return false
+	}
+	aesPart, _, _ := splitDatV4(sample, h)
+	if len(aesPart) != h.aesPartLen() {
+		return false
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return false
+	}
+	buf := make([]byte, aes.BlockSize)
+	block.Decrypt(buf, aesPart[:aes.BlockSize])
+	if !slices.ContainsFunc(imageAesKeyMagics, func(magic []byte) bool { return bytes.HasPrefix(buf, magic) }) {
+		return false
+	}
+	block.Decrypt(buf, aesPart[len(aesPart)-aes.BlockSize:])
+	pad := h.aesPartLen() - int(h.AesSize)
+	for _, b := range buf[aes.BlockSize-pad:] {
+		if int(b) != pad {
+			return false
+		}
+	}
+	return true
+}
+
+// FindImageAesKey 逐个校验候选 key，返回第一个能解开样本的 key
+func FindImageAesKey(sample []byte, candidates [][]byte) ([]byte, bool) {
+	for _, key := range candidates {
+		if VerifyImageAesKey(sample, key) {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// readDatV4Sample 读取 .dat 的文件头和完整的 AES 部分
+func readDatV4Sample(path string) ([]byte, *DatV4Header, error) {
+	head, err := readHead(path, datV4HeaderSz)
+	if err != nil {
+		return nil, nil, err
+	}
+	h, err := ParseDatV4Header(head)
+	if err != nil {
+		return nil, nil, err
+	}
+	if h.aesPartLen() > datV4MaxAesPart {
+		return nil, nil, fmt.Errorf("aes part too large: %d", h.AesSize)
+	}
+	sample, err := readHead(path, datV4HeaderSz+h.aesPartLen())
+	if err != nil {
+		return nil, nil, err
+	}
+	return sample, h, nil
+}
+
+// findSampleDatV4 挑一个 V2 格式的 .dat 作为样本
+func (a *Account) findSampleDatV4() ([]byte, error) {
+	files, err := collectImageDatsV4(a.imageDirV4())
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range files {
+		if sample, h, err := readDatV4Sample(path); err == nil && h.Version == 2 {
+			return sample, nil
+		}
+	}
+	return nil, errors.New("no v2 dat sample found")
+}
+
+// BruteForceImageAesKeyV4 离线校验候选 key，找到后写入 ImageAesKey
+// samplePath 为空时从账号目录中挑一个样本
+func (a *Account) BruteForceImageAesKeyV4(samplePath string, candidates [][]byte) error {
+	var sample []byte
+	var err error
+	if samplePath != "" {
+		sample, _, err = readDatV4Sample(samplePath)
+	} else {
+		sample, err = a.findSampleDatV4()
+	}
+	if err != nil {
+		return err
+	}
+	key, ok := FindImageAesKey(sample, candidates)
+	if !ok {
+		return fmt.Errorf("none of %d candidates matches", len(candidates))
+	}
+	a.ImageAesKey = string(key)
+	logrus.Infof("get image aes key:%s", a.ImageAesKey)
+	return nil
+}
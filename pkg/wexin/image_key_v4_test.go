@@ -0,0 +1,39 @@
+package wexin
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestVerifyImageAesKey(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	dat := encDatV4(datV4SigV2, testJPEG(2000), key, 1024, 0, 0)
+	if !VerifyImageAesKey(dat, key) {
+		t.Error("right key rejected")
+	}
+	if VerifyImageAesKey(dat[:datV4HeaderSz+16], key) {
+		t.Error("truncated sample accepted")
+	}
+	if VerifyImageAesKey(dat, []byte("0123456789abcdeX")) {
+		t.Error("wrong key accepted")
+	}
+	if VerifyImageAesKey(encDatV4(datV4SigV1, testJPEG(2000), datV4KeyV1, 1024, 0, 0), datV4KeyV1) {
+		t.Error("v1 sample accepted")
+	}
+	bmp := make([]byte, 2000)
+	copy(bmp, "BM")
+	if VerifyImageAesKey(encDatV4(datV4SigV2, bmp, key, 1024, 0, 0), key) {
+		t.Error("bmp header accepted")
+	}
+	// 填充和文件头里的明文长度对不上
+	bad := encDatV4(datV4SigV2, testJPEG(2000), key, 1020, 0, 0)
+	binary.LittleEndian.PutUint32(bad[6:], 1019)
+	if VerifyImageAesKey(bad, key) {
+		t.Error("bad padding accepted")
+	}
+
+	candidates := [][]byte{[]byte("short"), []byte("fedcba9876543210"), key}
+	if got, ok := FindImageAesKey(dat, candidates); !ok || string(got) != string(key) {
+		t.Errorf("FindImageAesKey = %q, %v", got, ok)
+	}
+}
@@ -0,0 +1,50 @@
+package wexin
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDetectXorKey(t *testing.T) {
+	png := []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A, 1, 2, 3}
+	key, ext, ok := DetectXorKey(xorBytes(png, 0x5A))
+	if !ok || key != 0x5A || ext != "png" {
+		t.Errorf("png: key = 0x%02X, ext = %s, ok = %v", key, ext, ok)
+	}
+	if _, _, ok := DetectXorKey([]byte{0x01}); ok {
+		t.Error("1 byte: want no key")
+	}
+
+	// 多数文件是 BMP 头也比不过 JPEG 的票数
+	votes := map[byte]int{}
+	jpg := xorBytes([]byte{0xFF, 0xD8, 0xFF, 0xE0}, 0x33)
+	bmp := xorBytes([]byte("BM\x00\x00"), 0x10)
+	for _, d := range [][]byte{jpg, bmp, bmp} {
+		k, ext, _ := DetectXorKey(d)
+		votes[k] += imageFormatWeight(ext)
+	}
+	if k := bestXorKey(votes); k != 0x33 {
+		t.Errorf("best key = 0x%02X, votes = %v", k, votes)
+	}
+	// 票数相同时取较小的 key，结果稳定
+	if k := bestXorKey(map[byte]int{9: 2, 3: 2}); k != 3 {
+		t.Errorf("tie: best key = %d", k)
+	}
+}
+
+func TestDecodeDatV3(t *testing.T) {
+	jpg := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0, 0x10, 'J', 'F', 'I', 'F'}
+	// key 对不上的文件重新推算
+	for _, key := range []byte{0x21, 0x00} {
+		out, ext, err := DecodeDatV3(xorBytes(jpg, 0x21), key)
+		if err != nil || ext != "jpg" || !bytes.Equal(out, jpg) {
+			t.Errorf("key 0x%02X: %x, %s, %v", key, out, ext, err)
+		}
+	}
+	if _, _, err := DecodeDatV3([]byte("not an image"), 0x21); err == nil {
+		t.Error("unknown format: want error")
+	}
+	if k, err := parseXorKey(formatXorKey(0xAB)); err != nil || k != 0xAB {
+		t.Errorf("parseXorKey = 0x%02X, %v", k, err)
+	}
+}
@@ -0,0 +1,292 @@
+package wexin
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// v4 .dat 图片结构：
+// 文件头 15 字节：签名(6) + AES 加密长度(4, LE) + XOR 加密长度(4, LE) + 填充(1)
+// 之后依次是 AES-128-ECB 加密的头部（PKCS7 填充）、未加密的中间部分、单字节异或的尾部
+var (
+	datV4SigV1 = []byte{0x07, 0x08, 0x56, 0x31, 0x08, 0x07} // \x07\x08V1\x08\x07
+	datV4SigV2 = []byte{0x07, 0x08, 0x56, 0x32, 0x08, 0x07} // \x07\x08V2\x08\x07
+	// V1 使用固定的 AES key：md5("0") 的前 16 个字符
+	datV4KeyV1 = []byte("cfcd208495d565ef")
+)
+
+const datV4HeaderSz = 15
+
+// DatV4Header v4 .dat 文件头
+type DatV4Header struct {
+	Version int    // 1 或 2
+	AesSize uint32 // AES 加密前的明文长度
+	XorSize uint32 // 尾部异或部分长度
+}
+
+// ParseDatV4Header 解析 v4 .dat 文件头
+func ParseDatV4Header(data []byte) (*DatV4Header, error) {
+	if len(data) < datV4HeaderSz {
+		return nil, errors.New("dat too short")
+	}
+	h := &DatV4Header{
+		AesSize: binary.LittleEndian.Uint32(data[6:10]),
+		XorSize: binary.LittleEndian.Uint32(data[10:14]),
+	}
+	switch {
+	case bytes.Equal(data[:6], datV4SigV1):
+		h.Version = 1
+	case bytes.Equal(data[:6], datV4SigV2):
+		h.Version = 2
+	default:
+		return nil, errors.New("not v4 dat")
+	}
+	return h, nil
+}
+
+// IsDatV4 判断是否为 v4 加密格式的 .dat
+func IsDatV4(data []byte) bool {
+	_, err := ParseDatV4Header(data)
+	return err == nil
+}
+
+// aesPartLen AES 部分在文件中的长度：明文长度按 16 字节补齐，刚好整除时也会多一个填充块
+func (h *DatV4Header) aesPartLen() int {
+	return int(h.AesSize/aes.BlockSize+1) * aes.BlockSize
+}
+
+// splitDatV4 把 .dat 数据拆成 AES 部分、中间明文部分和 XOR 部分
+func splitDatV4(data []byte, h *DatV4Header) (aesPart, rawPart, xorPart []byte) {
+	body := data[datV4HeaderSz:]
+	aesLen := min(h.aesPartLen(), len(body))
+	aesLen -= aesLen % aes.BlockSize
+	aesPart = body[:aesLen]
+	rest := body[aesLen:]
+	xorLen := int(h.XorSize)
+	if xorLen > len(rest) {
+		xorLen = len(rest)
+	}
+	return aesPart, rest[:len(rest)-xorLen], rest[len(rest)-xorLen:]
+}
+
+// decryptAESECB 解密 AES-128-ECB，并去掉 PKCS7 填充
+func decryptAESECB(data, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("aes data not multiple of block size")
+	}
+	out := make([]byte, len(data))
+	for i := 0; i < len(data); i += aes.BlockSize {
+		block.Decrypt(out[i:i+aes.BlockSize], data[i:i+aes.BlockSize])
+	}
+	if len(out) == 0 {
+		return out, nil
+	}
+	pad := int(out[len(out)-1])
+	if pad == 0 || pad > aes.BlockSize || pad > len(out) {
+		return nil, errors.New("invalid pkcs7 padding")
+	}
+	for _, b := range out[len(out)-pad:] {
+		if int(b) != pad {
+			return nil, errors.New("invalid pkcs7 padding")
+		}
+	}
+	return out[:len(out)-pad], nil
+}
+
+// DetectXorKeyV4 根据尾部推算 v4 异或 key：JPEG 以 FF D9 结尾，其他格式无法从尾部判断
+func DetectXorKeyV4(data []byte) (byte, bool) {
+	h, err := ParseDatV4Header(data)
+	if err != nil || h.XorSize < 2 {
+		return 0, false
+	}
+	_, _, xorPart := splitDatV4(data, h)
+	if len(xorPart) < 2 {
+		return 0, false
+	}
+	key := xorPart[len(xorPart)-2] ^ 0xFF
+	if xorPart[len(xorPart)-1]^key != 0xD9 {
+		return 0, false
+	}
+	return key, true
+}
+
+// DecodeDatV4 解码 v4 的 .dat 图片，aesKey 为 V2 格式使用的 16 字节 key（V1 使用固定 key）
+func DecodeDatV4(data, aesKey []byte, xorKey byte) ([]byte, string, error) {
+	h, err := ParseDatV4Header(data)
+	if err != nil {
+		return nil, "", err
+	}
+	key := datV4KeyV1
+	if h.Version == 2 {
+		if len(aesKey) != aes.BlockSize {
+			return nil, "", fmt.Errorf("v2 dat needs 16 bytes aes key, got %d", len(aesKey))
+		}
+		key = aesKey
+	}
+	aesPart, rawPart, xorPart := splitDatV4(data, h)
+	head, err := decryptAESECB(aesPart, key)
+	if err != nil {
+		return nil, "", fmt.Errorf("aes decrypt failed: %v", err)
+	}
+	out := make([]byte, 0, len(head)+len(rawPart)+len(xorPart))
+	out = append(out, head...)
+	out = append(out, rawPart...)
+	out = append(out, xorBytes(xorPart, xorKey)...)
+
+	ext, ok := detectImageExt(out)
+	if !ok {
+		// 微信自有的 wxgf 格式（HEVC），原样保留
+		if bytes.HasPrefix(out, []byte("wxgf")) {
+			return out, "wxgf", nil
+		}
+		return nil, "", errors.New("unknown image format, wrong aes key?")
+	}
+	return out, ext, nil
+}
+
+// detectImageExt 判断明文数据的图片格式
+func detectImageExt(data []byte) (string, bool) {
+	return matchImageFormat(data, 0)
+}
+
+// v4 图片目录：msg/attach/<md5(talker)>/2024-01/Img/xxx.dat
+func (a *Account) imageDirV4() string {
+	return filepath.Join(a.DataDir, "msg", "attach")
+}
+
+// collectImageDatsV4 收集 msg/attach/**/Img/ 下的 .dat
+func collectImageDatsV4(root string) ([]string, error) {
+	files, err := collectDatFiles(root)
+	if err != nil {
+		return nil, err
+	}
+	var imgs []string
+	for _, path := range files {
+		if filepath.Base(filepath.Dir(path)) == "Img" {
+			imgs = append(imgs, path)
+		}
+	}
+	return imgs, nil
+}
+
+// GetImageXorKeyV4 从缩略图（_t.dat，基本都是 JPEG）的尾部推算异或 key，写入 ImageXorKey
+func (a *Account) GetImageXorKeyV4() (byte, error) {
+	if a.ImageXorKey != "" {
+		return parseXorKey(a.ImageXorKey)
+	}
+	files, err := collectImageDatsV4(a.imageDirV4())
+	if err != nil {
+		return 0, err
+	}
+	// 缩略图优先
+	votes := make(map[byte]int)
+	sampled := 0
+	for _, thumbFirst := range []bool{true, false} {
+		for _, path := range files {
+			if sampled >= xorKeySampleSize {
+				break
+			}
+			if isThumbDat(path) != thumbFirst {
+				continue
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			if key, ok := DetectXorKeyV4(data); ok {
+				votes[key]++
+				sampled++
+			}
+		}
+	}
+	if len(votes) == 0 {
+		return 0, errors.New("can't detect v4 image xor key")
+	}
+	best := bestXorKey(votes)
+	a.ImageXorKey = formatXorKey(best)
+	logrus.Infof("get v4 image xor key:%s (%d/%d samples)", a.ImageXorKey, votes[best], sampled)
+	return best, nil
+}
+
+// 缩略图 xxx_t.dat，高清图 xxx_h.dat，原图 xxx.dat
+func isThumbDat(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), "_t.dat")
+}
+
+func isHDDat(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), "_h.dat")
+}
+
+// DatV4Variant 返回 .dat 的类型：thumb、hd 或 origin
+func DatV4Variant(path string) string {
+	switch {
+	case isThumbDat(path):
+		return "thumb"
+	case isHDDat(path):
+		return "hd"
+	default:
+		return "origin"
+	}
+}
+
+// DecodeImagesV4 把 msg/attach 下的图片 .dat 全部解码到 outDir，保留目录结构（含缩略图和高清图），
+// aesKey 为空时使用 ImageAesKey，并在 outDir 下写入 index.json
+func (a *Account) DecodeImagesV4(outDir string, aesKey string) ([]ImageIndexEntry, error) {
+	if aesKey == "" {
+		aesKey = a.ImageAesKey
+	}
+	if aesKey != "" && len(aesKey) != aes.BlockSize {
+		return nil, fmt.Errorf("aes key must be 16 bytes, got %d", len(aesKey))
+	}
+	xorKey, err := a.GetImageXorKeyV4()
+	if err != nil {
+		return nil, err
+	}
+	root := a.imageDirV4()
+	files, err := collectImageDatsV4(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var index []ImageIndexEntry
+	ok, fail := 0, 0
+	for _, path := range files {
+		rel, _ := filepath.Rel(root, path)
+		entry := ImageIndexEntry{Src: filepath.ToSlash(rel), Variant: DatV4Variant(path)}
+		dst, ext, err := decodeDatFile(path, filepath.Join(outDir, rel), func(data []byte) ([]byte, string, error) {
+			if !IsDatV4(data) {
+				// 旧版本迁移过来的图片还是 v3 的异或格式
+				return DecodeDatV3(data, xorKey)
+			}
+			return DecodeDatV4(data, []byte(aesKey), xorKey)
+		})
+		if err != nil {
+			fail++
+			entry.Error = err.Error()
+		} else {
+			ok++
+			dstRel, _ := filepath.Rel(outDir, dst)
+			entry.Dst = filepath.ToSlash(dstRel)
+			entry.Format = ext
+		}
+		index = append(index, entry)
+	}
+
+	if err := writeImageIndex(outDir, index); err != nil {
+		return index, err
+	}
+	logrus.Infof("[IMAGE] 完成: ok=%d fail=%d 输出目录=%s", ok, fail, outDir)
+	return index, nil
+}
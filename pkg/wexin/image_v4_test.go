@@ -0,0 +1,81 @@
+package wexin
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"testing"
+)
+
+// encDatV4 按 v4 .dat 格式加密 plain：前 aesSize 字节 AES-ECB，最后 xorSize 字节异或 xorKey
+func encDatV4(sig, plain, key []byte, aesSize, xorSize int, xorKey byte) []byte {
+	head := bytes.Clone(plain[:aesSize])
+	pad := aes.BlockSize - len(head)%aes.BlockSize
+	head = append(head, bytes.Repeat([]byte{byte(pad)}, pad)...)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	for i := 0; i < len(head); i += aes.BlockSize {
+		block.Encrypt(head[i:i+aes.BlockSize], head[i:i+aes.BlockSize])
+	}
+	out := bytes.Clone(sig)
+	out = binary.LittleEndian.AppendUint32(out, uint32(aesSize))
+	out = binary.LittleEndian.AppendUint32(out, uint32(xorSize))
+	out = append(out, 0)
+	out = append(out, head...)
+	out = append(out, plain[aesSize:len(plain)-xorSize]...)
+	return append(out, xorBytes(plain[len(plain)-xorSize:], xorKey)...)
+}
+
+// testJPEG 以 JPEG 文件头开始、FF D9 结束的数据
+func testJPEG(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	copy(data, []byte{0xFF, 0xD8, 0xFF, 0xE0})
+	copy(data[size-2:], []byte{0xFF, 0xD9})
+	return data
+}
+
+func TestDecodeDatV4(t *testing.T) {
+	plain := testJPEG(3000)
+	key := []byte("0123456789abcdef")
+	for _, c := range []struct {
+		name string
+		dat  []byte
+		key  []byte
+	}{
+		{"v1", encDatV4(datV4SigV1, plain, datV4KeyV1, 1024, 100, 0x37), nil},
+		{"v2", encDatV4(datV4SigV2, plain, key, 1024, 100, 0x37), key},
+		{"no xor", encDatV4(datV4SigV2, plain, key, 1000, 0, 0), key},
+	} {
+		h, err := ParseDatV4Header(c.dat)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		xorKey := byte(0)
+		if h.XorSize > 0 {
+			var ok bool
+			if xorKey, ok = DetectXorKeyV4(c.dat); !ok || xorKey != 0x37 {
+				t.Errorf("%s: xor key = 0x%02X, %v", c.name, xorKey, ok)
+			}
+		}
+		out, ext, err := DecodeDatV4(c.dat, c.key, xorKey)
+		if err != nil || ext != "jpg" || !bytes.Equal(out, plain) {
+			t.Errorf("%s: %d bytes, %s, %v", c.name, len(out), ext, err)
+		}
+	}
+
+	v2 := encDatV4(datV4SigV2, plain, key, 1024, 100, 0x37)
+	if _, _, err := DecodeDatV4(v2, []byte("short"), 0x37); err == nil {
+		t.Error("short key: want error")
+	}
+	if _, _, err := DecodeDatV4(v2, []byte("fedcba9876543210"), 0x37); err == nil {
+		t.Error("wrong key: want error")
+	}
+	if IsDatV4(plain) {
+		t.Error("plain jpeg is not v4 dat")
+	}
+}
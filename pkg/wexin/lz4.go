@@ -0,0 +1,40 @@
+package wexin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/pierrec/lz4/v4"
+)
+
+const (
+	lz4MinBound    = 4096
+	lz4MaxBound    = 4 << 20 // 消息 XML 不会超过 4MB
+	lz4MaxRatio    = 255     // LZ4 块的最大压缩比
+	lz4MaxAttempts = 4
+)
+
+// DecompressLZ4Block 解压没有长度头的 LZ4 块（v3 的 CompressContent），去掉结尾的 \x00。
+// 输出缓冲区从 8 倍开始每次扩大 4 倍，最多尝试 lz4MaxAttempts 次，最后一次直接用上限
+func DecompressLZ4Block(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	limit := min(max(len(data)*lz4MaxRatio, lz4MinBound), lz4MaxBound)
+	size := max(len(data)*8, lz4MinBound)
+	for i := 1; ; i++ {
+		if size = min(size, limit); i == lz4MaxAttempts {
+			size = limit
+		}
+		buf := make([]byte, size)
+		n, err := lz4.UncompressBlock(data, buf)
+		if err == nil {
+			return bytes.TrimRight(buf[:n], "\x00"), nil
+		}
+		if !errors.Is(err, lz4.ErrInvalidSourceShortBuffer) || size == limit {
+			return nil, fmt.Errorf("lz4 decompress failed: %v", err)
+		}
+		size *= 4
+	}
+}
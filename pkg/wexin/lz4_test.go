@@ -0,0 +1,47 @@
+package wexin
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pierrec/lz4/v4"
+)
+
+func lz4Compress(t *testing.T, data []byte) []byte {
+	t.Helper()
+	buf := make([]byte, lz4.CompressBlockBound(len(data)))
+	n, err := lz4.CompressBlock(data, buf, nil)
+	if err != nil || n == 0 {
+		t.Fatalf("compress %d bytes: %d, %v", len(data), n, err)
+	}
+	return buf[:n]
+}
+
+func TestDecompressLZ4Block(t *testing.T) {
+	// 小消息、需要多次扩大缓冲区的高压缩比数据、超过 lz4MaxBound 的数据
+	for _, c := range []struct {
+		src string
+		err bool
+	}{
+		{"<msg><appmsg><title>引用</title></appmsg></msg>", false},
+		{strings.Repeat("<a/>", 200000), false},
+		{strings.Repeat("a", lz4MaxBound+1), true},
+	} {
+		out, err := DecompressLZ4Block(lz4Compress(t, []byte(c.src+"\x00\x00")))
+		if c.err {
+			if err == nil {
+				t.Errorf("%d bytes: want error", len(c.src))
+			}
+			continue
+		}
+		if err != nil || string(out) != c.src {
+			t.Errorf("%d bytes: got %d bytes, %v", len(c.src), len(out), err)
+		}
+	}
+	if _, err := DecompressLZ4Block([]byte{0xff, 0xff, 0xff}); err == nil {
+		t.Error("corrupt block: want error")
+	}
+	if out, err := DecompressLZ4Block(nil); out != nil || err != nil {
+		t.Errorf("empty block = %q, %v", out, err)
+	}
+}
@@ -0,0 +1,185 @@
+package wexin
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/saucer-man/wxdump/pkg/utils"
+)
+
+// MessageMediaPath 返回图片、视频、文件消息在数据目录中的原始文件路径，找不到时返回空串。
+// 图片返回 .dat，需要用 DecodeDat 解码；优先原图，其次高清图、缩略图
+func (a *Account) MessageMediaPath(m *Message) string {
+	if m.MediaPath != "" {
+		return m.MediaPath
+	}
+	var candidates []string
+	if a.Version == 4 {
+		candidates = a.mediaCandidatesV4(m)
+	} else {
+		candidates = a.mediaCandidatesV3(m)
+	}
+	for _, p := range candidates {
+		if p != "" && utils.Exists(p) {
+			return p
+		}
+	}
+	return ""
+}
+
+// v3 BytesExtra 中的路径相对于 WeChat Files 目录，以 wxid 开头。
+// 路径来自消息库，绝对路径必须在 DataDir 下，相对路径清理后必须在 WeChat Files 下，否则忽略
+func (a *Account) mediaCandidatesV3(m *Message) []string {
+	var candidates []string
+	extra, err := ParseBytesExtraV3(m.Extra)
+	if err == nil {
+		filesDir := filepath.Dir(a.DataDir)
+		for _, p := range []string{extra.FilePath, extra.ThumbPath} {
+			if p == "" {
+				continue
+			}
+			p = filepath.FromSlash(strings.ReplaceAll(p, `\`, "/"))
+			root := filesDir
+			switch {
+			case filepath.IsAbs(p):
+				root = a.DataDir
+			case strings.HasPrefix(p, "FileStorage"):
+				p = filepath.Join(a.DataDir, p)
+			default:
+				p = filepath.Join(filesDir, p)
+			}
+			if !withinDir(root, p) {
+				continue
+			}
+			candidates = append(candidates, filepath.Clean(p))
+		}
+	}
+	if m.Type == MsgTypeApp && m.SubType == AppMsgFile {
+		if app, err := m.AppMsg(); err == nil {
+			if f, ok := app.(*FileMsg); ok && isPlainFileName(f.Title) {
+				candidates = append(candidates, filepath.Join(a.DataDir, "FileStorage", "File", m.CreateTime.Format("2006-01"), f.Title))
+			}
+		}
+	}
+	return candidates
+}
+
+// withinDir p 清理后是否在 root 目录下
+func withinDir(root, p string) bool {
+	if root == "" {
+		return false
+	}
+	rel, err := filepath.Rel(root, filepath.Clean(p))
+	return err == nil && filepath.IsLocal(rel)
+}
+
+// v4 媒体目录：
+// 图片 msg/attach/<md5(talker)>/<2024-01>/Img/<md5>[_h|_t].dat
+// 视频 msg/video/<2024-01>/<md5>.mp4
+// 文件 msg/file/<2024-01>/<文件名>
+func (a *Account) mediaCandidatesV4(m *Message) []string {
+	var hashes []string
+	if extra, err := ParsePackedInfoV4(m.Extra); err == nil && extra.ImageMD5 != "" {
+		hashes = append(hashes, extra.ImageMD5)
+	}
+	month := m.CreateTime.Format("2006-01")
+	var candidates []string
+	switch {
+	case m.Type == MsgTypeImage:
+		sum := md5.Sum([]byte(m.Talker))
+		talkerDir := filepath.Join(a.imageDirV4(), hex.EncodeToString(sum[:]))
+		for _, hash := range hashes {
+			for _, suffix := range []string{".dat", "_h.dat", "_t.dat"} {
+				candidates = append(candidates, filepath.Join(talkerDir, month, "Img", hash+suffix))
+				matches, _ := filepath.Glob(filepath.Join(talkerDir, "*", "Img", hash+suffix))
+				candidates = append(candidates, matches...)
+			}
+		}
+	case m.Type == MsgTypeVideo:
+		if md5 := videoMD5(m.XML()); md5 != "" {
+			hashes = append(hashes, md5)
+		}
+		for _, hash := range hashes {
+			candidates = append(candidates, filepath.Join(a.DataDir, "msg", "video", month, hash+".mp4"))
+			matches, _ := filepath.Glob(filepath.Join(a.DataDir, "msg", "video", "*", hash+".mp4"))
+			candidates = append(candidates, matches...)
+		}
+	case m.Type == MsgTypeApp && m.SubType == AppMsgFile:
+		if app, err := m.AppMsg(); err == nil {
+			if f, ok := app.(*FileMsg); ok && isPlainFileName(f.Title) {
+				candidates = append(candidates, filepath.Join(a.DataDir, "msg", "file", month, f.Title))
+			}
+		}
+	}
+	return candidates
+}
+
+// isPlainFileName 文件消息的标题来自消息 XML，只有不含路径的文件名才拿来拼路径
+func isPlainFileName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, `/\`) && filepath.IsLocal(name)
+}
+
+// videoMD5 视频消息 XML 中 <videomsg md5="..."> 的值
+func videoMD5(raw string) string {
+	var x struct {
+		VideoMsg struct {
+			MD5    string `xml:"md5,attr"`
+			RawMD5 string `xml:"rawmd5,attr"`
+		} `xml:"videomsg"`
+	}
+	dec := xml.NewDecoder(strings.NewReader(trimToXML(raw)))
+	dec.Strict = false
+	if dec.Decode(&x) != nil {
+		return ""
+	}
+	if x.VideoMsg.RawMD5 != "" {
+		return strings.ToLower(x.VideoMsg.RawMD5)
+	}
+	return strings.ToLower(x.VideoMsg.MD5)
+}
+
+// DecodeDat 用账号的图片 key 解码 .dat，v4 格式需要 ImageAesKey。
+// ImageXorKey 为空时（先调用 GetImageXorKeyV3/V4 推算）按当前文件推算异或 key
+func (a *Account) DecodeDat(data []byte) ([]byte, string, error) {
+	var xorKey byte
+	if a.ImageXorKey != "" {
+		key, err := parseXorKey(a.ImageXorKey)
+		if err != nil {
+			return nil, "", err
+		}
+		xorKey = key
+	}
+	if IsDatV4(data) {
+		if a.ImageXorKey == "" {
+			key, ok := DetectXorKeyV4(data)
+			if !ok {
+				return nil, "", errors.New("can't detect v4 image xor key")
+			}
+			xorKey = key
+		}
+		return DecodeDatV4(data, []byte(a.ImageAesKey), xorKey)
+	}
+	// DecodeDatV3 在 key 对不上时会自己推算
+	return DecodeDatV3(data, xorKey)
+}
+
+// ReadMessageMedia 读取消息的媒体文件，.dat 图片会被解码，返回数据和扩展名
+func (a *Account) ReadMessageMedia(m *Message) ([]byte, string, error) {
+	path := a.MessageMediaPath(m)
+	if path == "" {
+		return nil, "", errors.New("media file not found")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".dat") {
+		return a.DecodeDat(data)
+	}
+	return data, strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), "."), nil
+}
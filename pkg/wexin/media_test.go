@@ -0,0 +1,35 @@
+package wexin
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMediaCandidatesV3(t *testing.T) {
+	filesDir := t.TempDir()
+	dataDir := filepath.Join(filesDir, "wxid_me")
+	a := &Account{Version: 3, DataDir: dataDir}
+	inData := filepath.Join(dataDir, "FileStorage", "Image", "a.dat")
+	for _, c := range []struct {
+		path string
+		want string
+	}{
+		{`wxid_me\FileStorage\Image\a.dat`, inData},
+		{`FileStorage\Image\a.dat`, inData},
+		{inData, inData},
+		{`wxid_other\FileStorage\Image\a.dat`, filepath.Join(filesDir, "wxid_other", "FileStorage", "Image", "a.dat")},
+		{`wxid_me\..\..\secret.txt`, ""},
+		{`FileStorage\..\..\..\secret.txt`, ""},
+		{filepath.Join(filesDir, "wxid_other", "a.dat"), ""},
+		{filepath.Join(dataDir, "..", "..", "secret.txt"), ""},
+	} {
+		m := &Message{Version: 3, Type: MsgTypeImage, Extra: bytesExtraV3(bytesExtraFilePath, c.path)}
+		got := a.mediaCandidatesV3(m)
+		switch {
+		case c.want == "" && len(got) != 0:
+			t.Errorf("%s: got %v, want rejected", c.path, got)
+		case c.want != "" && (len(got) != 1 || got[0] != c.want):
+			t.Errorf("%s: got %v, want %s", c.path, got, c.want)
+		}
+	}
+}
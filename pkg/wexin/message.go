@@ -0,0 +1,225 @@
+package wexin
+
+import (
+	"container/heap"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 消息类型（v3 的 Type，v4 local_type 的低 32 位）
+const (
+	MsgTypeText     = 1
+	MsgTypeImage    = 3
+	MsgTypeVoice    = 34
+	MsgTypeCard     = 42
+	MsgTypeVideo    = 43
+	MsgTypeEmoji    = 47
+	MsgTypeLocation = 48
+	MsgTypeApp      = 49
+	MsgTypeVoip     = 50
+	MsgTypeSystem   = 10000
+	MsgTypeRevoke   = 10002
+)
+
+// Message v3 和 v4 统一的消息结构，导出时不用关心是哪个版本
+type Message struct {
+	Version         int
+	Talker          string // 会话的 username，群聊为 xxx@chatroom
+	LocalID         int64
+	ServerID        int64
+	Type            int64
+	SubType         int64
+	Sender          string // 发送者 username
+	IsSender        bool   // 是否为自己发送
+	CreateTime      time.Time
+	SortSeq         int64
+	Status          int64
+	Content         string
+	CompressContent []byte // 已解压（v3 LZ4、v4 zstd），解压失败时为空
+	RawCompress     []byte // 数据库中未解压的原始数据
+	Extra           []byte // v3 BytesExtra / v4 packed_info_data
+	Shard           string // 所在的数据库文件
+
+	SenderName string     // 发送者显示名，合并转发的记录中只有显示名
+	MediaPath  string     // 图片、文件等媒体的本地路径
+	Children   []*Message // 合并转发的聊天记录，见 ExpandRecord
+	ReplyTo    *Message   // 引用回复所引用的消息，见 LinkReplies
+	Replies    []*Message // 引用了这条消息的回复
+}
+
+// IsChatRoom 是否为群聊消息
+func (m *Message) IsChatRoom() bool {
+	return strings.HasSuffix(m.Talker, "@chatroom")
+}
+
+// XML 消息的 XML 内容：CompressContent 解压成功且有内容时优先使用（v3 的引用、合并转发等），否则为 Content
+func (m *Message) XML() string {
+	if len(m.CompressContent) > 0 {
+		return string(m.CompressContent)
+	}
+	return m.Content
+}
+
+// MessageStore 解密后的消息库
+type MessageStore interface {
+	// Messages 按时间顺序遍历消息，talker 为空时遍历所有会话
+	Messages(talker string) (*MessageIterator, error)
+	Close() error
+}
+
+// OpenMessageStore 打开解密目录中的消息库，decryptedDir 与 DecryptDBV4 的参数相同
+func (a *Account) OpenMessageStore(decryptedDir string) (MessageStore, error) {
+	dir := decryptedAccountDir(decryptedDir, a.Wxid)
+	switch a.Version {
+	case 3:
+		return OpenMessageStoreV3(dir, a.Wxid)
+	case 4:
+		return OpenMessageStoreV4(dir, a.Wxid)
+	}
+	return nil, fmt.Errorf("message store for v%d is not supported", a.Version)
+}
+
+// messageCursor 一个按时间排好序的结果集
+type messageCursor struct {
+	rows *sql.Rows
+	scan func(rows *sql.Rows) (*Message, error)
+	head *Message
+	seq  int // 相同时间时按打开顺序（分片顺序）排序
+}
+
+func (c *messageCursor) advance() error {
+	c.head = nil
+	if !c.rows.Next() {
+		return c.rows.Err()
+	}
+	m, err := c.scan(c.rows)
+	if err != nil {
+		return err
+	}
+	c.head = m
+	return nil
+}
+
+type cursorHeap []*messageCursor
+
+func (h cursorHeap) Len() int { return len(h) }
+func (h cursorHeap) Less(i, j int) bool {
+	a, b := h[i].head, h[j].head
+	if !a.CreateTime.Equal(b.CreateTime) {
+		return a.CreateTime.Before(b.CreateTime)
+	}
+	if a.SortSeq != b.SortSeq {
+		return a.SortSeq < b.SortSeq
+	}
+	return h[i].seq < h[j].seq
+}
+func (h cursorHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *cursorHeap) Push(x any)   { *h = append(*h, x.(*messageCursor)) }
+func (h *cursorHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// MessageIterator 把多个分片、多张表的有序结果集按时间归并，用法同 sql.Rows：
+//
+//	it, err := store.Messages("")
+//	defer it.Close()
+//	for it.Next() {
+//		m := it.Message()
+//	}
+//	err = it.Err()
+type MessageIterator struct {
+	cursors []*messageCursor
+	h       cursorHeap
+	cur     *Message
+	err     error
+	started bool
+	onClose func() error
+}
+
+func newMessageIterator(onClose func() error) *MessageIterator {
+	return &MessageIterator{onClose: onClose}
+}
+
+// add 添加一个有序结果集，scan 把一行转换成 Message
+func (it *MessageIterator) add(rows *sql.Rows, scan func(rows *sql.Rows) (*Message, error)) {
+	it.cursors = append(it.cursors, &messageCursor{rows: rows, scan: scan, seq: len(it.cursors)})
+}
+
+func (it *MessageIterator) start() {
+	it.started = true
+	for _, c := range it.cursors {
+		if err := c.advance(); err != nil {
+			it.err = err
+			return
+		}
+		if c.head != nil {
+			it.h = append(it.h, c)
+		}
+	}
+	heap.Init(&it.h)
+}
+
+// Next 移动到下一条消息，没有更多消息或出错时返回 false
+func (it *MessageIterator) Next() bool {
+	if !it.started {
+		it.start()
+	}
+	if it.err != nil || len(it.h) == 0 {
+		it.cur = nil
+		return false
+	}
+	c := it.h[0]
+	it.cur = c.head
+	if err := c.advance(); err != nil {
+		it.err = err
+		return true
+	}
+	if c.head == nil {
+		heap.Pop(&it.h)
+	} else {
+		heap.Fix(&it.h, 0)
+	}
+	return true
+}
+
+// Message 当前消息
+func (it *MessageIterator) Message() *Message {
+	return it.cur
+}
+
+// Err 遍历过程中的错误
+func (it *MessageIterator) Err() error {
+	return it.err
+}
+
+// Close 关闭所有结果集
+func (it *MessageIterator) Close() error {
+	for _, c := range it.cursors {
+		c.rows.Close()
+	}
+	it.cursors = nil
+	it.h = nil
+	if it.onClose != nil {
+		return it.onClose()
+	}
+	return nil
+}
+
+// CollectMessages 遍历并收集所有消息
+func CollectMessages(store MessageStore, talker string) ([]*Message, error) {
+	it, err := store.Messages(talker)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	var msgs []*Message
+	for it.Next() {
+		msgs = append(msgs, it.Message())
+	}
+	return msgs, it.Err()
+}
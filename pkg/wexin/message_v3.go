@@ -0,0 +1,137 @@
+package wexin
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var v3MessageDBRe = regexp.MustCompile(`^MSG\d+\.db$`)
+
+// MessageStoreV3 v3 消息库：所有会话都在 MSG 表中，按时间分散在 Msg/Multi/MSG0.db ... MSGn.db
+type MessageStoreV3 struct {
+	dir    string
+	self   string
+	shards []*messageShardV3
+}
+
+type messageShardV3 struct {
+	path string
+	db   *sql.DB
+}
+
+// OpenMessageStoreV3 打开 v3 解密后的账号目录（包含 Msg/Multi/MSG*.db）
+func OpenMessageStoreV3(dir, self string) (*MessageStoreV3, error) {
+	var paths []string
+	for _, pattern := range []string{"Msg/Multi/MSG*.db", "Multi/MSG*.db"} {
+		matches, _ := filepath.Glob(filepath.Join(dir, filepath.FromSlash(pattern)))
+		paths = append(paths, matches...)
+	}
+	s := &MessageStoreV3{dir: dir, self: self}
+	for _, path := range paths {
+		if !v3MessageDBRe.MatchString(filepath.Base(path)) {
+			continue
+		}
+		db, err := openSQLite(path)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("open %s failed: %v", path, err)
+		}
+		s.shards = append(s.shards, &messageShardV3{path: path, db: db})
+	}
+	if len(s.shards) == 0 {
+		return nil, fmt.Errorf("MSG*.db not found in %s", dir)
+	}
+	sortShards(s.shards, func(sh *messageShardV3) string { return sh.path })
+	return s, nil
+}
+
+const messageQueryV3 = `SELECT localId, MsgSvrID, Type, SubType, IsSender, CreateTime, Sequence, Status,
+	StrTalker, StrContent, CompressContent, BytesExtra FROM MSG`
+
+// Messages 按时间顺序归并所有分片，talker 为空时遍历所有会话
+func (s *MessageStoreV3) Messages(talker string) (*MessageIterator, error) {
+	it := newMessageIterator(nil)
+	for _, shard := range s.shards {
+		var rows *sql.Rows
+		var err error
+		if talker == "" {
+			rows, err = shard.db.Query(messageQueryV3 + ` ORDER BY CreateTime, Sequence`)
+		} else {
+			rows, err = shard.db.Query(messageQueryV3+` WHERE StrTalker = ? ORDER BY CreateTime, Sequence`, talker)
+		}
+		if err != nil {
+			it.Close()
+			return nil, fmt.Errorf("query %s failed: %v", shard.path, err)
+		}
+		it.add(rows, s.scanner(shard))
+	}
+	return it, nil
+}
+
+func (s *MessageStoreV3) scanner(shard *messageShardV3) func(rows *sql.Rows) (*Message, error) {
+	shardName := filepath.Base(shard.path)
+	return func(rows *sql.Rows) (*Message, error) {
+		var (
+			localID, svrID, msgType, subType, isSender, createTime, sequence, status sql.NullInt64
+			talker, content                                                          sql.NullString
+			compressContent, bytesExtra                                              []byte
+		)
+		err := rows.Scan(&localID, &svrID, &msgType, &subType, &isSender, &createTime, &sequence, &status,
+			&talker, &content, &compressContent, &bytesExtra)
+		if err != nil {
+			return nil, err
+		}
+		m := &Message{
+			Version:     3,
+			Talker:      talker.String,
+			LocalID:     localID.Int64,
+			ServerID:    svrID.Int64,
+			Type:        msgType.Int64,
+			SubType:     subType.Int64,
+			IsSender:    isSender.Int64 == 1,
+			CreateTime:  time.Unix(createTime.Int64, 0),
+			SortSeq:     sequence.Int64,
+			Status:      status.Int64,
+			Content:     content.String,
+			RawCompress: compressContent,
+			Extra:       bytesExtra,
+			Shard:       shardName,
+		}
+		// 引用、合并转发等消息的 XML 在 CompressContent 中，是 LZ4 压缩的
+		if len(compressContent) > 0 {
+			if data, err := DecompressLZ4Block(compressContent); err != nil {
+				logrus.Debugf("%s localId=%d: %v", shardName, m.LocalID, err)
+			} else {
+				m.CompressContent = data
+			}
+		}
+		// 群聊中别人发的消息，发送者在 BytesExtra 里
+		switch {
+		case m.IsSender:
+			m.Sender = s.self
+		case !m.IsChatRoom():
+			m.Sender = m.Talker
+		case len(bytesExtra) > 0:
+			if extra, err := ParseBytesExtraV3(bytesExtra); err != nil {
+				logrus.Debugf("%s localId=%d: parse BytesExtra failed: %v", shardName, m.LocalID, err)
+			} else {
+				m.Sender = extra.Sender
+			}
+		}
+		return m, nil
+	}
+}
+
+// Close 关闭所有分片
+func (s *MessageStoreV3) Close() error {
+	for _, shard := range s.shards {
+		shard.db.Close()
+	}
+	s.shards = nil
+	return nil
+}
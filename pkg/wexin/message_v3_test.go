@@ -0,0 +1,47 @@
+package wexin
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMessageStoreV3(t *testing.T) {
+	dir := t.TempDir()
+	multi := filepath.Join(dir, "wxid_me", "Msg", "Multi")
+	schema := `CREATE TABLE MSG(localId INTEGER PRIMARY KEY, TalkerId INT, MsgSvrID INT, Type INT, SubType INT, IsSender INT,
+		CreateTime INT, Sequence INT, Status INT, StrTalker TEXT, StrContent TEXT, CompressContent BLOB, BytesExtra BLOB)`
+	createDB(t, filepath.Join(multi, "MSG0.db"), schema,
+		`INSERT INTO MSG VALUES (1, 0, 11, 1, 0, 1, 100, 100000, 2, 'wxid_a', 'hi', NULL, NULL),
+			(2, 0, 12, 1, 0, 0, 300, 300000, 2, 'g@chatroom', 'yo', NULL, NULL)`)
+	createDB(t, filepath.Join(multi, "MSG1.db"), schema,
+		`INSERT INTO MSG VALUES (1, 0, 13, 1, 0, 0, 200, 200000, 2, 'wxid_a', 'hey', NULL, NULL)`)
+	// MediaMSG*.db 不是消息分片
+	createDB(t, filepath.Join(multi, "MediaMSG0.db"), `CREATE TABLE Media(Key TEXT)`)
+
+	store, err := (&Account{Version: 3, Wxid: "wxid_me"}).OpenMessageStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	msgs, err := CollectMessages(store, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 3 || msgs[0].Content != "hi" || msgs[1].Content != "hey" || msgs[2].Content != "yo" {
+		t.Fatalf("messages = %+v", msgs)
+	}
+	if m := msgs[0]; m.Sender != "wxid_me" || !m.IsSender || m.ServerID != 11 || m.Shard != "MSG0.db" {
+		t.Errorf("self = %+v", m)
+	}
+	// 私聊对方发送的消息，发送者就是会话；群聊的发送者在 BytesExtra 中
+	if msgs[1].Sender != "wxid_a" || msgs[1].Shard != "MSG1.db" || msgs[2].Sender != "" {
+		t.Errorf("senders = %q, %q", msgs[1].Sender, msgs[2].Sender)
+	}
+	if msgs, err = CollectMessages(store, "wxid_a"); err != nil || len(msgs) != 2 {
+		t.Errorf("wxid_a: %d messages, %v", len(msgs), err)
+	}
+
+	if _, err := (&Account{Version: 3, Wxid: "wxid_none"}).OpenMessageStore(dir); err == nil {
+		t.Error("no MSG*.db: want error")
+	}
+}
@@ -0,0 +1,280 @@
+package wexin
+
+import (
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 每条 UNION ALL 查询合并的表数，SQLite 默认最多 500 个复合查询
+const v4TablesPerQuery = 200
+
+var v4MessageDBRe = regexp.MustCompile(`^message_\d+\.db$`)
+
+// MessageStoreV4 v4 消息库：每个会话一张 Msg_<md5(username)> 表，分散在多个 message_N.db 中
+type MessageStoreV4 struct {
+	dir    string
+	self   string
+	shards []*messageShardV4
+	// md5(username) => username
+	talkers map[string]string
+	decoder *ContentDecoder
+}
+
+type messageShardV4 struct {
+	path    string
+	db      *sql.DB
+	tables  []string         // 表名中的 md5
+	name2id map[int64]string // Name2Id.rowid => username
+}
+
+// OpenMessageStoreV4 打开 v4 解密后的账号目录（包含 message/、contact/）
+func OpenMessageStoreV4(dir, self string) (*MessageStoreV4, error) {
+	paths, _ := filepath.Glob(filepath.Join(dir, "message", "message_*.db"))
+	s := &MessageStoreV4{dir: dir, self: self, talkers: make(map[string]string)}
+	var dicts [][]byte
+	for _, path := range paths {
+		if !v4MessageDBRe.MatchString(filepath.Base(path)) {
+			continue
+		}
+		shard, err := openMessageShardV4(path)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("open %s failed: %v", path, err)
+		}
+		s.shards = append(s.shards, shard)
+		for _, username := range shard.name2id {
+			s.addTalker(username)
+		}
+		found, err := loadZstdDicts(shard.db)
+		if err != nil {
+			logrus.Infof("load zstd dicts from %s failed: %v", path, err)
+		}
+		// 各分库的字典可能相同，由 NewContentDecoder 按字典 ID 去重
+		dicts = append(dicts, found...)
+	}
+	if len(s.shards) == 0 {
+		return nil, fmt.Errorf("message_*.db not found in %s", dir)
+	}
+	decoder, err := NewContentDecoder(dicts...)
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf("init zstd decoder failed: %v", err)
+	}
+	s.decoder = decoder
+	sortShards(s.shards, func(sh *messageShardV4) string { return sh.path })
+
+	// Name2Id 里没有的会话，再用 contact.db 中的 username 反查
+	contactDB := filepath.Join(dir, filepath.FromSlash(v4ContactDBRel))
+	err = querySQLite(contactDB, `SELECT username FROM contact`, func(rows *sql.Rows) error {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return err
+		}
+		s.addTalker(username)
+		return nil
+	})
+	if err != nil {
+		logrus.Infof("read %s failed: %v", contactDB, err)
+	}
+
+	// 反查不到的表仍会导出，会话名用表名中的 md5 代替
+	unresolved := 0
+	for _, shard := range s.shards {
+		for _, hash := range shard.tables {
+			if _, ok := s.talkers[hash]; !ok {
+				unresolved++
+				logrus.Debugf("%s: Msg_%s can't be resolved to username", filepath.Base(shard.path), hash)
+			}
+		}
+	}
+	if unresolved > 0 {
+		logrus.Infof("%d message tables can't be resolved to username, using table hash as talker", unresolved)
+	}
+	return s, nil
+}
+
+func openMessageShardV4(path string) (*messageShardV4, error) {
+	db, err := openSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+	shard := &messageShardV4{path: path, db: db, name2id: make(map[int64]string)}
+
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name LIKE 'Msg\_%' ESCAPE '\'`)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			db.Close()
+			return nil, err
+		}
+		shard.tables = append(shard.tables, strings.TrimPrefix(name, "Msg_"))
+	}
+	rows.Close()
+
+	rows, err = db.Query(`SELECT rowid, user_name FROM Name2Id`)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var username string
+		if err := rows.Scan(&id, &username); err != nil {
+			db.Close()
+			return nil, err
+		}
+		shard.name2id[id] = username
+	}
+	return shard, rows.Err()
+}
+
+func (s *MessageStoreV4) addTalker(username string) {
+	if username == "" {
+		return
+	}
+	sum := md5.Sum([]byte(username))
+	s.talkers[hex.EncodeToString(sum[:])] = username
+}
+
+// Talker 根据表名中的 md5 反查 username
+func (s *MessageStoreV4) Talker(hash string) (string, bool) {
+	username, ok := s.talkers[hash]
+	return username, ok
+}
+
+// talker 返回表对应的 username，反查不到时返回表名中的 md5
+func (s *MessageStoreV4) talker(hash string) string {
+	if username, ok := s.talkers[hash]; ok {
+		return username
+	}
+	return hash
+}
+
+// Messages 按时间顺序遍历消息，talker 为空时遍历所有会话；
+// 反查不到 username 的会话可以直接传表名中的 md5
+func (s *MessageStoreV4) Messages(talker string) (*MessageIterator, error) {
+	var wantHash string
+	if talker != "" {
+		sum := md5.Sum([]byte(talker))
+		wantHash = hex.EncodeToString(sum[:])
+	}
+
+	it := newMessageIterator(nil)
+	for _, shard := range s.shards {
+		var tables []string
+		for _, hash := range shard.tables {
+			if wantHash == "" || hash == wantHash || hash == talker {
+				tables = append(tables, hash)
+			}
+		}
+		for i := 0; i < len(tables); i += v4TablesPerQuery {
+			chunk := tables[i:min(i+v4TablesPerQuery, len(tables))]
+			rows, err := shard.db.Query(messageQueryV4(chunk))
+			if err != nil {
+				it.Close()
+				return nil, fmt.Errorf("query %s failed: %v", shard.path, err)
+			}
+			it.add(rows, s.scanner(shard))
+		}
+	}
+	return it, nil
+}
+
+// messageQueryV4 把多张 Msg_ 表 UNION ALL 成一个按时间排序的查询，tbl 列记录来源表
+func messageQueryV4(tables []string) string {
+	parts := make([]string, 0, len(tables))
+	for _, hash := range tables {
+		parts = append(parts, fmt.Sprintf(`SELECT local_id, server_id, local_type, sort_seq, real_sender_id, create_time, status,
+			message_content, compress_content, packed_info_data, '%s' AS tbl FROM "Msg_%s"`, hash, hash))
+	}
+	return strings.Join(parts, " UNION ALL ") + " ORDER BY create_time, sort_seq"
+}
+
+func (s *MessageStoreV4) scanner(shard *messageShardV4) func(rows *sql.Rows) (*Message, error) {
+	shardName := filepath.Base(shard.path)
+	return func(rows *sql.Rows) (*Message, error) {
+		var (
+			localID, serverID, localType, sortSeq, senderID, createTime, status sql.NullInt64
+			content, compressContent, packedInfo                                []byte
+			hash                                                                string
+		)
+		err := rows.Scan(&localID, &serverID, &localType, &sortSeq, &senderID, &createTime, &status,
+			&content, &compressContent, &packedInfo, &hash)
+		if err != nil {
+			return nil, err
+		}
+		m := &Message{
+			Version:     4,
+			Talker:      s.talker(hash),
+			LocalID:     localID.Int64,
+			ServerID:    serverID.Int64,
+			Type:        localType.Int64 & 0xFFFFFFFF,
+			SubType:     localType.Int64 >> 32,
+			Sender:      shard.name2id[senderID.Int64],
+			CreateTime:  time.Unix(createTime.Int64, 0),
+			SortSeq:     sortSeq.Int64,
+			Status:      status.Int64,
+			Content:     string(content),
+			RawCompress: compressContent,
+			Extra:       packedInfo,
+			Shard:       shardName,
+		}
+		// message_content、compress_content 可能是 zstd 压缩的；
+		// message_content 解压失败时保留原始数据，compress_content 解压失败时丢弃
+		if IsZstd(content) {
+			if text, err := s.decoder.DecodeString(content); err != nil {
+				logrus.Debugf("%s local_id=%d: %v", shardName, m.LocalID, err)
+			} else {
+				m.Content = text
+			}
+		}
+		if !IsZstd(compressContent) {
+			m.CompressContent = compressContent
+		} else if data, err := s.decoder.Decompress(compressContent); err != nil {
+			logrus.Debugf("%s local_id=%d: %v", shardName, m.LocalID, err)
+		} else {
+			m.CompressContent = data
+		}
+		m.IsSender = m.Sender != "" && m.Sender == s.self
+		// 群聊消息内容以 "发送者:\n" 开头
+		if m.IsChatRoom() {
+			m.Content = trimSenderPrefix(m.Content, m.Sender)
+		}
+		return m, nil
+	}
+}
+
+// trimSenderPrefix 去掉群聊消息内容开头的 "wxid_xxx:\n"
+func trimSenderPrefix(content, sender string) string {
+	if sender != "" && strings.HasPrefix(content, sender+":\n") {
+		return content[len(sender)+2:]
+	}
+	return content
+}
+
+// Close 关闭所有分片
+func (s *MessageStoreV4) Close() error {
+	for _, shard := range s.shards {
+		shard.db.Close()
+	}
+	s.shards = nil
+	if s.decoder != nil {
+		s.decoder.Close()
+		s.decoder = nil
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package wexin
+
+import (
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// createDB 在 path 创建明文库并执行 stmts
+func createDB(t *testing.T, path string, stmts ...string) *sql.DB {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, s := range stmts {
+		if _, err := db.Exec(s); err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+	}
+	return db
+}
+
+// createMsgTableV4 在 db 中建 talker 的 Msg_<md5> 表并插入 rows，
+// 每行为 server_id, local_type, sort_seq, real_sender_id, create_time, message_content
+func createMsgTableV4(t *testing.T, db *sql.DB, talker string, rows ...[]any) {
+	t.Helper()
+	sum := md5.Sum([]byte(talker))
+	table := "Msg_" + hex.EncodeToString(sum[:])
+	_, err := db.Exec(`CREATE TABLE "` + table + `"(local_id INTEGER PRIMARY KEY AUTOINCREMENT, server_id INTEGER, local_type INTEGER,
+		sort_seq INTEGER, real_sender_id INTEGER, create_time INTEGER, status INTEGER, message_content TEXT, compress_content TEXT,
+		packed_info_data BLOB)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range rows {
+		_, err := db.Exec(`INSERT INTO "`+table+`"(server_id, local_type, sort_seq, real_sender_id, create_time, status, message_content)
+			VALUES (?, ?, ?, ?, ?, 2, ?)`, r...)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMessageStoreV4(t *testing.T) {
+	dir := t.TempDir()
+	name2id := `CREATE TABLE Name2Id(user_name TEXT PRIMARY KEY, is_session INTEGER)`
+	db := createDB(t, filepath.Join(dir, "wxid_me", "message", "message_0.db"), name2id,
+		`INSERT INTO Name2Id(user_name) VALUES ('wxid_me'), ('wxid_a'), ('g@chatroom')`)
+	createMsgTableV4(t, db, "wxid_a", []any{11, 1, 100000, 2, 100, "hi"}, []any{12, 1, 300000, 1, 300, "yo"})
+	createMsgTableV4(t, db, "g@chatroom",
+		[]any{21, int64(AppMsgQuote)<<32 | MsgTypeApp, 200000, 2, 200, "wxid_a:\n<msg/>"},
+		[]any{22, 1, 150001, 2, 150, "wxid_a:\n同一秒内按 sort_seq 排序"})
+	// 第二个分片的 Name2Id 顺序不同
+	db = createDB(t, filepath.Join(dir, "wxid_me", "message", "message_1.db"), name2id,
+		`INSERT INTO Name2Id(user_name) VALUES ('wxid_a'), ('wxid_me')`)
+	createMsgTableV4(t, db, "wxid_a", []any{13, 3, 150000, 1, 150, "<img/>"})
+
+	store, err := (&Account{Version: 4, Wxid: "wxid_me"}).OpenMessageStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	msgs, err := CollectMessages(store, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	for _, m := range msgs {
+		ids = append(ids, m.ServerID)
+	}
+	if fmt.Sprint(ids) != "[11 13 22 21 12]" {
+		t.Fatalf("order = %v", ids)
+	}
+	byID := make(map[int64]*Message)
+	for _, m := range msgs {
+		byID[m.ServerID] = m
+	}
+	if m := byID[11]; m.Talker != "wxid_a" || m.Sender != "wxid_a" || m.IsSender || m.Type != MsgTypeText || m.Content != "hi" {
+		t.Errorf("11 = %+v", m)
+	}
+	if m := byID[12]; m.Sender != "wxid_me" || !m.IsSender {
+		t.Errorf("12 = %+v", m)
+	}
+	if m := byID[13]; m.Sender != "wxid_a" || m.Type != MsgTypeImage {
+		t.Errorf("13 (message_1.db) = %+v", m)
+	}
+	if m := byID[21]; m.Talker != "g@chatroom" || m.Sender != "wxid_a" || m.Type != MsgTypeApp || m.SubType != AppMsgQuote || m.Content != "<msg/>" {
+		t.Errorf("21 = %+v", m)
+	}
+
+	if msgs, err = CollectMessages(store, "wxid_a"); err != nil || len(msgs) != 3 {
+		t.Errorf("wxid_a: %d messages, %v", len(msgs), err)
+	}
+	if msgs, err = CollectMessages(store, "wxid_none"); err != nil || len(msgs) != 0 {
+		t.Errorf("unknown talker: %d messages, %v", len(msgs), err)
+	}
+}
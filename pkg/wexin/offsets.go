@@ -0,0 +1,295 @@
+package wexin
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/saucer-man/wxdump/pkg/utils"
+)
+
+// v3 各版本在 WeChatWin.dll 中的偏移：[昵称, 账号, 手机号, 邮箱, key指针]
+// 默认数据来自 https://raw.githubusercontent.com/xaoyaoo/PyWxDump/refs/heads/master/pywxdump/WX_OFFS.json
+//
+//go:embed offsets_v3.json
+var defaultOffsets []byte
+
+const (
+	OffsetsEnv      = "WXDUMP_OFFSETS" // 环境变量，指定偏移文件路径
+	OffsetsFileName = "offsets_v3.json"
+
+	OffsetSourceEmbedded = "embedded"
+	OffsetSourceEnv      = "env"
+	OffsetSourceUser     = "user"
+)
+
+// Unmarshal the JSON into a map[string][]int
+var OffSetMap map[string][]int
+
+// OffSetSource 记录每个版本的偏移来自哪里（embedded/env/user）
+var OffSetSource map[string]string
+
+var offsetVersionRe = regexp.MustCompile(`^3\.\d+\.\d+\.\d+$`)
+
+func init() {
+	if err := LoadOffSetMap(); err != nil {
+		logrus.Info("LoadOffSetMap error: ", err)
+	}
+}
+
+// UserOffsetsPath 用户偏移文件路径：%AppData%\wxdump\offsets_v3.json
+func UserOffsetsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "wxdump", OffsetsFileName), nil
+}
+
+// LoadOffSetMap 依次加载用户文件、环境变量指定的文件和内置的默认偏移，
+// 同一个版本以先出现的为准（用户文件 > 环境变量 > 内置）
+func LoadOffSetMap() error {
+	offsets, err := parseOffsets(defaultOffsets)
+	if err != nil {
+		return fmt.Errorf("embedded offsets invalid: %v", err)
+	}
+	sources := make(map[string]string, len(offsets))
+	for v := range offsets {
+		sources[v] = OffsetSourceEmbedded
+	}
+
+	var errs []string
+	if envPath := os.Getenv(OffsetsEnv); envPath != "" {
+		if err := mergeOffsetsFile(offsets, sources, envPath, OffsetSourceEnv); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if userPath, err := UserOffsetsPath(); err == nil && utils.Exists(userPath) {
+		if err := mergeOffsetsFile(offsets, sources, userPath, OffsetSourceUser); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	OffSetMap = offsets
+	OffSetSource = sources
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func mergeOffsetsFile(offsets map[string][]int, sources map[string]string, path, source string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read %s failed: %v", path, err)
+	}
+	m, err := parseOffsets(data)
+	if err != nil {
+		return fmt.Errorf("%s invalid: %v", path, err)
+	}
+	for v, offs := range m {
+		offsets[v] = offs
+		sources[v] = source
+	}
+	logrus.Debugf("load %d offsets from %s", len(m), path)
+	return nil
+}
+
+// parseOffsets 解析并校验偏移文件，任何一个版本不合法都会整体报错
+func parseOffsets(data []byte) (map[string][]int, error) {
+	var m map[string][]int
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	var errs []string
+	for v, offs := range m {
+		if err := ValidateOffsets(v, offs); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return nil, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return m, nil
+}
+
+// ValidateOffsets 校验单个版本的偏移：版本号必须是 3.x.x.x，偏移必须是 5 个非负数，
+// 除了邮箱（新版本为 0）以外都不能为 0
+func ValidateOffsets(version string, offsets []int) error {
+	if !offsetVersionRe.MatchString(version) {
+		return fmt.Errorf("%s: invalid version", version)
+	}
+	if len(offsets) != 5 {
+		return fmt.Errorf("%s: want 5 offsets, got %d", version, len(offsets))
+	}
+	for i, off := range offsets {
+		if off < 0 {
+			return fmt.Errorf("%s: offset[%d] is negative", version, i)
+		}
+		if off == 0 && i != 3 {
+			return fmt.Errorf("%s: offset[%d] is zero", version, i)
+		}
+	}
+	return nil
+}
+
+// SupportedVersionsV3 返回所有已收集偏移的版本，按版本号从小到大排序
+func SupportedVersionsV3() []string {
+	versions := make([]string, 0, len(OffSetMap))
+	for v := range OffSetMap {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return utils.CompareVersion(versions[i], versions[j]) < 0
+	})
+	return versions
+}
+
+type OffsetsMergeResult struct {
+	Added   []string
+	Updated []string
+	Skipped []string // 校验不通过的版本及原因
+}
+
+// MergeOffsetsFile 把 PyWxDump 的 WX_OFFS.json（格式相同）合并到用户偏移文件中，
+// 不合法的版本跳过，合并后重新加载 OffSetMap
+func MergeOffsetsFile(src string) (*OffsetsMergeResult, error) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return nil, err
+	}
+	var incoming map[string][]int
+	if err := json.Unmarshal(data, &incoming); err != nil {
+		return nil, fmt.Errorf("%s invalid: %v", src, err)
+	}
+
+	userOffsets, err := readUserOffsets()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &OffsetsMergeResult{}
+	for v, offs := range incoming {
+		if err := ValidateOffsets(v, offs); err != nil {
+			result.Skipped = append(result.Skipped, err.Error())
+			continue
+		}
+		if old, ok := OffSetMap[v]; ok {
+			if equalOffsets(old, offs) {
+				continue
+			}
+			result.Updated = append(result.Updated, v)
+		} else {
+			result.Added = append(result.Added, v)
+		}
+		userOffsets[v] = offs
+	}
+	sort.Strings(result.Added)
+	sort.Strings(result.Updated)
+	sort.Strings(result.Skipped)
+
+	if len(result.Added) == 0 && len(result.Updated) == 0 {
+		return result, nil
+	}
+	if err := SaveUserOffsets(userOffsets); err != nil {
+		return nil, err
+	}
+	return result, LoadOffSetMap()
+}
+
+// readUserOffsets 读取用户偏移文件，文件不存在时返回空 map
+func readUserOffsets() (map[string][]int, error) {
+	userPath, err := UserOffsetsPath()
+	if err != nil {
+		return nil, err
+	}
+	if !utils.Exists(userPath) {
+		return make(map[string][]int), nil
+	}
+	data, err := os.ReadFile(userPath)
+	if err != nil {
+		return nil, err
+	}
+	userOffsets, err := parseOffsets(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s invalid: %v", userPath, err)
+	}
+	return userOffsets, nil
+}
+
+// SaveUserOffsets 覆盖写入用户偏移文件
+func SaveUserOffsets(offsets map[string][]int) error {
+	userPath, err := UserOffsetsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(userPath), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(userPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(offsets); err != nil {
+		return err
+	}
+	logrus.Infof("偏移保存到: %s", userPath)
+	return nil
+}
+
+func equalOffsets(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// UnsupportedRunningV3 返回正在运行、但 OffSetMap 中没有偏移的 v3 进程
+func UnsupportedRunningV3() ([]*utils.MyProcess, error) {
+	processes, err := utils.FindWeixinProcesses()
+	if err != nil {
+		return nil, err
+	}
+	var unsupported []*utils.MyProcess
+	for _, proc := range processes {
+		if proc.Version != 3 {
+			continue
+		}
+		if _, ok := OffSetMap[proc.FullVersion]; !ok {
+			unsupported = append(unsupported, proc)
+		}
+	}
+	return unsupported, nil
+}
+
+// AddUserOffsets 把一个版本的偏移写入用户偏移文件，并重新加载 OffSetMap
+func AddUserOffsets(version string, offsets []int) error {
+	if err := ValidateOffsets(version, offsets); err != nil {
+		return err
+	}
+	userOffsets, err := readUserOffsets()
+	if err != nil {
+		return err
+	}
+	userOffsets[version] = offsets
+	if err := SaveUserOffsets(userOffsets); err != nil {
+		return err
+	}
+	return LoadOffSetMap()
+}
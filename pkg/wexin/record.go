@@ -0,0 +1,245 @@
+package wexin
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 合并转发中 dataitem 的 datatype
+const (
+	RecordDataText     = 1
+	RecordDataImage    = 2
+	RecordDataVoice    = 3
+	RecordDataVideo    = 4
+	RecordDataLink     = 5
+	RecordDataLocation = 6
+	RecordDataFile     = 8
+	RecordDataRecord   = 17 // 嵌套的聊天记录
+)
+
+const (
+	recordTimeLayout = "2006-1-2 15:04" // 合并转发中 sourcetime 的格式
+	maxRecordDepth   = 8                // 嵌套层数有限，防止异常数据
+)
+
+// RecordInfo 合并转发的 <recordinfo>
+type RecordInfo struct {
+	Title string       `xml:"title"`
+	Desc  string       `xml:"desc"`
+	Items []RecordItem `xml:"datalist>dataitem"`
+}
+
+// RecordItem 一条被转发的消息
+type RecordItem struct {
+	DataType         int    `xml:"datatype,attr"`
+	DataID           string `xml:"dataid,attr"`
+	Desc             string `xml:"datadesc"`
+	Title            string `xml:"datatitle"`
+	Fmt              string `xml:"datafmt"`
+	Size             int64  `xml:"datasize"`
+	SourceName       string `xml:"sourcename"`
+	SourceTime       string `xml:"sourcetime"`
+	SrcMsgCreateTime int64  `xml:"srcMsgCreateTime"`
+	FullMD5          string `xml:"fullmd5"`
+	Link             string `xml:"link"`
+	// 嵌套的聊天记录，v3 为转义后的 XML 字符串
+	RecordXML struct {
+		Text string      `xml:",chardata"`
+		Info *RecordInfo `xml:"recordinfo"`
+	} `xml:"recordxml"`
+}
+
+// ParseRecordInfo 解析 <recordinfo> XML（RecordMsg.RecordItem）
+func ParseRecordInfo(raw string) (*RecordInfo, error) {
+	var info RecordInfo
+	dec := xml.NewDecoder(strings.NewReader(trimToXML(raw)))
+	dec.Strict = false
+	if err := dec.Decode(&info); err != nil {
+		return nil, fmt.Errorf("parse recordinfo failed: %v", err)
+	}
+	return &info, nil
+}
+
+// nested 返回嵌套的聊天记录
+func (item *RecordItem) nested() *RecordInfo {
+	if item.RecordXML.Info != nil {
+		return item.RecordXML.Info
+	}
+	if text := strings.TrimSpace(item.RecordXML.Text); text != "" {
+		if info, err := ParseRecordInfo(text); err == nil {
+			return info
+		}
+	}
+	return nil
+}
+
+func (item *RecordItem) messageType() int64 {
+	switch item.DataType {
+	case RecordDataText:
+		return MsgTypeText
+	case RecordDataImage:
+		return MsgTypeImage
+	case RecordDataVoice:
+		return MsgTypeVoice
+	case RecordDataVideo:
+		return MsgTypeVideo
+	case RecordDataLocation:
+		return MsgTypeLocation
+	}
+	return MsgTypeApp
+}
+
+func (item *RecordItem) createTime() time.Time {
+	if item.SrcMsgCreateTime > 0 {
+		return time.Unix(item.SrcMsgCreateTime, 0)
+	}
+	if t, err := time.ParseInLocation(recordTimeLayout, item.SourceTime, time.Local); err == nil {
+		return t
+	}
+	return time.Time{}
+}
+
+// recordMediaResolver 在合并转发的媒体目录下按 dataid、md5、文件名查找文件
+// v3：FileStorage/**/RecordFile/，v4：msg/attach/<md5(talker)>/**/Rec/
+type recordMediaResolver struct {
+	roots []string
+	index map[string]string // 小写的文件名（含、不含扩展名）=> 路径
+}
+
+// recordResolver 返回合并转发媒体索引，DataDir 为空时不查找。
+// v3 的 RecordFile 不分会话，整个账号共用一个索引；v4 按 md5(talker) 分目录，每个会话一个索引
+func (a *Account) recordResolver(talker string) *recordMediaResolver {
+	key := talker
+	if a.Version != 4 {
+		key = ""
+	}
+	if r, ok := a.recordResolvers[key]; ok {
+		return r
+	}
+	if a.recordResolvers == nil {
+		a.recordResolvers = make(map[string]*recordMediaResolver)
+	}
+	r := &recordMediaResolver{}
+	a.recordResolvers[key] = r
+	if a.DataDir == "" {
+		return r
+	}
+	var base, dirName string
+	if a.Version == 4 {
+		sum := md5.Sum([]byte(talker))
+		base, dirName = filepath.Join(a.imageDirV4(), hex.EncodeToString(sum[:])), "Rec"
+	} else {
+		base, dirName = filepath.Join(a.DataDir, "FileStorage"), "RecordFile"
+	}
+	filepath.WalkDir(base, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && d.Name() == dirName {
+			r.roots = append(r.roots, path)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return r
+}
+
+func (r *recordMediaResolver) buildIndex() {
+	r.index = make(map[string]string)
+	for _, root := range r.roots {
+		filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			name := strings.ToLower(d.Name())
+			for _, key := range []string{name, strings.TrimSuffix(name, filepath.Ext(name))} {
+				if _, ok := r.index[key]; !ok {
+					r.index[key] = path
+				}
+			}
+			return nil
+		})
+	}
+}
+
+func (r *recordMediaResolver) resolve(item *RecordItem) string {
+	switch item.DataType {
+	case RecordDataText, RecordDataLink, RecordDataLocation, RecordDataRecord:
+		return ""
+	}
+	if len(r.roots) == 0 {
+		return ""
+	}
+	if r.index == nil {
+		r.buildIndex()
+	}
+	for _, key := range []string{item.DataID, item.FullMD5, item.Title} {
+		if key == "" {
+			continue
+		}
+		if path, ok := r.index[strings.ToLower(key)]; ok {
+			return path
+		}
+	}
+	return ""
+}
+
+// ExpandRecord 把合并转发消息（appmsg type 19）展开成 m.Children，嵌套的记录递归展开
+func (a *Account) ExpandRecord(m *Message) error {
+	app, err := m.AppMsg()
+	if err != nil {
+		return err
+	}
+	record, ok := app.(*RecordMsg)
+	if !ok {
+		return fmt.Errorf("appmsg type %d is not record", app.AppType())
+	}
+	info, err := ParseRecordInfo(record.RecordItem)
+	if err != nil {
+		return err
+	}
+	m.Children = buildRecordMessages(m, info, a.recordResolver(m.Talker), 0)
+	return nil
+}
+
+func buildRecordMessages(parent *Message, info *RecordInfo, resolver *recordMediaResolver, depth int) []*Message {
+	var children []*Message
+	for i := range info.Items {
+		item := &info.Items[i]
+		c := &Message{
+			Version:    parent.Version,
+			Talker:     parent.Talker,
+			Type:       item.messageType(),
+			SenderName: item.SourceName,
+			CreateTime: item.createTime(),
+			Content:    item.Desc,
+			Shard:      parent.Shard,
+		}
+		switch item.DataType {
+		case RecordDataFile:
+			c.SubType = AppMsgFile
+			c.Content = item.Title
+		case RecordDataLink:
+			c.SubType = AppMsgLink
+			c.Content = strings.TrimSpace(item.Title + " " + item.Link)
+		case RecordDataRecord:
+			c.SubType = AppMsgRecord
+			c.Content = item.Title
+			if nested := item.nested(); nested != nil && depth < maxRecordDepth {
+				if c.Content == "" {
+					c.Content = nested.Title
+				}
+				c.Children = buildRecordMessages(c, nested, resolver, depth+1)
+			}
+		}
+		c.MediaPath = resolver.resolve(item)
+		children = append(children, c)
+	}
+	return children
+}
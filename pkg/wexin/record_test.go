@@ -0,0 +1,54 @@
+package wexin
+
+import (
+	"html"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpandRecord(t *testing.T) {
+	nested := `<recordinfo><title>inner</title><datalist count="1"><dataitem datatype="1"><datadesc>deep</datadesc><sourcename>C</sourcename></dataitem></datalist></recordinfo>`
+	rec := `<recordinfo><title>群聊的聊天记录</title><datalist count="3">` +
+		`<dataitem datatype="1" dataid="d1"><datadesc>hello</datadesc><sourcename>A</sourcename><sourcetime>2024-1-2 10:30</sourcetime></dataitem>` +
+		`<dataitem datatype="2" dataid="ABCDEF"><datafmt>jpg</datafmt><sourcename>B</sourcename><srcMsgCreateTime>1700000000</srcMsgCreateTime></dataitem>` +
+		`<dataitem datatype="17" dataid="d3"><datatitle>嵌套</datatitle><recordxml>` + html.EscapeString(nested) + `</recordxml></dataitem>` +
+		`</datalist></recordinfo>`
+	content := `<msg><appmsg><title>聊天记录</title><type>19</type><recorditem><![CDATA[` + rec + `]]></recorditem></appmsg></msg>`
+
+	dataDir := t.TempDir()
+	image := filepath.Join(dataDir, "FileStorage", "RecordFile", "2024-01", "abcdef.jpg")
+	if err := os.MkdirAll(filepath.Dir(image), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(image, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &Account{Version: 3, DataDir: dataDir}
+	m := &Message{Version: 3, Type: MsgTypeApp, Talker: "g@chatroom", Content: content}
+	if err := a.ExpandRecord(m); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Children) != 3 {
+		t.Fatalf("children = %d, want 3", len(m.Children))
+	}
+	if c := m.Children[0]; c.Content != "hello" || c.SenderName != "A" || c.CreateTime.IsZero() {
+		t.Errorf("text item = %+v", c)
+	}
+	if c := m.Children[1]; c.MediaPath != image || c.Type != MsgTypeImage {
+		t.Errorf("image item = %+v", c)
+	}
+	if c := m.Children[2]; len(c.Children) != 1 || c.Children[0].Content != "deep" {
+		t.Errorf("nested item = %+v", c)
+	}
+
+	// v3 的 RecordFile 不分会话，不同会话共用一个索引
+	if a.recordResolver("wxid_a") != a.recordResolver("g@chatroom") {
+		t.Error("v3 resolver should be shared by all talkers")
+	}
+	v4 := &Account{Version: 4, DataDir: dataDir}
+	if v4.recordResolver("wxid_a") == v4.recordResolver("g@chatroom") {
+		t.Error("v4 resolver should be per talker")
+	}
+}
@@ -0,0 +1,55 @@
+package wexin
+
+import "github.com/sirupsen/logrus"
+
+// ReplyIndex 按 ServerID 索引消息，用于把引用回复（appmsg type 57）关联到原消息
+type ReplyIndex struct {
+	byServerID map[int64]*Message
+}
+
+// LinkReplies 为 msgs 建立索引，并填充每条引用回复的 ReplyTo 和原消息的 Replies。
+// msgs 通常是 CollectMessages 的结果，已经包含所有分片；原消息被删除时 ReplyTo 为 nil，
+// 被引用的内容仍可以通过 AppMsg 得到
+func LinkReplies(msgs []*Message) *ReplyIndex {
+	idx := &ReplyIndex{byServerID: make(map[int64]*Message, len(msgs))}
+	for _, m := range msgs {
+		if m.ServerID != 0 {
+			idx.byServerID[m.ServerID] = m
+		}
+	}
+	linked, missing := 0, 0
+	for _, m := range msgs {
+		if m.Type != MsgTypeApp || m.SubType != AppMsgQuote {
+			continue
+		}
+		app, err := m.AppMsg()
+		if err != nil {
+			logrus.Debugf("%s local id %d: %v", m.Shard, m.LocalID, err)
+			continue
+		}
+		quote, ok := app.(*QuoteMsg)
+		if !ok {
+			continue
+		}
+		orig := idx.Lookup(quote.Refer.SvrID)
+		if orig == nil || orig == m {
+			missing++
+			continue
+		}
+		m.ReplyTo = orig
+		orig.Replies = append(orig.Replies, m)
+		linked++
+	}
+	if linked+missing > 0 {
+		logrus.Debugf("link replies: linked=%d missing=%d", linked, missing)
+	}
+	return idx
+}
+
+// Lookup 按 ServerID 查找消息
+func (idx *ReplyIndex) Lookup(serverID int64) *Message {
+	if serverID == 0 {
+		return nil
+	}
+	return idx.byServerID[serverID]
+}
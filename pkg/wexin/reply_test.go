@@ -0,0 +1,31 @@
+package wexin
+
+import (
+	"fmt"
+	"testing"
+)
+
+func quoteMessage(serverID, referID int64) *Message {
+	return &Message{ServerID: serverID, Type: MsgTypeApp, SubType: AppMsgQuote,
+		Content: fmt.Sprintf(`<msg><appmsg><title>re</title><type>57</type><refermsg><svrid>%d</svrid></refermsg></appmsg></msg>`, referID)}
+}
+
+func TestLinkReplies(t *testing.T) {
+	orig := &Message{ServerID: 42, Type: MsgTypeText, Content: "hi"}
+	reply := quoteMessage(43, 42)
+	second := quoteMessage(44, 42)
+	dangling := quoteMessage(45, 999) // 原消息已删除
+	self := quoteMessage(46, 46)      // 不会引用自己
+	broken := &Message{ServerID: 47, Type: MsgTypeApp, SubType: AppMsgQuote, Content: "<msg"}
+	idx := LinkReplies([]*Message{orig, reply, second, dangling, self, broken})
+
+	if reply.ReplyTo != orig || second.ReplyTo != orig || len(orig.Replies) != 2 || orig.Replies[0] != reply {
+		t.Errorf("orig.Replies = %v, reply.ReplyTo = %v", orig.Replies, reply.ReplyTo)
+	}
+	if dangling.ReplyTo != nil || self.ReplyTo != nil || broken.ReplyTo != nil {
+		t.Error("unresolved replies should have no ReplyTo")
+	}
+	if idx.Lookup(43) != reply || idx.Lookup(999) != nil || idx.Lookup(0) != nil {
+		t.Error("Lookup by server id")
+	}
+}
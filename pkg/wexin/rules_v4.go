@@ -0,0 +1,148 @@
+package wexin
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/saucer-man/wxdump/pkg/utils"
+)
+
+// v4 内存中用户信息的特征规则，按 FullVersion 范围匹配
+//
+//go:embed rules_v4.json
+var defaultRulesV4 []byte
+
+const (
+	RulesV4Env      = "WXDUMP_V4_RULES" // 环境变量，指定规则文件路径
+	RulesV4FileName = "rules_v4.json"
+	RulesV4Schema   = 1
+)
+
+// UserInfoRuleV4 一条用户信息规则：设备类型特征码、以特征码为锚点读取的窗口大小，
+// 以及在窗口中提取账号、昵称、手机号的正则
+type UserInfoRuleV4 struct {
+	Name           string   `json:"name"`
+	MinVersion     string   `json:"min_version"` // 为空表示不限
+	MaxVersion     string   `json:"max_version"` // 为空表示不限
+	DevicePatterns []string `json:"device_patterns"`
+	WindowBefore   int      `json:"window_before"` // 特征码之前读取的字节数
+	WindowAfter    int      `json:"window_after"`  // 特征码之后读取的字节数
+	UserBlockRegex string   `json:"user_block_regex"`
+	Fields         []string `json:"fields"` // 正则每个分组对应的字段：account、nickname、phone
+
+	userBlockRe *regexp.Regexp
+	fieldIndex  map[string]int
+}
+
+type RulesV4 struct {
+	Schema int               `json:"schema"`
+	Rules  []*UserInfoRuleV4 `json:"rules"`
+}
+
+// UserRulesV4Path 用户规则文件路径：%AppData%\wxdump\rules_v4.json
+func UserRulesV4Path() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "wxdump", RulesV4FileName), nil
+}
+
+// LoadRulesV4 加载规则文件：显式指定的文件 > 环境变量指定的文件 > 用户文件 > 内置默认规则
+func LoadRulesV4(path string) (*RulesV4, error) {
+	if path == "" {
+		path = os.Getenv(RulesV4Env)
+	}
+	if path == "" {
+		if userPath, err := UserRulesV4Path(); err == nil && utils.Exists(userPath) {
+			path = userPath
+		}
+	}
+	if path == "" {
+		return ParseRulesV4(defaultRulesV4)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := ParseRulesV4(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s invalid: %v", path, err)
+	}
+	return rules, nil
+}
+
+// ParseRulesV4 解析并校验规则
+func ParseRulesV4(data []byte) (*RulesV4, error) {
+	var rules RulesV4
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	if rules.Schema != RulesV4Schema {
+		return nil, fmt.Errorf("unsupported schema %d, want %d", rules.Schema, RulesV4Schema)
+	}
+	if len(rules.Rules) == 0 {
+		return nil, fmt.Errorf("no rules")
+	}
+	for i, r := range rules.Rules {
+		if err := r.compile(); err != nil {
+			return nil, fmt.Errorf("rules[%d] %s: %v", i, r.Name, err)
+		}
+	}
+	return &rules, nil
+}
+
+func (r *UserInfoRuleV4) compile() error {
+	if len(r.DevicePatterns) == 0 {
+		return fmt.Errorf("device_patterns is empty")
+	}
+	for _, pat := range r.DevicePatterns {
+		if pat == "" {
+			return fmt.Errorf("device_patterns contains empty pattern")
+		}
+	}
+	if r.WindowBefore <= 0 || r.WindowAfter < 0 {
+		return fmt.Errorf("invalid window size")
+	}
+	re, err := regexp.Compile(r.UserBlockRegex)
+	if err != nil {
+		return err
+	}
+	if re.NumSubexp() != len(r.Fields) {
+		return fmt.Errorf("regex has %d groups but %d fields", re.NumSubexp(), len(r.Fields))
+	}
+	r.fieldIndex = make(map[string]int, len(r.Fields))
+	for i, field := range r.Fields {
+		switch field {
+		case "account", "nickname", "phone":
+			r.fieldIndex[field] = i + 1
+		default:
+			return fmt.Errorf("unknown field %q", field)
+		}
+	}
+	for _, field := range []string{"account", "nickname", "phone"} {
+		if _, ok := r.fieldIndex[field]; !ok {
+			return fmt.Errorf("missing field %q", field)
+		}
+	}
+	r.userBlockRe = re
+	return nil
+}
+
+// Match 返回第一条版本范围包含 fullVersion 的规则
+func (rules *RulesV4) Match(fullVersion string) (*UserInfoRuleV4, error) {
+	for _, r := range rules.Rules {
+		if r.MinVersion != "" && utils.CompareVersion(fullVersion, r.MinVersion) < 0 {
+			continue
+		}
+		if r.MaxVersion != "" && utils.CompareVersion(fullVersion, r.MaxVersion) > 0 {
+			continue
+		}
+		return r, nil
+	}
+	return nil, fmt.Errorf("no rule matches version %s", fullVersion)
+}
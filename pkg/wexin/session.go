@@ -0,0 +1,135 @@
+package wexin
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const v4SessionDBRel = "session/session.db"
+
+// Session 会话列表中的一项
+type Session struct {
+	Username    string
+	DisplayName string
+	Summary     string // 最后一条消息的摘要
+	LastTime    time.Time
+	LastMsgType int64
+	LastSender  string
+	Unread      int64
+	Sticky      bool  // 置顶，只有 v3，来自 Contact.Type
+	Muted       bool  // 消息免打扰，只有 v3，来自 Contact.Type
+	Hidden      bool  // 折叠、隐藏的会话（v4）
+	SortOrder   int64 // 客户端的排序值（v4 sort_timestamp、v3 nOrder），越大越靠前
+}
+
+// LoadSessions 读取会话列表，顺序与客户端一致：按客户端的排序值倒序，v3 置顶的会话在前。
+// v4 读取 session.db，v3 读取 MicroMsg.db 的 Session 表；显示名，以及 v3 的置顶、免打扰来自联系人。
+// v4 的会话表和联系人表里没有确认过的置顶、免打扰字段，这两项为 false
+func (a *Account) LoadSessions(decryptedDir string) ([]*Session, error) {
+	var (
+		sessions []*Session
+		err      error
+	)
+	if a.Version == 4 {
+		sessions, err = a.loadSessionsV4(decryptedDir)
+	} else {
+		sessions, err = a.loadSessionsV3(decryptedDir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	contacts, err := a.LoadContacts(decryptedDir)
+	if err != nil {
+		logrus.Infof("load contacts failed: %v", err)
+	}
+	for _, s := range sessions {
+		if contacts == nil {
+			break
+		}
+		if c, ok := contacts.Get(s.Username); ok {
+			s.DisplayName = c.DisplayName()
+			s.Sticky = c.IsSticky
+			s.Muted = c.IsMuted
+		}
+	}
+	for _, s := range sessions {
+		if s.DisplayName == "" {
+			s.DisplayName = s.Username
+		}
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		if sessions[i].Sticky != sessions[j].Sticky {
+			return sessions[i].Sticky
+		}
+		return sessions[i].SortOrder > sessions[j].SortOrder
+	})
+	return sessions, nil
+}
+
+func (a *Account) loadSessionsV4(decryptedDir string) ([]*Session, error) {
+	dbPath, err := a.decryptedDBPath(decryptedDir, v4SessionDBRel)
+	if err != nil {
+		return nil, err
+	}
+	var sessions []*Session
+	err = querySQLite(dbPath, `SELECT username, summary, last_timestamp, sort_timestamp, unread_count, is_hidden,
+		last_msg_type, last_msg_sender FROM SessionTable`, func(rows *sql.Rows) error {
+		var username, summary, sender sql.NullString
+		var lastTime, sortTime, unread, hidden, msgType sql.NullInt64
+		if err := rows.Scan(&username, &summary, &lastTime, &sortTime, &unread, &hidden, &msgType, &sender); err != nil {
+			return err
+		}
+		sessions = append(sessions, &Session{
+			Username:    username.String,
+			Summary:     summary.String,
+			LastTime:    time.Unix(lastTime.Int64, 0),
+			LastMsgType: msgType.Int64,
+			LastSender:  sender.String,
+			Unread:      unread.Int64,
+			Hidden:      hidden.Int64 != 0,
+			SortOrder:   sortTime.Int64,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("query SessionTable failed: %v", err)
+	}
+	return sessions, nil
+}
+
+func (a *Account) loadSessionsV3(decryptedDir string) ([]*Session, error) {
+	dbPath, err := a.decryptedDBPath(decryptedDir, v3MicroMsgDBRel, v3MicroMsgDBRelLegacy)
+	if err != nil {
+		return nil, err
+	}
+	var sessions []*Session
+	err = querySQLite(dbPath, `SELECT strUsrName, strContent, nTime, nOrder, nUnReadCount, nMsgType, nIsSend FROM Session`, func(rows *sql.Rows) error {
+		var username, content sql.NullString
+		var lastTime, order, unread, msgType, isSend sql.NullInt64
+		if err := rows.Scan(&username, &content, &lastTime, &order, &unread, &msgType, &isSend); err != nil {
+			return err
+		}
+		s := &Session{
+			Username:    username.String,
+			Summary:     content.String,
+			LastTime:    time.Unix(lastTime.Int64, 0),
+			LastMsgType: msgType.Int64,
+			Unread:      unread.Int64,
+			SortOrder:   order.Int64,
+		}
+		if isSend.Int64 == 1 {
+			s.LastSender = a.Wxid
+		}
+		sessions = append(sessions, s)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("query Session failed: %v", err)
+	}
+	return sessions, nil
+}
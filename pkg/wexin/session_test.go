@@ -0,0 +1,79 @@
+package wexin
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func sessionNames(ss []*Session) string {
+	var names []string
+	for _, s := range ss {
+		names = append(names, s.Username)
+	}
+	return fmt.Sprint(names)
+}
+
+func TestLoadSessionsV3(t *testing.T) {
+	dir := t.TempDir()
+	createDB(t, filepath.Join(dir, "wxid_me", "Msg", "MicroMsg.db"),
+		`CREATE TABLE Contact(UserName TEXT, Alias TEXT, Type INT, VerifyFlag INT, Remark TEXT, NickName TEXT, LabelIDList TEXT,
+			QuanPin TEXT, RemarkQuanPin TEXT, BigHeadImgUrl TEXT, SmallHeadImgUrl TEXT, ExtraBuf BLOB)`,
+		`CREATE TABLE ContactHeadImgUrl(usrName TEXT, smallHeadImgUrl TEXT, bigHeadImgUrl TEXT)`,
+		fmt.Sprintf(`INSERT INTO Contact(UserName, Type, Remark, NickName) VALUES ('wxid_a', 1, '', 'Alice'), ('g@chatroom', %d, '', 'G')`,
+			contactFlagSticky|contactFlagMute|2),
+		`CREATE TABLE Session(strUsrName TEXT, nOrder INT, nUnReadCount INT, strNickName TEXT, nStatus INT, nIsSend INT,
+			strContent TEXT, nMsgType INT, nMsgLocalID INT, nTime INT)`,
+		`INSERT INTO Session VALUES ('wxid_a', 300, 2, '', 0, 0, 'hi', 1, 5, 300), ('wxid_b', 200, 0, '', 0, 1, 'yo', 1, 6, 200),
+			('g@chatroom', 100, 0, '', 0, 0, 'x', 1, 7, 100)`)
+
+	ss, err := (&Account{Version: 3, Wxid: "wxid_me"}).LoadSessions(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 置顶的群聊在前
+	if got := sessionNames(ss); got != "[g@chatroom wxid_a wxid_b]" {
+		t.Fatalf("order = %s", got)
+	}
+	if g := ss[0]; !g.Sticky || !g.Muted || g.DisplayName != "G" {
+		t.Errorf("g@chatroom = %+v", g)
+	}
+	if a := ss[1]; a.DisplayName != "Alice" || a.Unread != 2 || a.Summary != "hi" || a.LastTime.Unix() != 300 || a.LastSender != "" {
+		t.Errorf("wxid_a = %+v", a)
+	}
+	if b := ss[2]; b.DisplayName != "wxid_b" || b.LastSender != "wxid_me" {
+		t.Errorf("wxid_b = %+v", b)
+	}
+}
+
+func TestLoadSessionsV4(t *testing.T) {
+	dir := t.TempDir()
+	createDB(t, filepath.Join(dir, "wxid_me", "session", "session.db"),
+		`CREATE TABLE SessionTable(username TEXT, summary TEXT, last_timestamp INTEGER, sort_timestamp INTEGER, unread_count INTEGER,
+			is_hidden INTEGER, last_msg_type INTEGER, last_msg_sender TEXT)`,
+		`INSERT INTO SessionTable VALUES ('wxid_a', 'hi', 100, 100, 2, 0, 1, 'wxid_a'), ('wxid_b', 'yo', 200, 200, 0, 1, 1, 'wxid_me'),
+			('g@chatroom', 'x', 50, 300, 0, 0, 1, '')`)
+	createDB(t, filepath.Join(dir, "wxid_me", "contact", "contact.db"),
+		`CREATE TABLE contact(id INTEGER PRIMARY KEY, username TEXT, local_type INTEGER, alias TEXT, remark TEXT, remark_quan_pin TEXT,
+			nick_name TEXT, quan_pin TEXT, big_head_url TEXT, small_head_url TEXT, description TEXT, extra_buffer BLOB, flag INTEGER,
+			verify_flag INTEGER)`,
+		`INSERT INTO contact(username, nick_name, flag, local_type) VALUES ('wxid_a', 'Alice', 1, 1), ('g@chatroom', 'G', 2051, 2)`)
+
+	ss, err := (&Account{Version: 4, Wxid: "wxid_me"}).LoadSessions(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 按 sort_timestamp 排序，v4 不设置置顶
+	if got := sessionNames(ss); got != "[g@chatroom wxid_b wxid_a]" {
+		t.Fatalf("order = %s", got)
+	}
+	if ss[0].Sticky || ss[0].Muted || ss[0].DisplayName != "G" || ss[0].LastTime.Unix() != 50 {
+		t.Errorf("g@chatroom = %+v", ss[0])
+	}
+	if b := ss[1]; !b.Hidden || b.LastSender != "wxid_me" || b.DisplayName != "wxid_b" {
+		t.Errorf("wxid_b = %+v", b)
+	}
+	if a := ss[2]; a.DisplayName != "Alice" || a.Unread != 2 || a.SortOrder != 100 {
+		t.Errorf("wxid_a = %+v", a)
+	}
+}
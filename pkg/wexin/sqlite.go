@@ -0,0 +1,97 @@
+package wexin
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/saucer-man/wxdump/pkg/utils"
+
+	_ "modernc.org/sqlite"
+)
+
+// openSQLite 以只读方式打开解密后的数据库
+func openSQLite(path string) (*sql.DB, error) {
+	if !utils.Exists(path) {
+		return nil, fmt.Errorf("db not found: %s", path)
+	}
+	db, err := sql.Open("sqlite", "file:"+filepath.ToSlash(path)+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// decryptedAccountDir 解密目录结构为 decryptedDir/wxid/...
+func decryptedAccountDir(decryptedDir, wxid string) string {
+	return filepath.Join(decryptedDir, wxid)
+}
+
+// decryptedDBPath 返回解密目录下第一个存在的数据库路径
+func (a *Account) decryptedDBPath(decryptedDir string, candidates ...string) (string, error) {
+	for _, rel := range candidates {
+		p := filepath.Join(decryptedAccountDir(decryptedDir, a.Wxid), filepath.FromSlash(rel))
+		if utils.Exists(p) {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("%v not found in %s", candidates, filepath.Join(decryptedDir, a.Wxid))
+}
+
+// decryptedDBGlob 返回解密目录下匹配的所有数据库，按分片序号排序（MSG0、MSG1 ... MSG10）
+func (a *Account) decryptedDBGlob(decryptedDir string, patterns ...string) []string {
+	var paths []string
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(filepath.Join(decryptedAccountDir(decryptedDir, a.Wxid), filepath.FromSlash(pattern)))
+		paths = append(paths, matches...)
+	}
+	paths = utils.Unique(paths)
+	sortShards(paths, func(p string) string { return p })
+	return paths
+}
+
+// sortShards 按分片序号排序
+func sortShards[T any](shards []T, path func(T) string) {
+	sort.SliceStable(shards, func(i, j int) bool {
+		return shardIndex(path(shards[i])) < shardIndex(path(shards[j]))
+	})
+}
+
+var shardIndexRe = regexp.MustCompile(`(\d+)\.db$`)
+
+// shardIndex 取出分片文件名末尾的序号，MSG12.db => 12，message_3.db => 3
+func shardIndex(path string) int {
+	m := shardIndexRe.FindStringSubmatch(filepath.Base(path))
+	if m == nil {
+		return -1
+	}
+	n, _ := strconv.Atoi(m[1])
+	return n
+}
+
+// querySQLite 打开数据库执行查询，逐行回调
+func querySQLite(path, query string, fn func(rows *sql.Rows) error, args ...any) error {
+	db, err := openSQLite(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,82 @@
+package wexin
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 用户信息各字段的来源
+const (
+	InfoSourceMemory     = "memory"
+	InfoSourceContactDB  = "contact.db"
+	InfoSourceMicroMsgDB = "MicroMsg.db"
+)
+
+const (
+	infoFieldNickname  = "Nickname"
+	infoFieldWxAccount = "WxAccount"
+	infoFieldPhone     = "Phone"
+	infoFieldRemark    = "Remark"
+	infoFieldAvatarURL = "AvatarURL"
+)
+
+// 解密后数据库相对 decryptedDir/wxid 的路径
+const (
+	v4ContactDBRel        = "contact/contact.db"
+	v3MicroMsgDBRel       = "Msg/MicroMsg.db"
+	v3MicroMsgDBRelLegacy = "MicroMsg.db"
+)
+
+// setInfo 设置用户信息字段并记录来源，已有值的字段不会被覆盖
+func (a *Account) setInfo(field string, dst *string, value, source string) {
+	if *dst != "" || value == "" {
+		return
+	}
+	*dst = value
+	if a.InfoSource == nil {
+		a.InfoSource = make(map[string]string)
+	}
+	a.InfoSource[field] = source
+}
+
+// LoadUserInfoFromDB 从解密后的数据库中读取账号自己的联系人记录，补全内存中没拿到的昵称、微信号、备注和头像
+// v4 读取 contact.db，v3 读取 MicroMsg.db；decryptedDir 与 DecryptDBV4 的参数相同
+func (a *Account) LoadUserInfoFromDB(decryptedDir string) error {
+	dbPath, err := a.contactDBPath(decryptedDir)
+	if err != nil {
+		return err
+	}
+	source := InfoSourceMicroMsgDB
+	if a.Version == 4 {
+		source = InfoSourceContactDB
+	}
+
+	// v4 目录名带后缀，HandleWxidV4 处理后的 wxid 查不到时再用目录名试一次
+	usernames := []string{a.Wxid}
+	if dirName := filepath.Base(a.DataDir); a.DataDir != "" && dirName != a.Wxid {
+		usernames = append(usernames, dirName)
+	}
+	var self *Contact
+	for _, username := range usernames {
+		list, err := queryContacts(dbPath, a.Version, username)
+		if err != nil {
+			return fmt.Errorf("query %s failed: %v", source, err)
+		}
+		if len(list) > 0 {
+			self = list[0]
+			break
+		}
+	}
+	if self == nil {
+		return fmt.Errorf("can't find self contact %s in %s", a.Wxid, source)
+	}
+
+	a.setInfo(infoFieldNickname, &a.Nickname, self.Nickname, source)
+	a.setInfo(infoFieldWxAccount, &a.WxAccount, self.Alias, source)
+	a.setInfo(infoFieldRemark, &a.Remark, self.Remark, source)
+	a.setInfo(infoFieldAvatarURL, &a.AvatarURL, self.AvatarURL(), source)
+	logrus.Infof("load userinfo from %s: account=%s nickname=%s sources=%v", source, a.WxAccount, a.Nickname, a.InfoSource)
+	return nil
+}
@@ -1,492 +1,26 @@
 package wexin
 
 import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
 	"encoding/binary"
 	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"unsafe"
 
 	"github.com/sirupsen/logrus"
-
+	"golang.org/x/crypto/pbkdf2"
 	"golang.org/x/sys/windows"
 )
 
-// Unmarshal the JSON into a map[string][]int
-var OffSetMap map[string][]int
-
-func init() {
-	// https://raw.githubusercontent.com/xaoyaoo/PyWxDump/refs/heads/master/pywxdump/WX_OFFS.json
-	offset := `
-	{
-		"3.2.1.154": [
-		  328121948,
-		  328122328,
-		  328123056,
-		  328121976,
-		  328123020
-		],
-		"3.3.0.115": [
-		  31323364,
-		  31323744,
-		  31324472,
-		  31323392,
-		  31324436
-		],
-		"3.3.0.84": [
-		  31315212,
-		  31315592,
-		  31316320,
-		  31315240,
-		  31316284
-		],
-		"3.3.0.93": [
-		  31323364,
-		  31323744,
-		  31324472,
-		  31323392,
-		  31324436
-		],
-		"3.3.5.34": [
-		  30603028,
-		  30603408,
-		  30604120,
-		  30603056,
-		  30604100
-		],
-		"3.3.5.42": [
-		  30603012,
-		  30603392,
-		  30604120,
-		  30603040,
-		  30604084
-		],
-		"3.3.5.46": [
-		  30578372,
-		  30578752,
-		  30579480,
-		  30578400,
-		  30579444
-		],
-		"3.4.0.37": [
-		  31608116,
-		  31608496,
-		  31609224,
-		  31608144,
-		  31609188
-		],
-		"3.4.0.38": [
-		  31604044,
-		  31604424,
-		  31605152,
-		  31604072,
-		  31605116
-		],
-		"3.4.0.50": [
-		  31688500,
-		  31688880,
-		  31689608,
-		  31688528,
-		  31689572
-		],
-		"3.4.0.54": [
-		  31700852,
-		  31701248,
-		  31700920,
-		  31700880,
-		  31701924
-		],
-		"3.4.5.27": [
-		  32133788,
-		  32134168,
-		  32134896,
-		  32133816,
-		  32134860
-		],
-		"3.4.5.45": [
-		  32147012,
-		  32147392,
-		  32147064,
-		  32147040,
-		  32148084
-		],
-		"3.5.0.20": [
-		  35494484,
-		  35494864,
-		  35494536,
-		  35494512,
-		  35495556
-		],
-		"3.5.0.29": [
-		  35507980,
-		  35508360,
-		  35508032,
-		  35508008,
-		  35509052
-		],
-		"3.5.0.33": [
-		  35512140,
-		  35512520,
-		  35512192,
-		  35512168,
-		  35513212
-		],
-		"3.5.0.39": [
-		  35516236,
-		  35516616,
-		  35516288,
-		  35516264,
-		  35517308
-		],
-		"3.5.0.42": [
-		  35512140,
-		  35512520,
-		  35512192,
-		  35512168,
-		  35513212
-		],
-		"3.5.0.44": [
-		  35510836,
-		  35511216,
-		  35510896,
-		  35510864,
-		  35511908
-		],
-		"3.5.0.46": [
-		  35506740,
-		  35507120,
-		  35506800,
-		  35506768,
-		  35507812
-		],
-		"3.6.0.18": [
-		  35842996,
-		  35843376,
-		  35843048,
-		  35843024,
-		  35844068
-		],
-		"3.6.5.7": [
-		  35864356,
-		  35864736,
-		  35864408,
-		  35864384,
-		  35865428
-		],
-		"3.6.5.16": [
-		  35909428,
-		  35909808,
-		  35909480,
-		  35909456,
-		  35910500
-		],
-		"3.7.0.26": [
-		  37105908,
-		  37106288,
-		  37105960,
-		  37105936,
-		  37106980
-		],
-		"3.7.0.29": [
-		  37105908,
-		  37106288,
-		  37105960,
-		  37105936,
-		  37106980
-		],
-		"3.7.0.30": [
-		  37118196,
-		  37118576,
-		  37118248,
-		  37118224,
-		  37119268
-		],
-		"3.7.5.11": [
-		  37883280,
-		  37884088,
-		  37883136,
-		  37883008,
-		  37884052
-		],
-		"3.7.5.23": [
-		  37895736,
-		  37896544,
-		  37895592,
-		  37883008,
-		  37896508
-		],
-		"3.7.5.27": [
-		  37895736,
-		  37896544,
-		  37895592,
-		  37895464,
-		  37896508
-		],
-		"3.7.5.31": [
-		  37903928,
-		  37904736,
-		  37903784,
-		  37903656,
-		  37904700
-		],
-		"3.7.6.24": [
-		  38978840,
-		  38979648,
-		  38978696,
-		  38978604,
-		  38979612
-		],
-		"3.7.6.29": [
-		  38986376,
-		  38987184,
-		  38986232,
-		  38986104,
-		  38987148
-		],
-		"3.7.6.44": [
-		  39016520,
-		  39017328,
-		  39016376,
-		  38986104,
-		  39017292
-		],
-		"3.8.0.31": [
-		  46064088,
-		  46064912,
-		  46063944,
-		  38986104,
-		  46064876
-		],
-		"3.8.0.33": [
-		  46059992,
-		  46060816,
-		  46059848,
-		  38986104,
-		  46060780
-		],
-		"3.8.0.41": [
-		  46064024,
-		  46064848,
-		  46063880,
-		  38986104,
-		  46064812
-		],
-		"3.8.1.26": [
-		  46409448,
-		  46410272,
-		  46409304,
-		  38986104,
-		  46410236
-		],
-		"3.9.0.28": [
-		  48418376,
-		  48419280,
-		  48418232,
-		  38986104,
-		  48419244
-		],
-		"3.9.2.23": [
-		  50320784,
-		  50321712,
-		  50320640,
-		  38986104,
-		  50321676
-		],
-		"3.9.2.26": [
-		  50329040,
-		  50329968,
-		  50328896,
-		  38986104,
-		  50329932
-		],
-		"3.9.5.81": [
-		  61650872,
-		  61652208,
-		  61650680,
-		  0,
-		  61652144
-		],
-		"3.9.5.91": [
-		  61654904,
-		  61656240,
-		  61654712,
-		  38986104,
-		  61656176
-		],
-		"3.9.6.19": [
-		  61997688,
-		  61997464,
-		  61997496,
-		  38986104,
-		  61998960
-		],
-		"3.9.6.33": [
-		  62030600,
-		  62031936,
-		  62030408,
-		  0,
-		  62031872
-		],
-		"3.9.7.15": [
-		  63482696,
-		  63484032,
-		  63482504,
-		  0,
-		  63483968
-		],
-		"3.9.7.25": [
-		  63482760,
-		  63484096,
-		  63482568,
-		  0,
-		  63484032
-		],
-		"3.9.7.29": [
-		  63486984,
-		  63488320,
-		  63486792,
-		  0,
-		  63488256
-		],
-		"3.9.8.12": [
-		  53479320,
-		  53480288,
-		  53479176,
-		  0,
-		  53480252
-		],
-		"3.9.8.15": [
-		  64996632,
-		  64997968,
-		  64996440,
-		  0,
-		  64997904
-		],
-		"3.9.8.25": [
-		  65000920,
-		  65002256,
-		  65000728,
-		  0,
-		  65002192
-		],
-		"3.9.9.27": [
-		  68065304,
-		  68066640,
-		  68065112,
-		  0,
-		  68066576
-		],
-		"3.9.9.35": [
-		  68065304,
-		  68066640,
-		  68065112,
-		  0,
-		  68066576
-		],
-		"3.9.9.43": [
-		  68065944,
-		  68067280,
-		  68065752,
-		  0,
-		  68067216
-		],
-		"3.9.10.19": [
-		  95129768,
-		  95131104,
-		  95129576,
-		  0,
-		  95131040
-		],
-		"3.9.10.27": [
-		  95125656,
-		  95126992,
-		  95125464,
-		  0,
-		  95126928
-		],
-		"3.9.11.17": [
-		  93550360,
-		  93551696,
-		  93550168,
-		  0,
-		  93551632
-		],
-		"3.9.11.19": [
-		  93550296,
-		  93551632,
-		  93550104,
-		  0,
-		  93551568
-		],
-		"3.9.11.23": [
-		  93701208,
-		  93700984,
-		  93701016,
-		  0,
-		  93700920
-		],
-		"3.9.11.25": [
-		  93701080,
-		  93702416,
-		  93700888,
-		  0,
-		  93702352
-		],
-		"3.9.12.15": [
-		  93813544,
-		  93814880,
-		  93813352,
-		  0,
-		  93814816
-		],
-		"3.9.12.17": [
-		  93834984,
-		  93836320,
-		  93834792,
-		  0,
-		  93836256
-		],
-		"3.9.12.31": [
-		  94516904,
-		  94518240,
-		  94516712,
-		  0,
-		  94518176
-		],
-		"3.9.12.37": [
-		  94520808,
-		  94522144,
-		  94522146,
-		  0,
-		  94522080
-		],
-		"3.9.12.45": [
-		  94503784,
-		  94505120,
-		  94503592,
-		  0,
-		  94505056
-		],
-		"3.9.12.51": [
-		  94555176,
-		  94556512,
-		  94554984,
-		  0,
-		  94556448
-		],
-		"3.9.12.55": [
-		  94550988,
-		  94552544,
-		  94551016,
-		  0,
-		  94552480
-		]
-	  }`
-
-	json.Unmarshal([]byte(offset), &OffSetMap)
-
-}
-
 const (
 	V3ModuleName = "WeChatWin.dll"
 )
@@ -502,11 +36,6 @@ func (a *Account) GetUserInfoV3() error {
 		return fmt.Errorf("WeChatAccountNotOnline")
 	}
 
-	// 首先判断版本号是否已经收集
-	if _, ok := OffSetMap[a.FullVersion]; !ok {
-		logrus.Info("version no support to get userinfo")
-		return nil
-	}
 	// Find WeChatWin.dll module
 	weChatWinDllModel, isFound := FindModule(a.PID, V3ModuleName)
 	if !isFound {
@@ -520,47 +49,393 @@ func (a *Account) GetUserInfoV3() error {
 		return fmt.Errorf("OpenProcess fail")
 	}
 	defer windows.CloseHandle(handle)
-	// 获取微信昵称
-	nickName, err := GetWeChatData(handle, weChatWinDllModel.ModBaseAddr+uintptr(OffSetMap[a.FullVersion][0]), 100)
+
+	// 首先判断版本号是否已经收集，没有收集的版本通过特征码扫描来定位key，并推算出偏移
+	offsets, ok := OffSetMap[a.FullVersion]
+	if !ok {
+		logrus.Infof("version %s not in OffSetMap, try to find key by signature", a.FullVersion)
+		offsets, err = a.discoverOffsetsV3(handle, weChatWinDllModel)
+		if err != nil {
+			logrus.Info("discoverOffsetsV3 error: ", err)
+			return err
+		}
+	}
+
+	// 偏移为 0 表示推算不出（没有可参考的版本），跳过该字段
+	if offsets[0] != 0 {
+		// 获取微信昵称
+		nickName, err := GetWeChatData(handle, weChatWinDllModel.ModBaseAddr+uintptr(offsets[0]), 100)
+		if err != nil {
+			logrus.Info("get nickname error: ", err)
+			return err
+		}
+		a.setInfo(infoFieldNickname, &a.Nickname, nickName, InfoSourceMemory)
+		logrus.Infof("get nickname:%+v\n", nickName)
+	}
+	if offsets[1] != 0 {
+		// 获取微信账号
+		account, err := GetWeChatData(handle, weChatWinDllModel.ModBaseAddr+uintptr(offsets[1]), 100)
+		if err != nil {
+			logrus.Info("get account error: ", err)
+			return nil
+		}
+		a.setInfo(infoFieldWxAccount, &a.WxAccount, account, InfoSourceMemory)
+		logrus.Infof("get account:%+v\n", account)
+	}
+	if offsets[2] != 0 {
+		// 获取微信手机号
+		phone, err := GetWeChatData(handle, weChatWinDllModel.ModBaseAddr+uintptr(offsets[2]), 100)
+		if err != nil {
+			logrus.Info("get mobile error: ", err)
+			return err
+		}
+		a.setInfo(infoFieldPhone, &a.Phone, phone, InfoSourceMemory)
+		logrus.Infof("get phone:%+v\n", phone)
+	}
+	// 特征码扫描时已经拿到并校验过key了
+	if a.Key != "" {
+		return nil
+	}
+	// 获取微信密钥
+	keyBytes, err := GetWeChatKey(handle, weChatWinDllModel.ModBaseAddr+uintptr(offsets[4]), 8)
 
 	if err != nil {
-		logrus.Info("get nickname error: ", err)
+		logrus.Info("get key error: ", err)
 		return err
 	}
-	a.Nickname = nickName
-	logrus.Infof("get nickname:%+v\n", nickName)
-	// 获取微信账号
-	account, err := GetWeChatData(handle, weChatWinDllModel.ModBaseAddr+uintptr(OffSetMap[a.FullVersion][1]), 100)
-	if err != nil {
-		logrus.Info("get account error: ", err)
-		return nil
 
+	a.Key = strings.ToUpper(hex.EncodeToString(keyBytes))
+	logrus.Infof("get key:%+v\n", a.Key)
+	return nil
+}
+
+// v3 数据库（SQLCipher3）参数
+const (
+	v3KeyIter     = 64000
+	v3HmacSz      = 20
+	v3ReserveSz   = 48 // IV(16) + HMAC(20) + padding(12)
+	v3ScanBackLen = 2000
+)
+
+// v3 设备类型特征码，登录信息结构体里紧挨着账号、手机号
+var v3AnchorPatterns = [][]byte{
+	[]byte("iphone\x00"),
+	[]byte("android\x00"),
+	[]byte("ipad\x00"),
+}
+
+// verifyKeyV3 通过校验 v3 DB 第 1 页的 HMAC-SHA1，判断 key 是否正确。
+func verifyKeyV3(key, dbPage1 []byte) bool {
+	if len(key) != keySz || len(dbPage1) < pageSz {
+		return false
+	}
+	salt := dbPage1[:saltSz]
+	encKey := pbkdf2.Key(key, salt, v3KeyIter, keySz, sha1.New)
+	macSalt := make([]byte, saltSz)
+	for i, b := range salt {
+		macSalt[i] = b ^ 0x3A
 	}
+	macKey := pbkdf2.Key(encKey, macSalt, 2, keySz, sha1.New)
+	hm := hmac.New(sha1.New, macKey)
+	hm.Write(dbPage1[saltSz : pageSz-v3ReserveSz+ivSz])
+	_ = binary.Write(hm, binary.LittleEndian, uint32(1))
+	storedHmac := dbPage1[pageSz-v3ReserveSz+ivSz : pageSz-v3ReserveSz+ivSz+v3HmacSz]
+	return hmac.Equal(hm.Sum(nil), storedHmac)
+}
 
-	a.WxAccount = account
-	logrus.Infof("get account:%+v\n", account)
-	// 获取微信手机号
-	phone, err := GetWeChatData(handle, weChatWinDllModel.ModBaseAddr+uintptr(OffSetMap[a.FullVersion][2]), 100)
+// readDBPage1 读取数据库第 1 页
+func readDBPage1(dbPath string) ([]byte, error) {
+	f, err := os.Open(dbPath)
 	if err != nil {
-		logrus.Info("get mobile error: ", err)
-		return err
+		return nil, err
+	}
+	defer f.Close()
+	page1 := make([]byte, pageSz)
+	if _, err := io.ReadFull(f, page1); err != nil {
+		return nil, err
 	}
+	return page1, nil
+}
 
-	a.Phone = phone
-	logrus.Infof("get phone:%+v\n", phone)
-	// 获取微信密钥
-	keyBytes, err := GetWeChatKey(handle, weChatWinDllModel.ModBaseAddr+uintptr(OffSetMap[a.FullVersion][4]), 8)
+// DecryptDBV3 用 a.Key 解密 DataDir/Msg 下的数据库（SQLCipher3），输出到 decryptedDir/wxid/Msg，
+// 保持原来的目录结构；HMAC 校验不通过的数据库（未加密或不是这个 key）跳过
+func (a *Account) DecryptDBV3(decryptedDir string) error {
+	if a.Version == 4 {
+		return errors.New("not v3 account")
+	}
+	if a.DataDir == "" {
+		return errors.New("DataDir is empty")
+	}
+	if decryptedDir == "" {
+		return errors.New("decryptedDir is empty")
+	}
+	key, err := hex.DecodeString(a.Key)
+	if err != nil || len(key) != keySz {
+		return errors.New("Key is empty or invalid, call GetUserInfoV3() first or provide key")
+	}
 
+	dbFiles, _, err := collectDBFiles(filepath.Join(a.DataDir, "Msg"))
 	if err != nil {
-		logrus.Info("get key error: ", err)
 		return err
 	}
+	if len(dbFiles) == 0 {
+		return errors.New("no db files found")
+	}
 
-	a.Key = strings.ToUpper(hex.EncodeToString(keyBytes))
-	logrus.Infof("get key:%+v\n", a.Key)
+	outDir := filepath.Join(decryptedDir, a.Wxid, "Msg")
+	ok, skip, fail := 0, 0, 0
+	for _, df := range dbFiles {
+		if !verifyKeyV3(key, df.page1) {
+			skip++
+			continue
+		}
+		encKey := pbkdf2.Key(key, df.page1[:saltSz], v3KeyIter, keySz, sha1.New)
+		outPath := filepath.Join(outDir, filepath.FromSlash(df.rel))
+		logrus.Infof("[DECRYPT] %s", df.rel)
+		if err := decryptDatabase(df.path, outPath, encKey, v3ReserveSz); err != nil {
+			fail++
+			logrus.Infof("[DECRYPT] FAIL: %s (%v)", df.rel, err)
+			continue
+		}
+		ok++
+	}
+	logrus.Infof("[DECRYPT] 完成: ok=%d skip=%d fail=%d 输出目录=%s", ok, skip, fail, outDir)
+	if ok == 0 {
+		return errors.New("no db decrypted, wrong key?")
+	}
 	return nil
 }
 
+type moduleRegion struct {
+	base     uintptr
+	data     []byte
+	writable bool
+}
+
+// readModuleRegions 按内存区域读取模块中所有可读的部分（模块内可能有不可读的页，不能一次读完）
+func readModuleRegions(handle windows.Handle, module windows.ModuleEntry32) []moduleRegion {
+	var regions []moduleRegion
+	addr := module.ModBaseAddr
+	end := module.ModBaseAddr + uintptr(module.ModBaseSize)
+	for addr < end {
+		var mbi windows.MemoryBasicInformation
+		if err := windows.VirtualQueryEx(handle, addr, &mbi, unsafe.Sizeof(mbi)); err != nil {
+			break
+		}
+		regionEnd := mbi.BaseAddress + mbi.RegionSize
+		if regionEnd > end {
+			regionEnd = end
+		}
+		if _, ok := readableProtect[mbi.Protect]; ok && mbi.State == memCommit && regionEnd > addr {
+			buf := make([]byte, regionEnd-addr)
+			if err := windows.ReadProcessMemory(handle, addr, &buf[0], uintptr(len(buf)), nil); err == nil {
+				writable := mbi.Protect == windows.PAGE_READWRITE || mbi.Protect == windows.PAGE_WRITECOPY ||
+					mbi.Protect == windows.PAGE_EXECUTE_READWRITE || mbi.Protect == windows.PAGE_EXECUTE_WRITECOPY
+				regions = append(regions, moduleRegion{base: addr, data: buf, writable: writable})
+			}
+		}
+		if regionEnd <= addr {
+			break
+		}
+		addr = regionEnd
+	}
+	return regions
+}
+
+// findInRegions 在模块内存中查找所有出现 pattern 的地址
+func findInRegions(regions []moduleRegion, pattern []byte) []uintptr {
+	var addrs []uintptr
+	for _, r := range regions {
+		idx := 0
+		for {
+			i := bytes.Index(r.data[idx:], pattern)
+			if i < 0 {
+				break
+			}
+			addrs = append(addrs, r.base+uintptr(idx+i))
+			idx += i + 1
+		}
+	}
+	return addrs
+}
+
+// findKeyV3NearAnchors 从每个特征码地址往前，逐个把 8 字节当作 key 指针去读 32 字节，
+// 用 Misc.db 第 1 页的 HMAC 校验，返回 key 指针所在地址和 key
+func findKeyV3NearAnchors(handle windows.Handle, anchors []uintptr, page1 []byte) (uintptr, []byte, error) {
+	tried := make(map[uintptr]struct{})
+	for i := len(anchors) - 1; i >= 0; i-- {
+		anchor := anchors[i] &^ 7
+		if addr, key, ok := findKeyV3InRange(handle, anchor-v3ScanBackLen, anchor, page1, tried); ok {
+			return addr, key, nil
+		}
+	}
+	return 0, nil, fmt.Errorf("can't find key near anchors")
+}
+
+// findKeyV3InRange 从 hi 往 lo 按 8 字节对齐逐个尝试 key 指针，tried 记录已经校验过的 key 地址
+func findKeyV3InRange(handle windows.Handle, lo, hi uintptr, page1 []byte, tried map[uintptr]struct{}) (uintptr, []byte, bool) {
+	ptrBuf := make([]byte, 8)
+	for addr := hi &^ 7; addr > lo; addr -= 8 {
+		if err := windows.ReadProcessMemory(handle, addr, &ptrBuf[0], uintptr(len(ptrBuf)), nil); err != nil {
+			continue
+		}
+		keyAddr := uintptr(binary.LittleEndian.Uint64(ptrBuf))
+		if keyAddr < 0x10000 || keyAddr > 0x7FFFFFFFFFFF {
+			continue
+		}
+		if _, ok := tried[keyAddr]; ok {
+			continue
+		}
+		tried[keyAddr] = struct{}{}
+		key, err := GetWeChatKey(handle, addr, 8)
+		if err != nil || bytes.Count(key, []byte{0}) > 8 {
+			continue
+		}
+		if verifyKeyV3(key, page1) {
+			return addr, key, true
+		}
+	}
+	return 0, nil, false
+}
+
+// discoverOffsetsV3 针对 OffSetMap 中没有的版本，通过特征码扫描定位 key，并推算出新版本的偏移
+func (a *Account) discoverOffsetsV3(handle windows.Handle, module windows.ModuleEntry32) ([]int, error) {
+	page1, err := readDBPage1(filepath.Join(a.DataDir, "Msg", "Misc.db"))
+	if err != nil {
+		return nil, fmt.Errorf("read Misc.db failed: %v", err)
+	}
+
+	regions := readModuleRegions(handle, module)
+	var anchors []uintptr
+	for _, pat := range v3AnchorPatterns {
+		anchors = findInRegions(regions, pat)
+		if len(anchors) > 0 {
+			logrus.Infof("[*] use pattern: %q, hit count=%d", string(pat), len(anchors))
+			break
+		}
+	}
+	if len(anchors) == 0 {
+		return nil, fmt.Errorf("can't find device type pattern in %s", V3ModuleName)
+	}
+
+	keyPtrAddr, key, err := findKeyV3NearAnchors(handle, anchors, page1)
+	if err != nil {
+		return nil, err
+	}
+	a.Key = strings.ToUpper(hex.EncodeToString(key))
+	keyOffset := int(keyPtrAddr - module.ModBaseAddr)
+	logrus.Infof("get key by signature:%s, key offset:%d", a.Key, keyOffset)
+
+	offsets, err := SuggestOffsetsV3(a.FullVersion, keyOffset)
+	if err != nil {
+		// 只有 key，昵称等字段解密后从 MicroMsg.db 补全
+		logrus.Warnf("suggest offsets for %s failed: %v", a.FullVersion, err)
+		offsets = []int{0, 0, 0, 0, keyOffset}
+	}
+	// 推算出的手机号偏移不对时，在key附近重新找一下手机号
+	if phone, err := readPhoneV3(handle, module.ModBaseAddr, offsets[2]); err != nil || !isPhoneV3(phone) {
+		if phoneAddr, ok := findPhoneNear(regions, keyPtrAddr); ok {
+			offsets[2] = int(phoneAddr - module.ModBaseAddr)
+		}
+	}
+	logrus.Infof("suggested OffSetMap entry: \"%s\": [%d, %d, %d, %d, %d]",
+		a.FullVersion, offsets[0], offsets[1], offsets[2], offsets[3], offsets[4])
+	return offsets, nil
+}
+
+// readPhoneV3 读取手机号，偏移未知时返回错误
+func readPhoneV3(handle windows.Handle, base uintptr, offset int) (string, error) {
+	if offset == 0 {
+		return "", fmt.Errorf("unknown phone offset")
+	}
+	return GetWeChatData(handle, base+uintptr(offset), 100)
+}
+
+// SuggestOffsetsV3 以最接近的已知版本中各字段相对 key 的位置为准，根据新的 key 偏移推算其余偏移，
+// 参考版本中为 0 的字段仍为 0。OffSetMap 中没有可参考的版本时返回错误
+func SuggestOffsetsV3(version string, keyOffset int) ([]int, error) {
+	var nearest string
+	for v, offs := range OffSetMap {
+		if len(offs) != 5 || offs[4] == 0 {
+			continue
+		}
+		if nearest == "" || versionDistance(v, version) < versionDistance(nearest, version) {
+			nearest = v
+		}
+	}
+	if nearest == "" {
+		return nil, fmt.Errorf("no reference version with key offset in OffSetMap")
+	}
+	suggested := []int{0, 0, 0, 0, keyOffset}
+	ref := OffSetMap[nearest]
+	for i := 0; i < 4; i++ {
+		if ref[i] == 0 {
+			continue
+		}
+		suggested[i] = keyOffset + ref[i] - ref[4]
+	}
+	return suggested, nil
+}
+
+// versionDistance 用于挑选最接近的版本：越新的同系列版本越接近
+func versionDistance(a, b string) int {
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+	dist := 0
+	for i := 0; i < 4; i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		d := x - y
+		if d < 0 {
+			d = -d
+		}
+		dist = dist*1000 + d
+	}
+	return dist
+}
+
+var v3PhoneRe = regexp.MustCompile(`^(?:\+?[1-9]\d{6,14})$`)
+
+func isPhoneV3(s string) bool {
+	return v3PhoneRe.MatchString(s)
+}
+
+// findPhoneNear 在 key 指针附近查找以 \0 结尾的手机号字符串
+func findPhoneNear(regions []moduleRegion, center uintptr) (uintptr, bool) {
+	for _, r := range regions {
+		if center < r.base || center >= r.base+uintptr(len(r.data)) {
+			continue
+		}
+		off := int(center - r.base)
+		lo := off - v3ScanBackLen
+		if lo < 0 {
+			lo = 0
+		}
+		hi := off + v3ScanBackLen
+		if hi > len(r.data) {
+			hi = len(r.data)
+		}
+		for i := lo; i < hi; i += 4 {
+			if i > 0 && r.data[i-1] != 0 {
+				continue
+			}
+			end := bytes.IndexByte(r.data[i:hi], 0)
+			if end <= 0 {
+				continue
+			}
+			if isPhoneV3(string(r.data[i : i+end])) {
+				return r.base + uintptr(i), true
+			}
+		}
+	}
+	return 0, false
+}
+
 // 从指定内存位置，读取key
 func GetWeChatKey(processHandler windows.Handle, address uintptr, addressLen int) ([]byte, error) {
 	array := make([]byte, addressLen)
@@ -0,0 +1,146 @@
+package wexin
+
+import (
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/windows"
+)
+
+// 校准时三个字符串之间允许的最大距离
+const v3CalibrateSpan = 0x1000
+
+// CalibrateOffsetsV3 针对未收集的 v3 版本，用界面上看到的昵称、账号、手机号校准偏移：
+// 在 WeChatWin.dll 的可写数据中找到这三个字符串，再在附近找到能通过 Misc.db 校验的 key 指针，
+// 得到 [昵称, 账号, 手机号, 邮箱(0), key指针] 五个偏移，并写入用户偏移文件
+func (a *Account) CalibrateOffsetsV3(nickname, account, phone string) ([]int, error) {
+	if a.Version != 3 {
+		return nil, fmt.Errorf("not v3 account")
+	}
+	if a.Status != StatusOnline {
+		return nil, fmt.Errorf("WeChatAccountNotOnline")
+	}
+	if nickname == "" || account == "" || phone == "" {
+		return nil, fmt.Errorf("nickname, account and phone are required")
+	}
+
+	module, isFound := FindModule(a.PID, V3ModuleName)
+	if !isFound {
+		return nil, fmt.Errorf("FindModule cant find WeChatWin.dll")
+	}
+	handle, err := windows.OpenProcess(windows.PROCESS_QUERY_INFORMATION|windows.PROCESS_VM_READ, false, a.PID)
+	if err != nil {
+		return nil, fmt.Errorf("OpenProcess fail")
+	}
+	defer windows.CloseHandle(handle)
+
+	page1, err := readDBPage1(filepath.Join(a.DataDir, "Msg", "Misc.db"))
+	if err != nil {
+		return nil, fmt.Errorf("read Misc.db failed: %v", err)
+	}
+
+	var writable []moduleRegion
+	for _, r := range readModuleRegions(handle, module) {
+		if r.writable {
+			writable = append(writable, r)
+		}
+	}
+	nickAddrs := findStringInRegions(writable, nickname)
+	accountAddrs := findStringInRegions(writable, account)
+	phoneAddrs := findStringInRegions(writable, phone)
+	logrus.Infof("[*] calibrate hits: nickname=%d account=%d phone=%d", len(nickAddrs), len(accountAddrs), len(phoneAddrs))
+	if len(nickAddrs) == 0 || len(accountAddrs) == 0 || len(phoneAddrs) == 0 {
+		return nil, fmt.Errorf("can't find nickname/account/phone in %s", V3ModuleName)
+	}
+
+	// 三个字符串可能各有多处，挑选彼此距离最近的一组，再在这组附近找 key 指针
+	tried := make(map[uintptr]struct{})
+	for _, group := range closestGroups(accountAddrs, nickAddrs, phoneAddrs) {
+		lo, hi := group[0], group[0]
+		for _, addr := range group[1:] {
+			lo = min(lo, addr)
+			hi = max(hi, addr)
+		}
+		keyPtrAddr, key, ok := findKeyV3InRange(handle, lo-v3ScanBackLen, hi+v3ScanBackLen, page1, tried)
+		if !ok {
+			continue
+		}
+		base := module.ModBaseAddr
+		offsets := []int{int(group[1] - base), int(group[0] - base), int(group[2] - base), 0, int(keyPtrAddr - base)}
+		if err := ValidateOffsets(a.FullVersion, offsets); err != nil {
+			return nil, err
+		}
+		a.Nickname = nickname
+		a.WxAccount = account
+		a.Phone = phone
+		a.Key = strings.ToUpper(hex.EncodeToString(key))
+		logrus.Infof("calibrated OffSetMap entry: \"%s\": %v", a.FullVersion, offsets)
+		if err := AddUserOffsets(a.FullVersion, offsets); err != nil {
+			return offsets, err
+		}
+		return offsets, nil
+	}
+	return nil, fmt.Errorf("can't find key pointer near nickname/account/phone")
+}
+
+// findStringInRegions 查找以 \0 结尾、并且前面也是 \0 的完整字符串
+func findStringInRegions(regions []moduleRegion, s string) []uintptr {
+	hits := findInRegions(regions, append([]byte(s), 0))
+	var addrs []uintptr
+	for _, addr := range hits {
+		for _, r := range regions {
+			if addr < r.base || addr >= r.base+uintptr(len(r.data)) {
+				continue
+			}
+			off := int(addr - r.base)
+			if off == 0 || r.data[off-1] == 0 {
+				addrs = append(addrs, addr)
+			}
+			break
+		}
+	}
+	if len(addrs) == 0 {
+		return hits
+	}
+	return addrs
+}
+
+// closestGroups 以账号为中心，找出距离不超过 v3CalibrateSpan 的 [账号, 昵称, 手机号] 组合，按跨度从小到大排序
+func closestGroups(accountAddrs, nickAddrs, phoneAddrs []uintptr) [][3]uintptr {
+	nearest := func(center uintptr, addrs []uintptr) (uintptr, bool) {
+		var best uintptr
+		bestDist := uintptr(v3CalibrateSpan)
+		found := false
+		for _, addr := range addrs {
+			dist := max(addr, center) - min(addr, center)
+			if dist <= bestDist {
+				best, bestDist, found = addr, dist, true
+			}
+		}
+		return best, found
+	}
+	span := func(g [3]uintptr) uintptr {
+		return max(g[0], g[1], g[2]) - min(g[0], g[1], g[2])
+	}
+
+	var groups [][3]uintptr
+	for _, acc := range accountAddrs {
+		nick, ok := nearest(acc, nickAddrs)
+		if !ok {
+			continue
+		}
+		phone, ok := nearest(acc, phoneAddrs)
+		if !ok {
+			continue
+		}
+		groups = append(groups, [3]uintptr{acc, nick, phone})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return span(groups[i]) < span(groups[j])
+	})
+	return groups
+}
@@ -39,18 +39,6 @@ const (
 	PAGE_EXECUTE_READWRITE    = 0x40
 )
 
-// 通用特征码：设备类型字符串
-var devicePatterns = [][]byte{
-	[]byte("android\x00"),
-	[]byte("iphone\x00"),
-	[]byte("ipad\x00"),
-}
-
-// 按顺序匹配：account、nickname、phone
-var userBlockRe = regexp.MustCompile(
-	`([\x20-\x7e]+)\x00+[\x00-\xff]{16}([\x20-\x7e]+)\x00+[\x00-\xff]{16}((?:\+?[1-9]\d{6,14})|(?:1[3-9]\d{9}))\x00`,
-)
-
 var phoneLikeRe = regexp.MustCompile(`(?:\+?[1-9]\d{6,14})|(?:1[3-9]\d{9})`)
 
 type userInfo struct {
@@ -67,59 +55,39 @@ func extractPrintableASCII(base uintptr, b []byte, minLen int, maxCount int) []s
 		maxCount = 20
 	}
 	var out []string
-
-	start := -1
-	for i := 0; i <= len(b); i++ {
-		isPrintable := false
-		if i < len(b) {
-			c := b[i]
-			isPrintable = c >= 0x20 && c <= 0x7e
-		}
-
-		if isPrintable {
-			if start == -1 {
-				start = i
-			}
-			continue
-		}
-
-		if start != -1 {
-			runLen := i - start
-			if runLen >= minLen {
-				addr := base + uintptr(start)
-				s := string(b[start:i])
-				out = append(out, fmt.Sprintf("0x%016X (+0x%X) len=%d %q", addr, start, runLen, s))
-				if len(out) >= maxCount {
-					return out
-				}
-			}
-			start = -1
+	for _, run := range printableRuns(b, minLen) {
+		start, runLen := run[0], run[1]-run[0]
+		addr := base + uintptr(start)
+		s := string(b[run[0]:run[1]])
+		out = append(out, fmt.Sprintf("0x%016X (+0x%X) len=%d %q", addr, start, runLen, s))
+		if len(out) >= maxCount {
+			return out
 		}
 	}
 	return out
 }
 
-func readUserWindow(handle windows.Handle, deviceAddr uintptr) (uintptr, []byte, error) {
-	start := deviceAddr - 0x280
-	buf := make([]byte, 0x290)
+func readUserWindow(handle windows.Handle, deviceAddr uintptr, rule *UserInfoRuleV4) (uintptr, []byte, error) {
+	start := deviceAddr - uintptr(rule.WindowBefore)
+	buf := make([]byte, rule.WindowBefore+rule.WindowAfter)
 	if err := windows.ReadProcessMemory(handle, start, &buf[0], uintptr(len(buf)), nil); err != nil {
 		return 0, nil, err
 	}
 	return start, buf, nil
 }
 
-func parseUserBlock(handle windows.Handle, deviceAddr uintptr) *userInfo {
-	_, buf, err := readUserWindow(handle, deviceAddr)
+func parseUserBlock(handle windows.Handle, deviceAddr uintptr, rule *UserInfoRuleV4) *userInfo {
+	_, buf, err := readUserWindow(handle, deviceAddr, rule)
 	if err != nil {
 		return nil
 	}
-	loc := userBlockRe.FindSubmatch(buf)
-	if loc == nil || len(loc) != 4 {
+	loc := rule.userBlockRe.FindSubmatch(buf)
+	if loc == nil || len(loc) != len(rule.Fields)+1 {
 		return nil
 	}
-	account := strings.TrimSpace(string(loc[1]))
-	nickname := strings.TrimSpace(string(loc[2]))
-	phone := strings.TrimSpace(string(loc[3]))
+	account := strings.TrimSpace(string(loc[rule.fieldIndex["account"]]))
+	nickname := strings.TrimSpace(string(loc[rule.fieldIndex["nickname"]]))
+	phone := strings.TrimSpace(string(loc[rule.fieldIndex["phone"]]))
 	if account == "" || nickname == "" || phone == "" {
 		return nil
 	}
@@ -177,8 +145,24 @@ func patternScanAll(handle windows.Handle, pattern []byte) []uintptr {
 }
 
 // 尝试从内存中找到手机号、账号等信息
-// 内存布局：account账号、nickname昵称、手机号（按顺序）
+// 内存布局：account账号、nickname昵称、手机号（按顺序），具体特征见 rules_v4.json
 func (a *Account) GetUserInfoV4() error {
+	return a.GetUserInfoV4WithRules("")
+}
+
+// GetUserInfoV4WithRules 使用指定的规则文件解析用户信息，结构变化时不用重新编译，改规则文件即可
+// rulesFile 为空时依次使用环境变量、用户文件、内置规则
+func (a *Account) GetUserInfoV4WithRules(rulesFile string) error {
+	rules, err := LoadRulesV4(rulesFile)
+	if err != nil {
+		return fmt.Errorf("load v4 rules failed: %v", err)
+	}
+	rule, err := rules.Match(a.FullVersion)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("[*] use v4 rule: %s", rule.Name)
+
 	handle, err := windows.OpenProcess(PROCESS_QUERY_INFORMATION|PROCESS_VM_READ, false, uint32(a.PID))
 	if err != nil {
 		return fmt.Errorf("can't open process: %v", err)
@@ -187,7 +171,8 @@ func (a *Account) GetUserInfoV4() error {
 
 	var addrs []uintptr
 	var usedPat []byte
-	for _, pat := range devicePatterns {
+	for _, p := range rule.DevicePatterns {
+		pat := []byte(p)
 		addrs = patternScanAll(handle, pat)
 		if len(addrs) > 0 {
 			usedPat = pat
@@ -204,10 +189,10 @@ func (a *Account) GetUserInfoV4() error {
 
 	dumped := 0
 	for _, addr := range addrs {
-		info := parseUserBlock(handle, addr)
+		info := parseUserBlock(handle, addr, rule)
 		if info == nil {
 			if dumped < 30 {
-				start, win, rerr := readUserWindow(handle, addr)
+				start, win, rerr := readUserWindow(handle, addr, rule)
 				if rerr != nil {
 					logrus.Infof("[dump] deviceAddr=0x%016X read window failed: %v", addr, rerr)
 				} else {
@@ -228,9 +213,9 @@ func (a *Account) GetUserInfoV4() error {
 		if info.Phones == "" {
 			continue
 		}
-		a.WxAccount = info.Account
-		a.Nickname = info.Nickname
-		a.Phone = info.Phones
+		a.setInfo(infoFieldWxAccount, &a.WxAccount, info.Account, InfoSourceMemory)
+		a.setInfo(infoFieldNickname, &a.Nickname, info.Nickname, InfoSourceMemory)
+		a.setInfo(infoFieldPhone, &a.Phone, info.Phones, InfoSourceMemory)
 		logrus.Infof("get userinfo: account=%s nickname=%s phones=%s\n",
 			a.WxAccount, a.Nickname, a.Phone)
 		return nil
@@ -342,48 +327,49 @@ func collectDBFiles(dbDir string) ([]dbFile, map[string][]string, error) {
 	return list, saltToDBs, nil
 }
 
-// decryptPageInto 解密单页（SQLCipher4 / WCDB），写入 out（4096 字节）。
+// decryptPageInto 解密单页，写入 out（4096 字节）。reserve 为页尾保留区长度：
+// SQLCipher4 / WCDB（v4）为 reserveSz，SQLCipher3（v3）为 v3ReserveSz。
 // 注意：out 会被完全覆盖；pageData 必须是 4096 字节。
-func decryptPageInto(block cipher.Block, pageData []byte, pgno int, out []byte) error {
+func decryptPageInto(block cipher.Block, pageData []byte, pgno int, out []byte, reserve int) error {
 	if len(pageData) != pageSz {
 		return errors.New("page size mismatch")
 	}
 	if len(out) != pageSz {
 		return errors.New("out page size mismatch")
 	}
-	ivOff := pageSz - reserveSz
+	ivOff := pageSz - reserve
 	iv := pageData[ivOff : ivOff+ivSz]
 
 	if pgno == 1 {
 		// page1: salt(16) 明文保留，后面的 encrypted 才是 AES-CBC 密文
-		encrypted := pageData[saltSz : pageSz-reserveSz]
+		encrypted := pageData[saltSz : pageSz-reserve]
 		if len(encrypted)%aes.BlockSize != 0 {
 			return errors.New("encrypted page1 not multiple of block size")
 		}
 		cbc := cipher.NewCBCDecrypter(block, iv)
 		copy(out, pageData) // 先覆盖，避免残留
 		copy(out[:len(sqliteHeader)], sqliteHeader)
-		cbc.CryptBlocks(out[len(sqliteHeader):pageSz-reserveSz], encrypted)
-		for i := pageSz - reserveSz; i < pageSz; i++ {
+		cbc.CryptBlocks(out[len(sqliteHeader):pageSz-reserve], encrypted)
+		for i := pageSz - reserve; i < pageSz; i++ {
 			out[i] = 0
 		}
 		return nil
 	}
 
-	encrypted := pageData[:pageSz-reserveSz]
+	encrypted := pageData[:pageSz-reserve]
 	if len(encrypted)%aes.BlockSize != 0 {
 		return errors.New("encrypted page not multiple of block size")
 	}
 	cbc := cipher.NewCBCDecrypter(block, iv)
-	cbc.CryptBlocks(out[:pageSz-reserveSz], encrypted)
-	for i := pageSz - reserveSz; i < pageSz; i++ {
+	cbc.CryptBlocks(out[:pageSz-reserve], encrypted)
+	for i := pageSz - reserve; i < pageSz; i++ {
 		out[i] = 0
 	}
 	return nil
 }
 
-// decryptDatabase 解密整个 DB 文件到 outPath。
-func decryptDatabase(dbPath, outPath string, encKey []byte) error {
+// decryptDatabase 解密整个 DB 文件到 outPath，reserve 见 decryptPageInto。
+func decryptDatabase(dbPath, outPath string, encKey []byte, reserve int) error {
 	st, err := os.Stat(dbPath)
 	if err != nil {
 		return err
@@ -440,7 +426,7 @@ func decryptDatabase(dbPath, outPath string, encKey []byte) error {
 			}
 		}
 
-		if err := decryptPageInto(block, page, pgno, outPage); err != nil {
+		if err := decryptPageInto(block, page, pgno, outPage, reserve); err != nil {
 			return err
 		}
 		if _, err := bw.Write(outPage); err != nil {
@@ -713,6 +699,42 @@ func enumRegions(h windows.Handle) []struct {
 	return regs
 }
 
+// ImageAesKeyCandidatesFromProcess 扫描进程可读内存，提取候选 key
+func ImageAesKeyCandidatesFromProcess(pid uint32) ([][]byte, error) {
+	h, err := windows.OpenProcess(windows.PROCESS_VM_READ|windows.PROCESS_QUERY_INFORMATION, false, pid)
+	if err != nil {
+		return nil, fmt.Errorf("can't open process: %v", err)
+	}
+	defer windows.CloseHandle(h)
+
+	seen := make(map[string]struct{})
+	var candidates [][]byte
+	for _, reg := range enumRegions(h) {
+		data := readMem(h, reg.base, reg.sz)
+		if len(data) == 0 {
+			continue
+		}
+		candidates = append(candidates, ImageAesKeyCandidates(data, seen)...)
+	}
+	logrus.Infof("[*] PID=%d 提取到 %d 个候选图片 key", pid, len(candidates))
+	return candidates, nil
+}
+
+// GetImageAesKeyV4 从在线进程的内存中提取候选 key 并校验
+func (a *Account) GetImageAesKeyV4() error {
+	if a.ImageAesKey != "" {
+		return nil
+	}
+	if a.Status != StatusOnline {
+		return fmt.Errorf("WeChatAccountNotOnline")
+	}
+	candidates, err := ImageAesKeyCandidatesFromProcess(a.PID)
+	if err != nil {
+		return err
+	}
+	return a.BruteForceImageAesKeyV4("", candidates)
+}
+
 func (a *Account) GetKeyV4() error {
 
 	// 如果已经有密钥，直接返回
@@ -874,7 +896,7 @@ func (a *Account) DecryptDBV4(decryptedDir string) error {
 
 		outPath := filepath.Join(outDir, filepath.FromSlash(df.rel))
 		logrus.Infof("[DECRYPT] %s", df.rel)
-		if err := decryptDatabase(df.path, outPath, encKey); err != nil {
+		if err := decryptDatabase(df.path, outPath, encKey, reserveSz); err != nil {
 			fail++
 			logrus.Infof("[DECRYPT] FAIL: %s (%v)", df.rel, err)
 			continue
@@ -0,0 +1,271 @@
+package wexin
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/saucer-man/wxdump/pkg/silk"
+)
+
+const VoiceIndexFile = "index.json"
+
+// Voice 一条语音消息：SILK 数据以及它所属的消息和会话
+type Voice struct {
+	MsgSvrID   int64
+	LocalID    int64
+	Talker     string
+	CreateTime int64
+	Data       []byte
+	Shard      string
+}
+
+// VoiceIndexEntry 语音导出的映射记录，路径都是相对 outDir 的路径
+type VoiceIndexEntry struct {
+	MsgSvrID   int64  `json:"msg_svr_id"`
+	LocalID    int64  `json:"local_id,omitempty"`
+	Talker     string `json:"talker"`
+	CreateTime int64  `json:"create_time"`
+	DurationMs int    `json:"duration_ms"`
+	Silk       string `json:"silk"`
+	Wav        string `json:"wav,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// LoadVoices 读取解密目录中的所有语音
+// v3：Msg/Multi/MediaMSG*.db 的 Media 表（Reserved0 为 MsgSvrID），通过 MSG*.db 关联会话
+// v4：message/media_*.db 的 VoiceInfo 表，chat_name_id 对应同库的 Name2Id
+func (a *Account) LoadVoices(decryptedDir string) ([]*Voice, error) {
+	if a.Version == 4 {
+		return a.loadVoicesV4(decryptedDir)
+	}
+	return a.loadVoicesV3(decryptedDir)
+}
+
+type voiceMsgRef struct {
+	talker     string
+	localID    int64
+	createTime int64
+}
+
+// voiceDBs 语音所在的库：v3 MediaMSG*.db，v4 media_*.db
+func (a *Account) voiceDBs(decryptedDir string) ([]string, error) {
+	if a.Version == 4 {
+		if paths := a.decryptedDBGlob(decryptedDir, "message/media_*.db"); len(paths) > 0 {
+			return paths, nil
+		}
+		return nil, fmt.Errorf("media_*.db not found in %s", filepath.Join(decryptedDir, a.Wxid))
+	}
+	if paths := a.decryptedDBGlob(decryptedDir, "Msg/Multi/MediaMSG*.db", "Multi/MediaMSG*.db"); len(paths) > 0 {
+		return paths, nil
+	}
+	return nil, fmt.Errorf("MediaMSG*.db not found in %s", filepath.Join(decryptedDir, a.Wxid))
+}
+
+func (a *Account) loadVoicesV3(decryptedDir string) ([]*Voice, error) {
+	mediaDBs, err := a.voiceDBs(decryptedDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// MsgSvrID => 会话
+	refs := make(map[int64]voiceMsgRef)
+	for _, path := range a.decryptedDBGlob(decryptedDir, "Msg/Multi/MSG*.db", "Multi/MSG*.db") {
+		err := querySQLite(path, `SELECT MsgSvrID, StrTalker, localId, CreateTime FROM MSG WHERE Type = 34`, func(rows *sql.Rows) error {
+			var svrID, localID, createTime int64
+			var talker string
+			if err := rows.Scan(&svrID, &talker, &localID, &createTime); err != nil {
+				return err
+			}
+			refs[svrID] = voiceMsgRef{talker: talker, localID: localID, createTime: createTime}
+			return nil
+		})
+		if err != nil {
+			logrus.Infof("[VOICE] read %s failed: %v", path, err)
+		}
+	}
+
+	var voices []*Voice
+	for _, path := range mediaDBs {
+		err := querySQLite(path, `SELECT Reserved0, Buf FROM Media`, func(rows *sql.Rows) error {
+			v := &Voice{Shard: filepath.Base(path)}
+			if err := rows.Scan(&v.MsgSvrID, &v.Data); err != nil {
+				return err
+			}
+			if ref, ok := refs[v.MsgSvrID]; ok {
+				v.Talker, v.LocalID, v.CreateTime = ref.talker, ref.localID, ref.createTime
+			}
+			voices = append(voices, v)
+			return nil
+		})
+		if err != nil {
+			return voices, fmt.Errorf("read %s failed: %v", path, err)
+		}
+	}
+	return voices, nil
+}
+
+func (a *Account) loadVoicesV4(decryptedDir string) ([]*Voice, error) {
+	mediaDBs, err := a.voiceDBs(decryptedDir)
+	if err != nil {
+		return nil, err
+	}
+	var voices []*Voice
+	for _, path := range mediaDBs {
+		query := `SELECT v.svr_id, v.local_id, IFNULL(n.user_name, ''), v.create_time, v.voice_data
+			FROM VoiceInfo v LEFT JOIN Name2Id n ON n.rowid = v.chat_name_id`
+		err := querySQLite(path, query, func(rows *sql.Rows) error {
+			v := &Voice{Shard: filepath.Base(path)}
+			if err := rows.Scan(&v.MsgSvrID, &v.LocalID, &v.Talker, &v.CreateTime, &v.Data); err != nil {
+				return err
+			}
+			voices = append(voices, v)
+			return nil
+		})
+		if err != nil {
+			return voices, fmt.Errorf("read %s failed: %v", path, err)
+		}
+	}
+	return voices, nil
+}
+
+// VoiceIndex MsgSvrID 到语音所在库和行的索引，只在取用时读取语音数据，不会把整个账号的语音留在内存中
+type VoiceIndex struct {
+	version int
+	rows    map[int64]voiceRow
+}
+
+type voiceRow struct {
+	path  string
+	rowid int64
+}
+
+// LoadVoiceIndex 读取所有语音的 MsgSvrID 和所在行，不读取语音数据
+func (a *Account) LoadVoiceIndex(decryptedDir string) (*VoiceIndex, error) {
+	mediaDBs, err := a.voiceDBs(decryptedDir)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT Reserved0, rowid FROM Media`
+	if a.Version == 4 {
+		query = `SELECT svr_id, rowid FROM VoiceInfo`
+	}
+	idx := &VoiceIndex{version: a.Version, rows: make(map[int64]voiceRow)}
+	for _, path := range mediaDBs {
+		err := querySQLite(path, query, func(rows *sql.Rows) error {
+			var svrID, rowid int64
+			if err := rows.Scan(&svrID, &rowid); err != nil {
+				return err
+			}
+			idx.rows[svrID] = voiceRow{path: path, rowid: rowid}
+			return nil
+		})
+		if err != nil {
+			return idx, fmt.Errorf("read %s failed: %v", path, err)
+		}
+	}
+	return idx, nil
+}
+
+// Len 索引中的语音条数
+func (idx *VoiceIndex) Len() int {
+	return len(idx.rows)
+}
+
+// Data 读取一条语音的 SILK 数据
+func (idx *VoiceIndex) Data(svrID int64) ([]byte, error) {
+	row, ok := idx.rows[svrID]
+	if !ok {
+		return nil, fmt.Errorf("voice %d not found", svrID)
+	}
+	query := `SELECT Buf FROM Media WHERE rowid = ?`
+	if idx.version == 4 {
+		query = `SELECT voice_data FROM VoiceInfo WHERE rowid = ?`
+	}
+	var data []byte
+	err := querySQLite(row.path, query, func(rows *sql.Rows) error {
+		return rows.Scan(&data)
+	}, row.rowid)
+	if err != nil {
+		return nil, fmt.Errorf("read voice %d from %s failed: %v", svrID, filepath.Base(row.path), err)
+	}
+	return data, nil
+}
+
+// ExportVoices 把语音导出到 outDir/<talker>/<create_time>_<svr_id>.silk，
+// 同时解码输出 24kHz 的 WAV，并在 outDir 下写入 index.json。
+// 只要 SILK 写入成功就算导出成功，解码失败的原因记录在 index.json 的 error 字段
+func (a *Account) ExportVoices(decryptedDir, outDir string) ([]VoiceIndexEntry, error) {
+	voices, err := a.LoadVoices(decryptedDir)
+	if err != nil {
+		return nil, err
+	}
+	var index []VoiceIndexEntry
+	ok, fail := 0, 0
+	for _, v := range voices {
+		entry := VoiceIndexEntry{MsgSvrID: v.MsgSvrID, LocalID: v.LocalID, Talker: v.Talker, CreateTime: v.CreateTime}
+		if err := exportVoice(v, outDir, &entry); err != nil {
+			fail++
+			entry.Error = err.Error()
+		} else {
+			ok++
+		}
+		index = append(index, entry)
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return index, err
+	}
+	f, err := os.Create(filepath.Join(outDir, VoiceIndexFile))
+	if err != nil {
+		return index, err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(index); err != nil {
+		return index, err
+	}
+	logrus.Infof("[VOICE] 完成: ok=%d fail=%d 输出目录=%s", ok, fail, outDir)
+	return index, nil
+}
+
+func exportVoice(v *Voice, outDir string, entry *VoiceIndexEntry) error {
+	talker := v.Talker
+	if talker == "" {
+		talker = "unknown"
+	}
+	base := filepath.Join(talker, fmt.Sprintf("%d_%d", v.CreateTime, v.MsgSvrID))
+	if err := os.MkdirAll(filepath.Join(outDir, talker), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(outDir, base+".silk"), v.Data, 0644); err != nil {
+		return err
+	}
+	entry.Silk = filepath.ToSlash(base + ".silk")
+
+	// SILK 已经导出，后面时长和 WAV 的问题只记录在索引里，不算导出失败
+	duration, err := silk.Duration(v.Data)
+	entry.DurationMs = duration
+	if err != nil {
+		entry.Error = err.Error()
+		return nil
+	}
+	wav, err := silk.ToWAV(v.Data, silk.SampleRate)
+	if err != nil {
+		logrus.Debugf("[VOICE] %d 解码失败: %v", v.MsgSvrID, err)
+		entry.Error = err.Error()
+		return nil
+	}
+	if err := os.WriteFile(filepath.Join(outDir, base+".wav"), wav, 0644); err != nil {
+		entry.Error = err.Error()
+		return nil
+	}
+	entry.Wav = filepath.ToSlash(base + ".wav")
+	return nil
+}
@@ -100,6 +100,10 @@ func GetWexinList() []*Account {
 			if err != nil {
 				logrus.Info("account.GetKeyV4 error:", err)
 			}
+			err = a.GetImageAesKeyV4()
+			if err != nil {
+				logrus.Info("account.GetImageAesKeyV4 error:", err)
+			}
 		}
 		accounts = append(accounts, a)
 
@@ -0,0 +1,157 @@
+package wexin
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	zstdMagic     = []byte{0x28, 0xB5, 0x2F, 0xFD}
+	zstdDictMagic = []byte{0x37, 0xA4, 0x30, 0xEC}
+)
+
+// zstdMaxMemory 单条消息解压后的上限，防止构造的帧声明超大窗口耗尽内存
+const zstdMaxMemory = 64 << 20
+
+// IsZstd 是否为 zstd 帧
+func IsZstd(data []byte) bool {
+	return bytes.HasPrefix(data, zstdMagic)
+}
+
+// ContentDecoder 解压 v4 的 message_content、compress_content，WCDB 压缩时可能带字典
+type ContentDecoder struct {
+	dec   *zstd.Decoder
+	dicts int
+}
+
+// NewContentDecoder dicts 为 zstd 格式的字典（37 A4 30 EC 开头），按帧头中的字典 ID 匹配；
+// 无法解析的字典和重复的字典 ID 会被跳过，不影响其他字典
+func NewContentDecoder(dicts ...[]byte) (*ContentDecoder, error) {
+	valid := validZstdDicts(dicts)
+	opts := []zstd.DOption{zstd.WithDecoderConcurrency(0), zstd.WithDecoderMaxMemory(zstdMaxMemory)}
+	if len(valid) > 0 {
+		opts = append(opts, zstd.WithDecoderDicts(valid...))
+	}
+	dec, err := zstd.NewReader(nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &ContentDecoder{dec: dec, dicts: len(valid)}, nil
+}
+
+// validZstdDicts 逐个试加载字典，同一个字典 ID 只保留第一个
+func validZstdDicts(dicts [][]byte) [][]byte {
+	var valid [][]byte
+	seen := make(map[uint32]bool)
+	for i, dict := range dicts {
+		if len(dict) < 8 || !bytes.HasPrefix(dict, zstdDictMagic) {
+			logrus.Debugf("skip zstd dict #%d: bad header", i)
+			continue
+		}
+		id := binary.LittleEndian.Uint32(dict[4:8])
+		if seen[id] {
+			logrus.Debugf("skip zstd dict #%d: duplicate id %d", i, id)
+			continue
+		}
+		dec, err := zstd.NewReader(nil, zstd.WithDecoderDicts(dict))
+		if err != nil {
+			logrus.Infof("skip zstd dict #%d (id %d): %v", i, id, err)
+			continue
+		}
+		dec.Close()
+		seen[id] = true
+		valid = append(valid, dict)
+	}
+	return valid
+}
+
+// Decompress 解压 zstd 帧，不是 zstd 时原样返回
+func (d *ContentDecoder) Decompress(data []byte) ([]byte, error) {
+	if !IsZstd(data) {
+		return data, nil
+	}
+	out, err := d.dec.DecodeAll(data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("zstd decompress failed: %v", err)
+	}
+	return out, nil
+}
+
+// DecodeString 解压并转换成 UTF-8 文本，解压失败时返回空串和错误
+func (d *ContentDecoder) DecodeString(data []byte) (string, error) {
+	out, err := d.Decompress(data)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToValidUTF8(string(out), "�"), nil
+}
+
+// Close 释放解码器
+func (d *ContentDecoder) Close() {
+	d.dec.Close()
+}
+
+// loadZstdDicts 读取库中表名含 dict 的表里所有 zstd 字典
+func loadZstdDicts(db *sql.DB) ([][]byte, error) {
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name LIKE '%dict%'`)
+	if err != nil {
+		return nil, err
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+
+	var dicts [][]byte
+	for _, table := range tables {
+		found, err := scanZstdDicts(db, table)
+		if err != nil {
+			logrus.Infof("read dict table %s failed: %v", table, err)
+			continue
+		}
+		dicts = append(dicts, found...)
+	}
+	return dicts, nil
+}
+
+// scanZstdDicts 字典表的结构没有固定格式，逐列查找字典头
+func scanZstdDicts(db *sql.DB, table string) ([][]byte, error) {
+	rows, err := db.Query(fmt.Sprintf(`SELECT * FROM "%s"`, strings.ReplaceAll(table, `"`, `""`)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var dicts [][]byte
+	for rows.Next() {
+		values := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		for _, v := range values {
+			if b, ok := v.([]byte); ok && bytes.HasPrefix(b, zstdDictMagic) {
+				dicts = append(dicts, bytes.Clone(b))
+			}
+		}
+	}
+	return dicts, rows.Err()
+}
@@ -0,0 +1,103 @@
+package wexin
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// buildZstdDict 用相似的 appmsg XML 训练一个字典
+func buildZstdDict(t *testing.T, id uint32, word string) []byte {
+	t.Helper()
+	var hist [][]byte
+	for i := 0; i < 200; i++ {
+		hist = append(hist, []byte(fmt.Sprintf(`<msg><appmsg appid="" sdkver="0"><title>%s %d</title><des>desc</des><type>%d</type></appmsg></msg>`, word, i, i%7)))
+	}
+	dict, err := zstd.BuildDict(zstd.BuildDictOptions{ID: id, Contents: hist, History: bytes.Join(hist, nil)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dict
+}
+
+func zstdEncode(t *testing.T, data, dict []byte) []byte {
+	t.Helper()
+	opts := []zstd.EOption{}
+	if dict != nil {
+		opts = append(opts, zstd.WithEncoderDict(dict))
+	}
+	enc, err := zstd.NewWriter(nil, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Close()
+	return enc.EncodeAll(data, nil)
+}
+
+func TestContentDecoder(t *testing.T) {
+	dict := buildZstdDict(t, 7, "hello")
+	msg := []byte(`<msg><appmsg><title>x</title></appmsg></msg>`)
+	withDict := zstdEncode(t, msg, dict)
+
+	plain, err := NewContentDecoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plain.Close()
+	if s, err := plain.DecodeString(zstdEncode(t, []byte("你好"), nil)); err != nil || s != "你好" {
+		t.Errorf("no dict: %q, %v", s, err)
+	}
+	if _, err := plain.Decompress(withDict); err == nil {
+		t.Error("frame needs dict, want error")
+	}
+	if s, err := plain.DecodeString([]byte("plain")); err != nil || s != "plain" {
+		t.Errorf("not zstd: %q, %v", s, err)
+	}
+
+	// 坏字典和重复 ID 的字典被跳过，其余字典照常可用
+	bad := append(bytes.Clone(zstdDictMagic), 1, 0, 0, 0, 0xde, 0xad)
+	dup := buildZstdDict(t, 7, "other")
+	dec, err := NewContentDecoder(bad, dict, dup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dec.Close()
+	if dec.dicts != 1 {
+		t.Errorf("dicts = %d, want 1", dec.dicts)
+	}
+	if s, err := dec.DecodeString(withDict); err != nil || s != string(msg) {
+		t.Errorf("with dict: %q, %v", s, err)
+	}
+}
+
+func TestLoadZstdDicts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "message_0.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	dict := buildZstdDict(t, 7, "hello")
+	for _, s := range []string{`CREATE TABLE WCDB_Dict(id INT, content BLOB)`, `CREATE TABLE other(content BLOB)`} {
+		if _, err := db.Exec(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec(`INSERT INTO WCDB_Dict VALUES (1, ?), (2, x'00')`, dict); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO other VALUES (?)`, dict); err != nil {
+		t.Fatal(err)
+	}
+	dicts, err := loadZstdDicts(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dicts) != 1 || !bytes.Equal(dicts[0], dict) {
+		t.Errorf("got %d dicts, want the one in WCDB_Dict", len(dicts))
+	}
+}